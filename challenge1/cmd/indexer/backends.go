@@ -0,0 +1,10 @@
+package main
+
+// Storage backends register themselves with the storage package and are
+// selected at runtime by DB_BACKEND.
+import (
+	_ "github.com/zacksfF/sepolia-sh/ch1/internal/storage/bolt"
+	_ "github.com/zacksfF/sepolia-sh/ch1/internal/storage/flat"
+	_ "github.com/zacksfF/sepolia-sh/ch1/internal/storage/pebble"
+	_ "github.com/zacksfF/sepolia-sh/ch1/internal/storage/sqlite"
+)
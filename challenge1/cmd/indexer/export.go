@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/zacksfF/sepolia-sh/ch1/config"
+	"github.com/zacksfF/sepolia-sh/ch1/internal/export"
+	"github.com/zacksfF/sepolia-sh/ch1/internal/storage"
+)
+
+// runExport streams events from the database to a file or stdout. Like
+// serve it opens the database read-only, which only SQLite allows while
+// the indexer is running.
+func runExport(ctx context.Context, args []string) {
+	cfg := config.LoadServe()
+
+	var r export.Range
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	format := fs.String("format", export.FormatCSV, "output format: csv, jsonl or parquet")
+	out := fs.String("out", "", "output file (default stdout)")
+	columns := fs.String("columns", "", "comma-separated columns (default all): "+strings.Join(export.ColumnNames(), ","))
+	fs.Var(optUint{&r.FromIndex}, "from-index", "first index to export")
+	fs.Var(optUint{&r.ToIndex}, "to-index", "last index to export")
+	fs.Var(optUint{&r.FromBlock}, "from-block", "first block to export")
+	fs.Var(optUint{&r.ToBlock}, "to-block", "last block to export")
+	_ = fs.Parse(args)
+
+	var names []string
+	if *columns != "" {
+		names = strings.Split(*columns, ",")
+	}
+
+	store, err := storage.OpenReadOnly(cfg.DBBackend, cfg.DBPath)
+	if err != nil {
+		log.Fatalf("failed to open db: %v", err)
+	}
+	defer store.Close()
+
+	dst := os.Stdout
+	if *out != "" {
+		if dst, err = os.Create(*out); err != nil {
+			log.Fatalf("failed to create output: %v", err)
+		}
+	}
+	buf := bufio.NewWriter(dst)
+
+	w, err := export.NewWriter(buf, *format, names)
+	if err != nil {
+		log.Fatalf("export: %v", err)
+	}
+
+	n, err := export.Events(ctx, store, w, r)
+	if err != nil {
+		log.Fatalf("export failed: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		log.Fatalf("export failed: %v", err)
+	}
+	if err := buf.Flush(); err != nil {
+		log.Fatalf("export failed: %v", err)
+	}
+	if err := dst.Close(); err != nil {
+		log.Fatalf("export failed: %v", err)
+	}
+
+	log.Printf("exported %d events", n)
+}
+
+// optUint is a flag that stays nil unless set.
+type optUint struct{ v **uint64 }
+
+func (o optUint) String() string {
+	if o.v == nil || *o.v == nil {
+		return ""
+	}
+	return strconv.FormatUint(**o.v, 10)
+}
+
+func (o optUint) Set(s string) error {
+	u, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return err
+	}
+	*o.v = &u
+	return nil
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"maps"
+	"net/http"
+	"slices"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/zacksfF/sepolia-sh/ch1/config"
+	"github.com/zacksfF/sepolia-sh/ch1/internal/api"
+	"github.com/zacksfF/sepolia-sh/ch1/internal/eth"
+	"github.com/zacksfF/sepolia-sh/ch1/internal/indexer"
+	"github.com/zacksfF/sepolia-sh/ch1/internal/metrics"
+	"github.com/zacksfF/sepolia-sh/ch1/internal/storage"
+)
+
+// runIndex syncs events into the database, optionally serving the query
+// API from the same process so readers don't contend for the file lock.
+func runIndex(ctx context.Context) {
+	cfg := config.Load()
+
+	ethClient, err := eth.Dial(ctx, cfg.RPCURL, eth.RetryPolicy{
+		MaxRetries: int(cfg.RPCMaxRetries),
+		BaseDelay:  cfg.RPCRetryDelay,
+		MaxDelay:   cfg.RPCRetryMaxDelay,
+		Budget:     cfg.RPCRetryBudget,
+	})
+	if err != nil {
+		log.Fatalf("failed to connect to ethereum rpc: %v", err)
+	}
+	defer logRetries(ethClient)
+
+	store, err := storage.Open(cfg.DBBackend, cfg.DBPath)
+	if err != nil {
+		log.Fatalf("failed to open db: %v", err)
+	}
+	defer store.Close()
+
+	if cfg.HTTPAddr != "" {
+		go func() {
+			if err := serveHTTP(ctx, "query server", cfg.HTTPAddr, api.New(store)); err != nil {
+				log.Printf("query server stopped: %v", err)
+			}
+		}()
+	}
+	if cfg.MetricsAddr != "" {
+		go func() {
+			mux := http.NewServeMux()
+			mux.Handle("GET /metrics", metrics.Handler())
+			if err := serveHTTP(ctx, "metrics server", cfg.MetricsAddr, mux); err != nil {
+				log.Printf("metrics server stopped: %v", err)
+			}
+		}()
+	}
+
+	idx := indexer.New(
+		ethClient,
+		store,
+		common.HexToAddress(cfg.Contract),
+		common.HexToHash(cfg.Topic),
+		indexer.Config{
+			ReorgWindow:   int(cfg.ReorgWindow),
+			Target:        cfg.SyncTarget,
+			Confirmations: cfg.Confirmations,
+			FetchWorkers:  int(cfg.FetchWorkers),
+			RPCBatchSize:  int(cfg.RPCBatchSize),
+		},
+	)
+
+	if cfg.Follow {
+		err = idx.Follow(ctx, cfg.StartBlock, cfg.BatchSize, cfg.PollInterval)
+	} else {
+		err = idx.Run(ctx, cfg.StartBlock, cfg.EndBlock, cfg.BatchSize)
+	}
+	switch {
+	case ctx.Err() != nil || errors.Is(err, context.Canceled):
+		log.Println("indexer stopped: shutting down")
+	case err != nil:
+		logRetries(ethClient)
+		log.Fatalf("indexer failed: %v", err)
+	default:
+		log.Println("indexer finished successfully")
+	}
+}
+
+// logRetries reports how often each RPC method had to be retried
+func logRetries(c *eth.Client) {
+	stats := c.RetryStats()
+	for _, method := range slices.Sorted(maps.Keys(stats)) {
+		s := stats[method]
+		log.Printf("rpc %s: %d retries, %d calls gave up", method, s.Retries, s.Exhausted)
+	}
+}
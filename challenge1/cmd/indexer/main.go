@@ -6,18 +6,23 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
-	"time"
-
-	"github.com/ethereum/go-ethereum/common"
-	"github.com/zacksfF/sepolia-sh/ch1/config"
-	"github.com/zacksfF/sepolia-sh/ch1/internal/eth"
-	"github.com/zacksfF/sepolia-sh/ch1/internal/indexer"
-	"github.com/zacksfF/sepolia-sh/ch1/internal/storage/bolt"
 )
 
+const usage = `usage: indexer [command]
+
+commands:
+  index   sync events from the chain into the database (default)
+  serve   serve the database over a read-only HTTP/JSON API
+  export  stream events to CSV, JSON Lines or Parquet (see export -h)`
+
 func main() {
-	cfg := config.Load()
+	cmd := "index"
+	if len(os.Args) > 1 {
+		cmd = os.Args[1]
+	}
 
+	// no global timeout: a backfill takes as long as it takes, and follow
+	// mode and the server run until they are signalled
 	ctx, stop := signal.NotifyContext(
 		context.Background(),
 		os.Interrupt,
@@ -25,35 +30,16 @@ func main() {
 	)
 	defer stop()
 
-	ctx, cancel := context.WithTimeout(ctx, 10*time.Minute)
-	defer cancel()
-
-	ethClient, err := eth.Dial(ctx, cfg.RPCURL)
-	if err != nil {
-		log.Fatalf("failed to connect to ethereum rpc: %v", err)
-	}
-
-	store, err := bolt.Open(cfg.DBPath)
-	if err != nil {
-		log.Fatalf("failed to open db: %v", err)
-	}
-	defer store.Close()
-
-	idx := indexer.New(
-		ethClient,
-		store,
-		common.HexToAddress(cfg.Contract),
-		common.HexToHash(cfg.Topic),
-	)
-
-	if err := idx.Run(
-		ctx,
-		cfg.StartBlock,
-		cfg.EndBlock, 
-		cfg.BatchSize,
-	); err != nil {
-		log.Fatalf("indexer failed: %v", err)
+	switch cmd {
+	case "index":
+		runIndex(ctx)
+	case "serve":
+		runServe(ctx)
+	case "export":
+		runExport(ctx, os.Args[2:])
+	case "help", "-h", "--help":
+		log.Println(usage)
+	default:
+		log.Fatalf("unknown command %q\n%s", cmd, usage)
 	}
-
-	log.Println("indexer finished successfully")
 }
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/zacksfF/sepolia-sh/ch1/config"
+	"github.com/zacksfF/sepolia-sh/ch1/internal/api"
+	"github.com/zacksfF/sepolia-sh/ch1/internal/storage"
+)
+
+// runServe serves an existing database read-only. Bolt, Pebble and flat
+// lock the database against other processes while the indexer holds it,
+// so for a live database on those backends set HTTP_ADDR on the indexer
+// instead.
+func runServe(ctx context.Context) {
+	cfg := config.LoadServe()
+
+	store, err := storage.OpenReadOnly(cfg.DBBackend, cfg.DBPath)
+	if err != nil {
+		log.Fatalf("failed to open db: %v", err)
+	}
+	defer store.Close()
+
+	if err := serveHTTP(ctx, "query server", cfg.HTTPAddr, api.New(store)); err != nil {
+		log.Fatalf("query server failed: %v", err)
+	}
+}
+
+// serveHTTP runs handler on addr until ctx is cancelled, then drains
+// in-flight requests. name only labels the log line.
+func serveHTTP(ctx context.Context, name, addr string, handler http.Handler) error {
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	errc := make(chan error, 1)
+	go func() {
+		log.Printf("%s listening on %s", name, addr)
+		errc <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+	if err := <-errc; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
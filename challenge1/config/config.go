@@ -4,30 +4,82 @@ import (
 	"log"
 	"os"
 	"strconv"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
 type Config struct {
-	RPCURL     string
-	DBPath     string
-	StartBlock uint64
-	EndBlock   *uint64 
-	BatchSize  uint64
-	Contract   string
-	Topic      string
+	RPCURL      string
+	DBBackend   string // storage backend: bolt, pebble, sqlite or flat
+	DBPath      string // file, directory or DSN, depending on the backend
+	StartBlock  uint64
+	EndBlock    *uint64
+	BatchSize   uint64
+	Contract    string
+	Topic       string
+	ReorgWindow uint64
+
+	Follow       bool          // keep tailing the head after the backfill
+	PollInterval time.Duration // head polling interval in follow mode
+
+	SyncTarget    string // latest, safe or finalized
+	Confirmations uint64 // blocks to stay behind the sync target
+
+	FetchWorkers uint64 // header requests in flight per log batch
+	RPCBatchSize uint64 // headers per JSON-RPC batch request, 1 disables batching
+
+	RPCMaxRetries    uint64        // retries of a failed RPC call, 0 disables retrying
+	RPCRetryDelay    time.Duration // backoff before the first retry
+	RPCRetryMaxDelay time.Duration // cap on a single backoff
+	RPCRetryBudget   time.Duration // total backoff allowed per call
+
+	HTTPAddr    string // serve the query API alongside indexing when set
+	MetricsAddr string // serve Prometheus metrics when set
+}
+
+// ServeConfig is the subset needed by the read-only query server.
+type ServeConfig struct {
+	DBBackend string
+	DBPath    string
+	HTTPAddr  string
 }
 
 func Load() Config {
 	// load .env file if present (silently ignore if missing)
 	_ = godotenv.Load()
 	cfg := Config{
-		RPCURL:     getEnv("RPC_URL", "https://ethereum-sepolia-rpc.publicnode.com"),
-		DBPath:     getEnv("DB_PATH", "./sepolia.db"),
-		BatchSize:  getEnvUint("BATCH_SIZE", 5000),
-		Contract:   mustEnv("CONTRACT_ADDRESS"),
-		Topic:      mustEnv("EVENT_TOPIC"),
-		StartBlock: getEnvUint("START_BLOCK", 0),
+		RPCURL:      getEnv("RPC_URL", "https://ethereum-sepolia-rpc.publicnode.com"),
+		DBBackend:   getEnv("DB_BACKEND", "bolt"),
+		DBPath:      dbDSN(),
+		BatchSize:   getEnvUint("BATCH_SIZE", 5000),
+		Contract:    mustEnv("CONTRACT_ADDRESS"),
+		Topic:       mustEnv("EVENT_TOPIC"),
+		StartBlock:  getEnvUint("START_BLOCK", 0),
+		ReorgWindow: getEnvUint("REORG_WINDOW", 64),
+
+		Follow:       getEnvBool("FOLLOW", false),
+		PollInterval: getEnvDuration("POLL_INTERVAL", 12*time.Second),
+
+		SyncTarget:    getEnv("SYNC_TARGET", "latest"),
+		Confirmations: getEnvUint("CONFIRMATIONS", 0),
+
+		FetchWorkers: getEnvUint("FETCH_WORKERS", 8),
+		RPCBatchSize: getEnvUint("RPC_BATCH_SIZE", 100),
+
+		RPCMaxRetries:    getEnvUint("RPC_MAX_RETRIES", 5),
+		RPCRetryDelay:    getEnvDuration("RPC_RETRY_DELAY", 500*time.Millisecond),
+		RPCRetryMaxDelay: getEnvDuration("RPC_RETRY_MAX_DELAY", 30*time.Second),
+		RPCRetryBudget:   getEnvDuration("RPC_RETRY_BUDGET", 2*time.Minute),
+
+		HTTPAddr:    os.Getenv("HTTP_ADDR"),
+		MetricsAddr: os.Getenv("METRICS_ADDR"),
+	}
+
+	switch cfg.SyncTarget {
+	case "latest", "safe", "finalized":
+	default:
+		log.Fatalf("invalid SYNC_TARGET %q: want latest, safe or finalized", cfg.SyncTarget)
 	}
 
 	if v := os.Getenv("END_BLOCK"); v != "" {
@@ -38,9 +90,29 @@ func Load() Config {
 		cfg.EndBlock = &end
 	}
 
+	if cfg.Follow && cfg.EndBlock != nil {
+		log.Fatalf("END_BLOCK cannot be combined with FOLLOW")
+	}
+
 	return cfg
 }
 
+// LoadServe loads the query server configuration. Unlike Load it doesn't
+// require the contract and topic, since the server never talks to a node.
+func LoadServe() ServeConfig {
+	_ = godotenv.Load()
+	return ServeConfig{
+		DBBackend: getEnv("DB_BACKEND", "bolt"),
+		DBPath:    dbDSN(),
+		HTTPAddr:  getEnv("HTTP_ADDR", ":8080"),
+	}
+}
+
+// dbDSN returns DB_DSN, falling back to DB_PATH for existing setups.
+func dbDSN() string {
+	return getEnv("DB_DSN", getEnv("DB_PATH", "./sepolia.db"))
+}
+
 func getEnv(key, def string) string {
 	if v := os.Getenv(key); v != "" {
 		return v
@@ -66,3 +138,28 @@ func getEnvUint(key string, def uint64) uint64 {
 	}
 	return def
 }
+
+func getEnvBool(key string, def bool) bool {
+	if v := os.Getenv(key); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			log.Fatalf("invalid %s: %v", key, err)
+		}
+		return b
+	}
+	return def
+}
+
+func getEnvDuration(key string, def time.Duration) time.Duration {
+	if v := os.Getenv(key); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			log.Fatalf("invalid %s: %v", key, err)
+		}
+		if d <= 0 {
+			log.Fatalf("invalid %s: must be positive", key)
+		}
+		return d
+	}
+	return def
+}
@@ -0,0 +1,407 @@
+// Package api serves the indexed events over a read-only HTTP/JSON API.
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/zacksfF/sepolia-sh/ch1/internal/l1infotree"
+	"github.com/zacksfF/sepolia-sh/ch1/internal/model"
+	"github.com/zacksfF/sepolia-sh/ch1/internal/storage"
+)
+
+const (
+	defaultPageSize = 100
+	maxPageSize     = 1000
+)
+
+// Server exposes a storage.Store over HTTP. It only reads.
+type Server struct {
+	store storage.Store
+	mux   *http.ServeMux
+}
+
+// New creates a server over the given store.
+func New(store storage.Store) *Server {
+	s := &Server{store: store, mux: http.NewServeMux()}
+
+	s.mux.HandleFunc("GET /events", s.handleRange)
+	s.mux.HandleFunc("GET /events/latest", s.handleLatest)
+	s.mux.HandleFunc("GET /events/{index}", s.handleEvent)
+	s.mux.HandleFunc("GET /blocks/{number}/events", s.handleBlock)
+	s.mux.HandleFunc("GET /txs/{hash}/events", s.handleTx)
+	s.mux.HandleFunc("GET /roots/global/{root}", s.handleGlobalExitRoot)
+	s.mux.HandleFunc("GET /roots/info/{root}", s.handleInfoRoot)
+	s.mux.HandleFunc("GET /proofs/{index}", s.handleProof)
+	s.mux.HandleFunc("GET /status", s.handleStatus)
+
+	return s
+}
+
+// ServeHTTP implements http.Handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// eventResponse is an event plus the L1 info root right after it.
+type eventResponse struct {
+	*model.IndexedEvent
+	L1InfoRoot common.Hash `json:"l1InfoRoot"`
+}
+
+// pageResponse is one page of a range scan. Next is the index to pass as
+// `from` for the following page, absent on the last page.
+type pageResponse struct {
+	Events []*model.IndexedEvent `json:"events"`
+	Next   *uint64               `json:"next,omitempty"`
+}
+
+type statusResponse struct {
+	NextIndex   uint64             `json:"nextIndex"`
+	Checkpoint  *storage.BlockRef  `json:"checkpoint"`
+	Bound       *storage.SyncBound `json:"bound"`
+	LatestIndex *uint64            `json:"latestIndex"`
+	L1InfoRoot  *common.Hash       `json:"l1InfoRoot"`
+}
+
+type proofResponse struct {
+	Index uint64                         `json:"index"`
+	Count uint64                         `json:"count"`
+	Leaf  common.Hash                    `json:"leaf"`
+	Root  common.Hash                    `json:"root"`
+	Proof [l1infotree.Height]common.Hash `json:"proof"`
+}
+
+// GET /events/{index}
+func (s *Server) handleEvent(w http.ResponseWriter, r *http.Request) {
+	index, err := strconv.ParseUint(r.PathValue("index"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid index: %w", err))
+		return
+	}
+	s.writeEvent(w, r, func(storage.Reader) (uint64, error) { return index, nil })
+}
+
+// GET /events/latest
+func (s *Server) handleLatest(w http.ResponseWriter, r *http.Request) {
+	s.writeEvent(w, r, func(sr storage.Reader) (uint64, error) {
+		event, err := sr.LatestEvent(r.Context())
+		if err != nil {
+			return 0, err
+		}
+		return event.Index, nil
+	})
+}
+
+// GET /events?from=0&limit=100
+func (s *Server) handleRange(w http.ResponseWriter, r *http.Request) {
+	from, err := queryUint(r, "from", 0)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	limit, err := queryUint(r, "limit", defaultPageSize)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if limit == 0 || limit > maxPageSize {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("limit must be between 1 and %d", maxPageSize))
+		return
+	}
+
+	// read one extra event to know whether another page follows; near the
+	// top of the index space the page just ends there
+	to := from + min(limit, math.MaxUint64-from)
+	page := pageResponse{Events: []*model.IndexedEvent{}}
+	err = s.store.IterateEvents(r.Context(), from, to, func(e *model.IndexedEvent) error {
+		if uint64(len(page.Events)) == limit {
+			next := e.Index
+			page.Next = &next
+			return storage.ErrStop
+		}
+		page.Events = append(page.Events, e)
+		return nil
+	})
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, page)
+}
+
+// GET /blocks/{number}/events
+func (s *Server) handleBlock(w http.ResponseWriter, r *http.Request) {
+	number, err := strconv.ParseUint(r.PathValue("number"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid block number: %w", err))
+		return
+	}
+
+	ctx := r.Context()
+	var events []*model.IndexedEvent
+	err = s.store.View(ctx, func(sr storage.Reader) error {
+		first, last, err := sr.EventRangeByBlock(ctx, number)
+		if err != nil {
+			return err
+		}
+		events = make([]*model.IndexedEvent, 0, last-first+1)
+		for idx := first; idx <= last; idx++ {
+			e, err := sr.GetEvent(ctx, idx)
+			if err != nil {
+				return err
+			}
+			events = append(events, e)
+		}
+		return nil
+	})
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, events)
+}
+
+// GET /txs/{hash}/events
+func (s *Server) handleTx(w http.ResponseWriter, r *http.Request) {
+	hash, err := pathHash(r, "hash")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	// one snapshot, so the indices can't go stale before the events are read
+	ctx := r.Context()
+	var events []*model.IndexedEvent
+	err = s.store.View(ctx, func(sr storage.Reader) error {
+		indices, err := sr.IndicesByTx(ctx, hash)
+		if err != nil {
+			return err
+		}
+		events = make([]*model.IndexedEvent, 0, len(indices))
+		for _, idx := range indices {
+			e, err := sr.GetEvent(ctx, idx)
+			if err != nil {
+				return err
+			}
+			events = append(events, e)
+		}
+		return nil
+	})
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, events)
+}
+
+// GET /roots/global/{root}
+func (s *Server) handleGlobalExitRoot(w http.ResponseWriter, r *http.Request) {
+	root, err := pathHash(r, "root")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	s.writeEvent(w, r, func(sr storage.Reader) (uint64, error) {
+		return sr.IndexByGlobalExitRoot(r.Context(), root)
+	})
+}
+
+// GET /roots/info/{root}
+func (s *Server) handleInfoRoot(w http.ResponseWriter, r *http.Request) {
+	root, err := pathHash(r, "root")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	s.writeEvent(w, r, func(sr storage.Reader) (uint64, error) {
+		return sr.IndexByInfoRoot(r.Context(), root)
+	})
+}
+
+// GET /proofs/{index}?count=N proves leaf index against the root of the
+// first N leaves, defaulting to the current root.
+func (s *Server) handleProof(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	index, err := strconv.ParseUint(r.PathValue("index"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid index: %w", err))
+		return
+	}
+
+	// count defaults to the number of leaves, which needs the snapshot
+	count, err := queryUint(r, "count", 0)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	countSet := r.URL.Query().Has("count")
+
+	// one snapshot, so a rollback can't slip between the reads
+	resp := proofResponse{Index: index}
+	err = s.store.View(ctx, func(sr storage.Reader) error {
+		next, err := sr.GetNextIndex(ctx)
+		if err != nil {
+			return err
+		}
+		if !countSet {
+			count = next
+		}
+		if count > next {
+			return statusError{http.StatusNotFound, fmt.Errorf("only %d leaves indexed", next)}
+		}
+		if index >= count {
+			return statusError{http.StatusBadRequest, fmt.Errorf("index %d not below count %d", index, count)}
+		}
+
+		event, err := sr.GetEvent(ctx, index)
+		if err != nil {
+			return err
+		}
+		if resp.Root, err = sr.GetInfoRoot(ctx, count-1); err != nil {
+			return err
+		}
+		if resp.Proof, err = l1infotree.Proof(ctx, sr, index, count); err != nil {
+			return err
+		}
+		resp.Count = count
+		resp.Leaf = l1infotree.LeafHash(event.GlobalExitRoot, event.ParentHash, event.BlockTime)
+		return nil
+	})
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// GET /status
+func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var status statusResponse
+	err := s.store.View(ctx, func(sr storage.Reader) error {
+		var err error
+		if status.NextIndex, err = sr.GetNextIndex(ctx); err != nil {
+			return err
+		}
+		if status.Checkpoint, err = sr.GetCheckpoint(ctx); err != nil {
+			return err
+		}
+		if status.Bound, err = sr.GetSyncBound(ctx); err != nil {
+			return err
+		}
+		if status.NextIndex > 0 {
+			latest := status.NextIndex - 1
+			root, err := sr.GetInfoRoot(ctx, latest)
+			if err != nil {
+				return err
+			}
+			status.LatestIndex = &latest
+			status.L1InfoRoot = &root
+		}
+		return nil
+	})
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, status)
+}
+
+// writeEvent writes the event at the index lookup finds, with its L1 info
+// root. All three reads share one snapshot, so a rollback can't pair the
+// event with a root from another chain.
+func (s *Server) writeEvent(w http.ResponseWriter, r *http.Request, lookup func(storage.Reader) (uint64, error)) {
+	ctx := r.Context()
+
+	var resp eventResponse
+	err := s.store.View(ctx, func(sr storage.Reader) error {
+		index, err := lookup(sr)
+		if err != nil {
+			return err
+		}
+		if resp.IndexedEvent, err = sr.GetEvent(ctx, index); err != nil {
+			return err
+		}
+		resp.L1InfoRoot, err = sr.GetInfoRoot(ctx, index)
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil
+		}
+		return err
+	})
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func queryUint(r *http.Request, key string, def uint64) (uint64, error) {
+	v := r.URL.Query().Get(key)
+	if v == "" {
+		return def, nil
+	}
+	u, err := strconv.ParseUint(v, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return u, nil
+}
+
+func pathHash(r *http.Request, key string) (common.Hash, error) {
+	b, err := hexutil.Decode(r.PathValue(key))
+	if err != nil || len(b) != common.HashLength {
+		return common.Hash{}, fmt.Errorf("invalid %s: want 0x-prefixed 32-byte hex", key)
+	}
+	return common.BytesToHash(b), nil
+}
+
+// statusError carries a client error out of a store view
+type statusError struct {
+	status int
+	err    error
+}
+
+func (e statusError) Error() string { return e.err.Error() }
+
+func writeStoreError(w http.ResponseWriter, err error) {
+	var serr statusError
+	if errors.As(err, &serr) {
+		writeError(w, serr.status, serr.err)
+		return
+	}
+	if errors.Is(err, storage.ErrNotFound) {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	log.Printf("api: %v", err)
+	writeError(w, http.StatusInternalServerError, errors.New("internal error"))
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("api: encode response: %v", err)
+	}
+}
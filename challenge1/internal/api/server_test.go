@@ -0,0 +1,298 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/zacksfF/sepolia-sh/ch1/internal/l1infotree"
+	"github.com/zacksfF/sepolia-sh/ch1/internal/model"
+	"github.com/zacksfF/sepolia-sh/ch1/internal/storage"
+	"github.com/zacksfF/sepolia-sh/ch1/internal/storage/memory"
+)
+
+// newTestServer indexes five events: blocks 100, 100, 101, 102, 103 with
+// one shared tx in block 100.
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	srv := httptest.NewServer(New(newTestStore(t)))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// newTestStore holds the events behind newTestServer
+func newTestStore(t *testing.T) *memory.Store {
+	t.Helper()
+
+	store := memory.New()
+
+	tree := l1infotree.New()
+	batch := &storage.Batch{
+		Checkpoint: storage.BlockRef{Number: 103, Hash: common.HexToHash("0x103")},
+		Bound:      &storage.SyncBound{Tag: "finalized", Number: 103},
+		Window:     10,
+	}
+	nodes := map[[2]uint64]l1infotree.Node{}
+	for i, bn := range []uint64{100, 100, 101, 102, 103} {
+		e := &model.IndexedEvent{
+			Index:          uint64(i),
+			BlockNumber:    bn,
+			BlockTime:      1700000000 + bn,
+			TxHash:         common.BigToHash(new(big.Int).SetUint64(bn)),
+			LogIndex:       uint(i),
+			GlobalExitRoot: common.BigToHash(new(big.Int).SetUint64(uint64(1000 + i))),
+		}
+		touched, root := tree.Append(l1infotree.LeafHash(e.GlobalExitRoot, e.ParentHash, e.BlockTime))
+		for _, n := range touched {
+			nodes[[2]uint64{uint64(n.Level), n.Pos}] = n
+		}
+		batch.Events = append(batch.Events, e)
+		batch.InfoRoots = append(batch.InfoRoots, root)
+	}
+	for _, n := range nodes {
+		batch.TreeNodes = append(batch.TreeNodes, n)
+	}
+	batch.NextIndex = uint64(len(batch.Events))
+
+	if err := store.WriteBatch(context.Background(), batch); err != nil {
+		t.Fatalf("Failed to write batch: %v", err)
+	}
+	return store
+}
+
+// rollbackStore rolls back to block 101 right after a request's first
+// lookup, from another goroutine, and gives the rollback a moment to land.
+// A handler that reads outside one View then finds its index gone.
+type rollbackStore struct {
+	storage.Store
+	once sync.Once
+	done chan error
+}
+
+func (s *rollbackStore) rollback() {
+	s.once.Do(func() {
+		go func() {
+			_, err := s.Store.Rollback(context.Background(), 101)
+			s.done <- err
+		}()
+		select {
+		case err := <-s.done:
+			s.done <- err
+		case <-time.After(50 * time.Millisecond):
+		}
+	})
+}
+
+func (s *rollbackStore) IndicesByTx(ctx context.Context, hash common.Hash) ([]uint64, error) {
+	defer s.rollback()
+	return s.Store.IndicesByTx(ctx, hash)
+}
+
+func (s *rollbackStore) IndexByInfoRoot(ctx context.Context, root common.Hash) (uint64, error) {
+	defer s.rollback()
+	return s.Store.IndexByInfoRoot(ctx, root)
+}
+
+func (s *rollbackStore) View(ctx context.Context, fn func(storage.Reader) error) error {
+	return s.Store.View(ctx, func(r storage.Reader) error {
+		return fn(rollbackReader{r, s})
+	})
+}
+
+type rollbackReader struct {
+	storage.Reader
+	s *rollbackStore
+}
+
+func (r rollbackReader) IndicesByTx(ctx context.Context, hash common.Hash) ([]uint64, error) {
+	defer r.s.rollback()
+	return r.Reader.IndicesByTx(ctx, hash)
+}
+
+func (r rollbackReader) IndexByInfoRoot(ctx context.Context, root common.Hash) (uint64, error) {
+	defer r.s.rollback()
+	return r.Reader.IndexByInfoRoot(ctx, root)
+}
+
+func getJSON(t *testing.T, url string, wantStatus int, out any) {
+	t.Helper()
+
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("GET %s: %v", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != wantStatus {
+		t.Fatalf("GET %s: status %d, want %d", url, resp.StatusCode, wantStatus)
+	}
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			t.Fatalf("GET %s: decode: %v", url, err)
+		}
+	}
+}
+
+func TestServer_Events(t *testing.T) {
+	srv := newTestServer(t)
+
+	var event eventResponse
+	getJSON(t, srv.URL+"/events/2", http.StatusOK, &event)
+	if event.Index != 2 || event.BlockNumber != 101 {
+		t.Errorf("Event 2 mismatch: got index %d block %d", event.Index, event.BlockNumber)
+	}
+	if event.L1InfoRoot == (common.Hash{}) {
+		t.Error("Expected event to carry its L1 info root")
+	}
+
+	getJSON(t, srv.URL+"/events/latest", http.StatusOK, &event)
+	if event.Index != 4 {
+		t.Errorf("Latest event index: got %d, want 4", event.Index)
+	}
+
+	getJSON(t, srv.URL+"/events/99", http.StatusNotFound, nil)
+	getJSON(t, srv.URL+"/events/abc", http.StatusBadRequest, nil)
+}
+
+func TestServer_Pagination(t *testing.T) {
+	srv := newTestServer(t)
+
+	var page pageResponse
+	getJSON(t, srv.URL+"/events?from=0&limit=2", http.StatusOK, &page)
+	if len(page.Events) != 2 || page.Next == nil || *page.Next != 2 {
+		t.Fatalf("First page: got %d events, next %v", len(page.Events), page.Next)
+	}
+
+	page = pageResponse{}
+	getJSON(t, srv.URL+"/events?from=4&limit=2", http.StatusOK, &page)
+	if len(page.Events) != 1 || page.Next != nil {
+		t.Errorf("Last page: got %d events, next %v", len(page.Events), page.Next)
+	}
+
+	getJSON(t, srv.URL+"/events?limit=0", http.StatusBadRequest, nil)
+
+	// from+limit past the top of the index space must not wrap around
+	page = pageResponse{}
+	getJSON(t, srv.URL+"/events?from=18446744073709551610&limit=1000", http.StatusOK, &page)
+	if len(page.Events) != 0 || page.Next != nil {
+		t.Errorf("Page past the end: got %d events, next %v", len(page.Events), page.Next)
+	}
+}
+
+func TestServer_Lookups(t *testing.T) {
+	srv := newTestServer(t)
+
+	var events []*model.IndexedEvent
+	getJSON(t, srv.URL+"/blocks/100/events", http.StatusOK, &events)
+	if len(events) != 2 {
+		t.Errorf("Block 100: got %d events, want 2", len(events))
+	}
+
+	tx := common.BigToHash(new(big.Int).SetUint64(102))
+	getJSON(t, srv.URL+"/txs/"+tx.Hex()+"/events", http.StatusOK, &events)
+	if len(events) != 1 || events[0].Index != 3 {
+		t.Errorf("Tx lookup mismatch: %+v", events)
+	}
+
+	var event eventResponse
+	ger := common.BigToHash(new(big.Int).SetUint64(1001))
+	getJSON(t, srv.URL+"/roots/global/"+ger.Hex(), http.StatusOK, &event)
+	if event.Index != 1 {
+		t.Errorf("Global exit root lookup: got index %d, want 1", event.Index)
+	}
+
+	root := event.L1InfoRoot
+	getJSON(t, srv.URL+"/roots/info/"+root.Hex(), http.StatusOK, &event)
+	if event.Index != 1 {
+		t.Errorf("Info root lookup: got index %d, want 1", event.Index)
+	}
+
+	getJSON(t, srv.URL+"/blocks/999/events", http.StatusNotFound, nil)
+	getJSON(t, srv.URL+"/txs/0x1234/events", http.StatusBadRequest, nil)
+}
+
+func TestServer_StatusAndProof(t *testing.T) {
+	srv := newTestServer(t)
+
+	var status statusResponse
+	getJSON(t, srv.URL+"/status", http.StatusOK, &status)
+	if status.NextIndex != 5 || status.LatestIndex == nil || *status.LatestIndex != 4 {
+		t.Errorf("Status mismatch: %+v", status)
+	}
+	if status.Checkpoint == nil || status.Checkpoint.Number != 103 {
+		t.Errorf("Status checkpoint mismatch: %+v", status.Checkpoint)
+	}
+	if status.Bound == nil || status.Bound.Tag != "finalized" {
+		t.Errorf("Status bound mismatch: %+v", status.Bound)
+	}
+
+	var proof proofResponse
+	getJSON(t, srv.URL+"/proofs/1?count=3", http.StatusOK, &proof)
+	if !l1infotree.VerifyProof(proof.Leaf, proof.Index, proof.Proof, proof.Root) {
+		t.Error("Proof from API does not verify")
+	}
+
+	// without count the proof is against the current root
+	proof = proofResponse{}
+	getJSON(t, srv.URL+"/proofs/4", http.StatusOK, &proof)
+	if proof.Count != 5 || proof.Root != *status.L1InfoRoot {
+		t.Errorf("Default proof mismatch: count %d root %s, want 5 and %s", proof.Count, proof.Root, status.L1InfoRoot)
+	}
+	if !l1infotree.VerifyProof(proof.Leaf, proof.Index, proof.Proof, proof.Root) {
+		t.Error("Proof against the current root does not verify")
+	}
+
+	getJSON(t, srv.URL+"/proofs/1?count=9", http.StatusNotFound, nil)
+	getJSON(t, srv.URL+"/proofs/3?count=2", http.StatusBadRequest, nil)
+}
+
+func TestServer_RollbackBetweenReads(t *testing.T) {
+	ctx := context.Background()
+
+	root, err := newTestStore(t).GetInfoRoot(ctx, 3)
+	if err != nil {
+		t.Fatalf("Failed to get info root: %v", err)
+	}
+	tx := common.BigToHash(new(big.Int).SetUint64(102))
+
+	// event 3 is in block 102, which the rollback removes after the lookup;
+	// the response still shows the state the lookup saw
+	t.Run("tx", func(t *testing.T) {
+		store := &rollbackStore{Store: newTestStore(t), done: make(chan error, 1)}
+		srv := httptest.NewServer(New(store))
+		defer srv.Close()
+
+		var events []*model.IndexedEvent
+		getJSON(t, srv.URL+"/txs/"+tx.Hex()+"/events", http.StatusOK, &events)
+		if len(events) != 1 || events[0].Index != 3 {
+			t.Errorf("Tx lookup mismatch: %+v", events)
+		}
+		if err := <-store.done; err != nil {
+			t.Fatalf("Failed to roll back: %v", err)
+		}
+		getJSON(t, srv.URL+"/txs/"+tx.Hex()+"/events", http.StatusNotFound, nil)
+	})
+
+	t.Run("info root", func(t *testing.T) {
+		store := &rollbackStore{Store: newTestStore(t), done: make(chan error, 1)}
+		srv := httptest.NewServer(New(store))
+		defer srv.Close()
+
+		var event eventResponse
+		getJSON(t, srv.URL+"/roots/info/"+root.Hex(), http.StatusOK, &event)
+		if event.Index != 3 || event.L1InfoRoot != root {
+			t.Errorf("Info root lookup mismatch: got index %d root %s, want 3 and %s", event.Index, event.L1InfoRoot, root)
+		}
+		if err := <-store.done; err != nil {
+			t.Fatalf("Failed to roll back: %v", err)
+		}
+		getJSON(t, srv.URL+"/roots/info/"+root.Hex(), http.StatusNotFound, nil)
+	})
+}
@@ -2,22 +2,141 @@ package eth
 
 import (
 	"context"
+	"fmt"
+	"math/big"
+	"strconv"
 
+	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+	"golang.org/x/sync/errgroup"
 )
 
+// BlockFetcher fetches block headers; the indexer needs nothing from the
+// body, so transactions are never downloaded.
 type BlockFetcher struct {
-	client *Client
+	client    *Client
+	batchSize int // headers per JSON-RPC batch, <= 1 disables batching
 }
 
-func NewBlockFetcher(client *Client) *BlockFetcher {
-	return &BlockFetcher{client: client}
+func NewBlockFetcher(client *Client, batchSize int) *BlockFetcher {
+	return &BlockFetcher{client: client, batchSize: batchSize}
 }
 
 func (f *BlockFetcher) ByHash(
 	ctx context.Context,
 	hash common.Hash,
-) (*types.Block, error) {
-	return f.client.BlockByHash(ctx, hash)
+) (*types.Header, error) {
+	header, err := f.client.HeaderByHash(ctx, hash)
+	if err != nil {
+		return nil, fmt.Errorf("fetch header %s: %w", hash.Hex(), err)
+	}
+	return header, nil
+}
+
+// ByHashes fetches headers in JSON-RPC batches of batchSize, with at most
+// workers requests in flight, and returns them in the order of hashes. The
+// first failure cancels the requests still running.
+func (f *BlockFetcher) ByHashes(
+	ctx context.Context,
+	hashes []common.Hash,
+	workers int,
+) ([]*types.Header, error) {
+
+	calls := make([]headerCall, len(hashes))
+	for k, hash := range hashes {
+		calls[k] = headerCall{
+			method: "eth_getBlockByHash",
+			arg:    hash,
+			name:   hash.Hex(),
+			single: func(ctx context.Context) (*types.Header, error) { return f.ByHash(ctx, hash) },
+		}
+	}
+	return f.fetch(ctx, calls, workers)
+}
+
+// ByNumbers is ByHashes for the canonical headers at numbers
+func (f *BlockFetcher) ByNumbers(
+	ctx context.Context,
+	numbers []uint64,
+	workers int,
+) ([]*types.Header, error) {
+
+	calls := make([]headerCall, len(numbers))
+	for k, number := range numbers {
+		calls[k] = headerCall{
+			method: "eth_getBlockByNumber",
+			arg:    hexutil.EncodeUint64(number),
+			name:   strconv.FormatUint(number, 10),
+			single: func(ctx context.Context) (*types.Header, error) {
+				header, err := f.client.HeaderByNumber(ctx, new(big.Int).SetUint64(number))
+				if err != nil {
+					return nil, fmt.Errorf("fetch header %d: %w", number, err)
+				}
+				return header, nil
+			},
+		}
+	}
+	return f.fetch(ctx, calls, workers)
+}
+
+// headerCall is one header lookup, either alone or as a batch element
+type headerCall struct {
+	method string
+	arg    any
+	name   string // block hash or number, for errors
+	single func(ctx context.Context) (*types.Header, error)
+}
+
+// fetch runs calls in batches of batchSize on at most workers goroutines
+func (f *BlockFetcher) fetch(ctx context.Context, calls []headerCall, workers int) ([]*types.Header, error) {
+	headers := make([]*types.Header, len(calls))
+	size := max(f.batchSize, 1)
+
+	g, ctx := errgroup.WithContext(ctx)
+	g.SetLimit(max(workers, 1))
+	for lo := 0; lo < len(calls); lo += size {
+		hi := min(lo+size, len(calls))
+		g.Go(func() error {
+			if hi-lo == 1 {
+				h, err := calls[lo].single(ctx)
+				headers[lo] = h
+				return err
+			}
+			return f.batch(ctx, calls[lo:hi], headers[lo:hi])
+		})
+	}
+
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+	return headers, nil
+}
+
+// batch sends every call in a single JSON-RPC batch
+func (f *BlockFetcher) batch(ctx context.Context, calls []headerCall, headers []*types.Header) error {
+	elems := make([]rpc.BatchElem, len(calls))
+	for k, call := range calls {
+		elems[k] = rpc.BatchElem{
+			Method: call.method,
+			Args:   []any{call.arg, false},
+			Result: &headers[k],
+		}
+	}
+
+	if err := f.client.BatchCallContext(ctx, elems); err != nil {
+		return fmt.Errorf("fetch %d headers: %w", len(calls), err)
+	}
+	for k, elem := range elems {
+		if elem.Error != nil {
+			return fmt.Errorf("fetch header %s: %w", calls[k].name, elem.Error)
+		}
+		// a missing block decodes as JSON null
+		if headers[k] == nil {
+			return fmt.Errorf("fetch header %s: %w", calls[k].name, ethereum.NotFound)
+		}
+	}
+	return nil
 }
@@ -2,18 +2,95 @@ package eth
 
 import (
 	"context"
+	"math/big"
+	"net/http"
+	"sync"
 
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
 )
 
+// Client is an ethclient whose calls used by the indexer are retried
+// according to a RetryPolicy. Methods it does not override go straight to
+// the node.
 type Client struct {
 	*ethclient.Client
+
+	retry RetryPolicy
+
+	mu    sync.Mutex
+	stats map[string]RetryStats // by JSON-RPC method
 }
 
-func Dial(ctx context.Context, rpcURL string) (*Client, error) {
-	c, err := ethclient.DialContext(ctx, rpcURL)
+// Dial connects to rpcURL. Transient failures of the overridden calls are
+// retried with retry; the zero policy never retries.
+func Dial(ctx context.Context, rpcURL string, retry RetryPolicy) (*Client, error) {
+	// the transport reads Retry-After, which rpc.HTTPError doesn't keep
+	httpClient := &http.Client{Transport: retryAfterTransport{http.DefaultTransport}}
+
+	c, err := rpc.DialOptions(ctx, rpcURL, rpc.WithHTTPClient(httpClient))
 	if err != nil {
 		return nil, err
 	}
-	return &Client{Client: c}, nil
+	return &Client{
+		Client: ethclient.NewClient(c),
+		retry:  retry,
+		stats:  make(map[string]RetryStats),
+	}, nil
+}
+
+// BlockNumber returns the most recent block number
+func (c *Client) BlockNumber(ctx context.Context) (uint64, error) {
+	var n uint64
+	err := c.do(ctx, "eth_blockNumber", func(ctx context.Context) (err error) {
+		n, err = c.Client.BlockNumber(ctx)
+		return err
+	})
+	return n, err
+}
+
+// HeaderByNumber returns the canonical header at number, nil for latest
+func (c *Client) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	var h *types.Header
+	err := c.do(ctx, "eth_getBlockByNumber", func(ctx context.Context) (err error) {
+		h, err = c.Client.HeaderByNumber(ctx, number)
+		return err
+	})
+	return h, err
+}
+
+// HeaderByHash returns the header with the given hash
+func (c *Client) HeaderByHash(ctx context.Context, hash common.Hash) (*types.Header, error) {
+	var h *types.Header
+	err := c.do(ctx, "eth_getBlockByHash", func(ctx context.Context) (err error) {
+		h, err = c.Client.HeaderByHash(ctx, hash)
+		return err
+	})
+	return h, err
+}
+
+// FilterLogs runs eth_getLogs
+func (c *Client) FilterLogs(ctx context.Context, q ethereum.FilterQuery) ([]types.Log, error) {
+	var logs []types.Log
+	err := c.do(ctx, "eth_getLogs", func(ctx context.Context) (err error) {
+		logs, err = c.Client.FilterLogs(ctx, q)
+		return err
+	})
+	return logs, err
+}
+
+// BatchCallContext sends elems as one JSON-RPC batch. Only a failure of
+// the whole request is retried; errors of single elements are left in
+// elems for the caller.
+func (c *Client) BatchCallContext(ctx context.Context, elems []rpc.BatchElem) error {
+	method := "batch"
+	if len(elems) > 0 {
+		method = elems[0].Method
+	}
+	return c.do(ctx, method, func(ctx context.Context) error {
+		return c.Client.Client().BatchCallContext(ctx, elems)
+	})
 }
@@ -0,0 +1,579 @@
+// Package ethtest provides a fake Ethereum JSON-RPC node for tests. It
+// serves a scripted chain over HTTP, so eth.Dial can point at it, and can
+// reorg that chain or fail calls with JSON-RPC errors and HTTP statuses
+// such as 429 between or during calls.
+//
+// Blocks carry no transactions; logs reference made-up transaction hashes.
+// That is all the indexer reads.
+package ethtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// GenesisTime is the timestamp of block 0; every block adds 12 seconds.
+const GenesisTime = 1700000000
+
+// JSON-RPC error codes returned by the server
+const (
+	CodeMethodNotFound = -32601
+	CodeInvalidParams  = -32602
+	CodeServerError    = -32000
+)
+
+// Fault replaces the result of matching calls.
+type Fault struct {
+	Method string // method to fail; empty matches every method
+	Times  int    // number of calls to fail; zero fails one
+
+	// Status fails the whole HTTP request with this status, such as 429 or
+	// 503. Zero fails just the call with a JSON-RPC error instead.
+	Status     int
+	RetryAfter time.Duration // sent as Retry-After with Status
+
+	Code    int    // JSON-RPC error code, CodeServerError if zero
+	Message string // error message, a generic one if empty
+}
+
+// Server is a fake JSON-RPC node serving a single chain. All methods are
+// safe to call while the server handles requests.
+type Server struct {
+	URL string
+	srv *httptest.Server
+
+	mu        sync.Mutex
+	chain     []*types.Header               // canonical, by number
+	blocks    map[common.Hash]*types.Header // every block mined, orphans included
+	logs      map[common.Hash][]types.Log   // block hash -> logs
+	safe      *uint64                       // nil follows the head
+	finalized *uint64
+	fork      byte // bumped by every reorg so replacement blocks hash differently
+	faults    []*Fault
+	maxLogs   int    // eth_getLogs result limit, zero for none
+	maxRange  uint64 // eth_getLogs block range limit, zero for none
+	calls     map[string]int
+	batches   int
+	onCall    func(method string)
+}
+
+// NewServer starts a server with only the genesis block. It is closed
+// when the test ends.
+func NewServer(t testing.TB) *Server {
+	s := &Server{
+		blocks: make(map[common.Hash]*types.Header),
+		logs:   make(map[common.Hash][]types.Log),
+		calls:  make(map[string]int),
+	}
+	s.mine(nil)
+
+	s.srv = httptest.NewServer(s)
+	s.URL = s.srv.URL
+	t.Cleanup(s.srv.Close)
+	return s
+}
+
+// Mine appends a block holding logs to the head and returns its header.
+// Block number, block hash, log index and, if unset, tx hash are filled in.
+func (s *Server) Mine(logs ...types.Log) *types.Header {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.mine(logs)
+}
+
+// MineEmpty appends n blocks without logs
+func (s *Server) MineEmpty(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for range n {
+		s.mine(nil)
+	}
+}
+
+func (s *Server) mine(logs []types.Log) *types.Header {
+	number := uint64(len(s.chain))
+	h := &types.Header{
+		UncleHash:   types.EmptyUncleHash,
+		TxHash:      types.EmptyTxsHash,
+		ReceiptHash: types.EmptyReceiptsHash,
+		Difficulty:  new(big.Int),
+		Number:      new(big.Int).SetUint64(number),
+		GasLimit:    30_000_000,
+		Time:        GenesisTime + number*12,
+		Extra:       []byte{s.fork},
+	}
+	if number > 0 {
+		h.ParentHash = s.chain[number-1].Hash()
+	}
+	hash := h.Hash()
+
+	stored := make([]types.Log, len(logs))
+	for i, lg := range logs {
+		lg.BlockNumber = number
+		lg.BlockHash = hash
+		lg.Index = uint(i)
+		lg.TxIndex = uint(i)
+		if lg.TxHash == (common.Hash{}) {
+			lg.TxHash = crypto.Keccak256Hash(hash[:], []byte{byte(i)})
+		}
+		stored[i] = lg
+	}
+
+	s.chain = append(s.chain, h)
+	s.blocks[hash] = h
+	s.logs[hash] = stored
+	return h
+}
+
+// Reorg drops the newest depth blocks. Blocks mined afterwards hash
+// differently from the dropped ones at the same height. The dropped blocks
+// stay retrievable by hash, like on a real node.
+func (s *Server) Reorg(depth int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if depth >= len(s.chain) {
+		panic("ethtest: cannot reorg the genesis block")
+	}
+	s.chain = s.chain[:len(s.chain)-depth]
+	s.fork++
+}
+
+// SetHead makes a block mined earlier, orphaned or not, the canonical head
+// again, with its ancestors, like a node switching back to a fork.
+func (s *Server) SetHead(hash common.Hash) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var fork []*types.Header
+	h, ok := s.blocks[hash]
+	for ok {
+		number := h.Number.Uint64()
+		if number < uint64(len(s.chain)) && s.chain[number].Hash() == h.Hash() {
+			break
+		}
+		fork = append(fork, h)
+		h, ok = s.blocks[h.ParentHash]
+	}
+	if !ok {
+		panic("ethtest: unknown block " + hash.Hex())
+	}
+
+	s.chain = s.chain[:h.Number.Uint64()+1]
+	for k := len(fork) - 1; k >= 0; k-- {
+		s.chain = append(s.chain, fork[k])
+	}
+	s.fork++
+}
+
+// Head returns the canonical head
+func (s *Server) Head() *types.Header {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return types.CopyHeader(s.chain[len(s.chain)-1])
+}
+
+// Block returns the canonical block at number and its logs, or nil past
+// the head.
+func (s *Server) Block(number uint64) (*types.Header, []types.Log) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if number >= uint64(len(s.chain)) {
+		return nil, nil
+	}
+	h := s.chain[number]
+	return types.CopyHeader(h), append([]types.Log(nil), s.logs[h.Hash()]...)
+}
+
+// SetSafe pins the "safe" tag to number instead of the head
+func (s *Server) SetSafe(number uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.safe = &number
+}
+
+// SetFinalized pins the "finalized" tag to number instead of the head
+func (s *Server) SetFinalized(number uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.finalized = &number
+}
+
+// Inject queues a fault. Faults are matched in the order they were added.
+func (s *Server) Inject(f Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if f.Times <= 0 {
+		f.Times = 1
+	}
+	s.faults = append(s.faults, &f)
+}
+
+// LimitLogs makes eth_getLogs fail like a public provider when a query
+// returns more than results logs or spans more than blocks blocks. Zero
+// lifts a limit.
+func (s *Server) LimitLogs(results int, blocks uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.maxLogs = results
+	s.maxRange = blocks
+}
+
+// OnCall registers fn to run before each call is handled, outside the
+// server's lock, so it may reorg the chain or inject faults mid-sync.
+func (s *Server) OnCall(fn func(method string)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onCall = fn
+}
+
+// Calls returns how many times method was called, batched calls included
+func (s *Server) Calls(method string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls[method]
+}
+
+// Batches returns how many batch requests were received
+func (s *Server) Batches() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.batches
+}
+
+type request struct {
+	ID     json.RawMessage   `json:"id"`
+	Method string            `json:"method"`
+	Params []json.RawMessage `json:"params"`
+}
+
+type response struct {
+	Version string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string { return e.Message }
+
+// ServeHTTP handles single and batched JSON-RPC requests
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	body = bytes.TrimSpace(body)
+	batch := len(body) > 0 && body[0] == '['
+
+	var reqs []request
+	if batch {
+		err = json.Unmarshal(body, &reqs)
+	} else {
+		reqs = make([]request, 1)
+		err = json.Unmarshal(body, &reqs[0])
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if batch {
+		s.mu.Lock()
+		s.batches++
+		s.mu.Unlock()
+	}
+
+	resps := make([]response, len(reqs))
+	for i, req := range reqs {
+		s.mu.Lock()
+		s.calls[req.Method]++
+		hook := s.onCall
+		s.mu.Unlock()
+		if hook != nil {
+			hook(req.Method)
+		}
+
+		resps[i] = response{Version: "2.0", ID: req.ID}
+
+		s.mu.Lock()
+		fault := s.takeFault(req.Method)
+		if fault == nil {
+			resps[i].Result, err = s.handle(req.Method, req.Params)
+		}
+		s.mu.Unlock()
+
+		switch {
+		case fault != nil && fault.Status != 0:
+			if fault.RetryAfter > 0 {
+				w.Header().Set("Retry-After", strconv.Itoa(int(fault.RetryAfter.Seconds())))
+			}
+			http.Error(w, fault.message(), fault.Status)
+			return
+		case fault != nil:
+			code := fault.Code
+			if code == 0 {
+				code = CodeServerError
+			}
+			resps[i].Error = &rpcError{Code: code, Message: fault.message()}
+		case err != nil:
+			var rerr *rpcError
+			if !errors.As(err, &rerr) {
+				rerr = &rpcError{Code: CodeServerError, Message: err.Error()}
+			}
+			resps[i].Error = rerr
+		case resps[i].Result == nil:
+			resps[i].Result = json.RawMessage("null")
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if batch {
+		_ = json.NewEncoder(w).Encode(resps)
+	} else {
+		_ = json.NewEncoder(w).Encode(resps[0])
+	}
+}
+
+// takeFault consumes one use of the first fault matching method
+func (s *Server) takeFault(method string) *Fault {
+	for i, f := range s.faults {
+		if f.Method != "" && f.Method != method {
+			continue
+		}
+		f.Times--
+		if f.Times == 0 {
+			s.faults = append(s.faults[:i], s.faults[i+1:]...)
+		}
+		return f
+	}
+	return nil
+}
+
+func (f *Fault) message() string {
+	if f.Message != "" {
+		return f.Message
+	}
+	return "injected fault"
+}
+
+func (s *Server) handle(method string, params []json.RawMessage) (any, error) {
+	switch method {
+	case "eth_chainId":
+		return hexutil.Uint64(11155111), nil
+
+	case "eth_blockNumber":
+		return hexutil.Uint64(len(s.chain) - 1), nil
+
+	case "eth_getBlockByNumber":
+		var number rpc.BlockNumber
+		if err := param(params, 0, &number); err != nil {
+			return nil, err
+		}
+		n, ok := s.resolve(number)
+		if !ok {
+			return nil, nil
+		}
+		return blockJSON(s.chain[n])
+
+	case "eth_getBlockByHash":
+		var hash common.Hash
+		if err := param(params, 0, &hash); err != nil {
+			return nil, err
+		}
+		h, ok := s.blocks[hash]
+		if !ok {
+			return nil, nil
+		}
+		return blockJSON(h)
+
+	case "eth_getLogs":
+		var filter filterArg
+		if err := param(params, 0, &filter); err != nil {
+			return nil, err
+		}
+		return s.getLogs(filter)
+
+	default:
+		return nil, &rpcError{Code: CodeMethodNotFound, Message: fmt.Sprintf("the method %s does not exist/is not available", method)}
+	}
+}
+
+// resolve maps a block number or tag to a canonical height
+func (s *Server) resolve(number rpc.BlockNumber) (uint64, bool) {
+	head := uint64(len(s.chain) - 1)
+	switch number {
+	case rpc.LatestBlockNumber, rpc.PendingBlockNumber:
+		return head, true
+	case rpc.SafeBlockNumber:
+		return min(pinned(s.safe, head), head), true
+	case rpc.FinalizedBlockNumber:
+		return min(pinned(s.finalized, head), head), true
+	case rpc.EarliestBlockNumber:
+		return 0, true
+	}
+	if number < 0 || uint64(number) > head {
+		return 0, false
+	}
+	return uint64(number), true
+}
+
+func pinned(n *uint64, head uint64) uint64 {
+	if n == nil {
+		return head
+	}
+	return *n
+}
+
+type filterArg struct {
+	BlockHash *common.Hash      `json:"blockHash"`
+	FromBlock *rpc.BlockNumber  `json:"fromBlock"`
+	ToBlock   *rpc.BlockNumber  `json:"toBlock"`
+	Address   json.RawMessage   `json:"address"`
+	Topics    []json.RawMessage `json:"topics"`
+}
+
+func (s *Server) getLogs(f filterArg) ([]types.Log, error) {
+	addresses, err := hashSet[common.Address](f.Address)
+	if err != nil {
+		return nil, &rpcError{Code: CodeInvalidParams, Message: "invalid address: " + err.Error()}
+	}
+	topics := make([]map[common.Hash]bool, len(f.Topics))
+	for i, raw := range f.Topics {
+		if topics[i], err = hashSet[common.Hash](raw); err != nil {
+			return nil, &rpcError{Code: CodeInvalidParams, Message: "invalid topics: " + err.Error()}
+		}
+	}
+
+	var headers []*types.Header
+	if f.BlockHash != nil {
+		h, ok := s.blocks[*f.BlockHash]
+		if !ok {
+			return nil, &rpcError{Code: CodeServerError, Message: "unknown block"}
+		}
+		headers = append(headers, h)
+	} else {
+		from, to := rpc.LatestBlockNumber, rpc.LatestBlockNumber
+		if f.FromBlock != nil {
+			from = *f.FromBlock
+		}
+		if f.ToBlock != nil {
+			to = *f.ToBlock
+		}
+		lo, ok := s.resolve(from)
+		if !ok {
+			return []types.Log{}, nil
+		}
+		hi, ok := s.resolve(to)
+		if !ok {
+			hi = uint64(len(s.chain) - 1)
+		}
+		if lo > hi {
+			return nil, &rpcError{Code: CodeInvalidParams, Message: "invalid block range params"}
+		}
+		if s.maxRange > 0 && hi-lo+1 > s.maxRange {
+			return nil, &rpcError{Code: CodeServerError, Message: "block range too large"}
+		}
+		headers = s.chain[lo : hi+1]
+	}
+
+	logs := []types.Log{}
+	for _, h := range headers {
+		for _, lg := range s.logs[h.Hash()] {
+			if matches(lg, addresses, topics) {
+				logs = append(logs, lg)
+			}
+		}
+	}
+	if s.maxLogs > 0 && len(logs) > s.maxLogs {
+		return nil, &rpcError{Code: CodeServerError, Message: fmt.Sprintf("query returned more than %d results", s.maxLogs)}
+	}
+	return logs, nil
+}
+
+func matches(lg types.Log, addresses map[common.Address]bool, topics []map[common.Hash]bool) bool {
+	if len(addresses) > 0 && !addresses[lg.Address] {
+		return false
+	}
+	for i, set := range topics {
+		if len(set) == 0 {
+			continue
+		}
+		if i >= len(lg.Topics) || !set[lg.Topics[i]] {
+			return false
+		}
+	}
+	return true
+}
+
+// hashSet decodes a filter criterion: null, one value or a list of values
+func hashSet[T comparable](raw json.RawMessage) (map[T]bool, error) {
+	set := make(map[T]bool)
+	if len(raw) == 0 || string(raw) == "null" {
+		return set, nil
+	}
+
+	var list []T
+	if raw[0] != '[' {
+		var one T
+		if err := json.Unmarshal(raw, &one); err != nil {
+			return nil, err
+		}
+		list = append(list, one)
+	} else if err := json.Unmarshal(raw, &list); err != nil {
+		return nil, err
+	}
+
+	for _, v := range list {
+		set[v] = true
+	}
+	return set, nil
+}
+
+func param(params []json.RawMessage, i int, v any) error {
+	if i >= len(params) {
+		return &rpcError{Code: CodeInvalidParams, Message: fmt.Sprintf("missing value for required argument %d", i)}
+	}
+	if err := json.Unmarshal(params[i], v); err != nil {
+		return &rpcError{Code: CodeInvalidParams, Message: fmt.Sprintf("invalid argument %d: %v", i, err)}
+	}
+	return nil
+}
+
+// blockJSON renders a header as a block without transactions or uncles
+func blockJSON(h *types.Header) (any, error) {
+	data, err := json.Marshal(h)
+	if err != nil {
+		return nil, err
+	}
+	var block map[string]any
+	if err := json.Unmarshal(data, &block); err != nil {
+		return nil, err
+	}
+	block["transactions"] = []any{}
+	block["uncles"] = []any{}
+	return block, nil
+}
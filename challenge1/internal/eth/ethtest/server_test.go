@@ -0,0 +1,89 @@
+package ethtest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+func TestServer_Batch(t *testing.T) {
+	srv := NewServer(t)
+	srv.MineEmpty(10)
+	srv.SetFinalized(4)
+
+	c, err := rpc.DialContext(context.Background(), srv.URL)
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer c.Close()
+
+	var head hexutil.Uint64
+	var finalized, missing *types.Header
+	batch := []rpc.BatchElem{
+		{Method: "eth_blockNumber", Result: &head},
+		{Method: "eth_getBlockByNumber", Args: []any{"finalized", false}, Result: &finalized},
+		{Method: "eth_getBlockByNumber", Args: []any{"0x20", false}, Result: &missing},
+		{Method: "eth_sendRawTransaction", Args: []any{"0x"}},
+	}
+	if err := c.BatchCallContext(context.Background(), batch); err != nil {
+		t.Fatalf("Batch failed: %v", err)
+	}
+
+	if head != 10 {
+		t.Errorf("head mismatch: got %d, want 10", head)
+	}
+	if finalized == nil || finalized.Number.Uint64() != 4 || finalized.Hash() != srv.chain[4].Hash() {
+		t.Errorf("finalized block mismatch: got %+v", finalized)
+	}
+	if missing != nil {
+		t.Errorf("block past the head: got %+v, want null", missing)
+	}
+	var rerr rpc.Error
+	if !errors.As(batch[3].Error, &rerr) || rerr.ErrorCode() != CodeMethodNotFound {
+		t.Errorf("unknown method error mismatch: got %v", batch[3].Error)
+	}
+	if got := srv.Calls("eth_getBlockByNumber"); got != 2 {
+		t.Errorf("call count mismatch: got %d, want 2", got)
+	}
+	if got := srv.Batches(); got != 1 {
+		t.Errorf("batch count mismatch: got %d, want 1", got)
+	}
+}
+
+func TestServer_Faults(t *testing.T) {
+	srv := NewServer(t)
+	srv.Inject(Fault{Method: "eth_blockNumber", Times: 2, Code: -32005, Message: "limit exceeded"})
+	srv.Inject(Fault{Status: http.StatusTooManyRequests, RetryAfter: 2 * time.Second})
+
+	c, err := rpc.DialContext(context.Background(), srv.URL)
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer c.Close()
+
+	var head hexutil.Uint64
+	for range 2 {
+		err := c.CallContext(context.Background(), &head, "eth_blockNumber")
+		var rerr rpc.Error
+		if !errors.As(err, &rerr) || rerr.ErrorCode() != -32005 || err.Error() != "limit exceeded" {
+			t.Errorf("JSON-RPC fault mismatch: got %v", err)
+		}
+	}
+
+	err = c.CallContext(context.Background(), &head, "eth_blockNumber")
+	var herr rpc.HTTPError
+	if !errors.As(err, &herr) || herr.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("HTTP fault mismatch: got %v", err)
+	}
+
+	// faults are used up
+	if err := c.CallContext(context.Background(), &head, "eth_blockNumber"); err != nil {
+		t.Errorf("call after faults: %v", err)
+	}
+}
@@ -0,0 +1,77 @@
+package eth
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// l1InfoTreeABI describes the event emitted by the global exit root manager
+// on every L1 info tree update. Both roots are indexed, so they travel in
+// the topics and the log data is empty.
+const l1InfoTreeABI = `[{
+	"type": "event",
+	"name": "UpdateL1InfoTree",
+	"anonymous": false,
+	"inputs": [
+		{"name": "mainnetExitRoot", "type": "bytes32", "indexed": true},
+		{"name": "rollupExitRoot", "type": "bytes32", "indexed": true}
+	]
+}]`
+
+var updateL1InfoTree = mustParseEvent(l1InfoTreeABI, "UpdateL1InfoTree")
+
+// UpdateL1InfoTree is a decoded UpdateL1InfoTree log.
+type UpdateL1InfoTree struct {
+	MainnetExitRoot common.Hash
+	RollupExitRoot  common.Hash
+}
+
+// DecodeUpdateL1InfoTree decodes a log using the event ABI: indexed
+// arguments from Topics[1:], the rest from Data. The signature topic is not
+// checked against the ABI since the indexer filters on the configured topic.
+func DecodeUpdateL1InfoTree(lg types.Log) (*UpdateL1InfoTree, error) {
+	if len(lg.Topics) == 0 {
+		return nil, errors.New("log has no topics")
+	}
+
+	var indexed abi.Arguments
+	for _, arg := range updateL1InfoTree.Inputs {
+		if arg.Indexed {
+			indexed = append(indexed, arg)
+		}
+	}
+
+	var out UpdateL1InfoTree
+	if err := abi.ParseTopics(&out, indexed, lg.Topics[1:]); err != nil {
+		return nil, fmt.Errorf("decode %s topics: %w", updateL1InfoTree.Name, err)
+	}
+
+	if nonIndexed := updateL1InfoTree.Inputs.NonIndexed(); len(nonIndexed) > 0 {
+		values, err := nonIndexed.Unpack(lg.Data)
+		if err != nil {
+			return nil, fmt.Errorf("decode %s data: %w", updateL1InfoTree.Name, err)
+		}
+		if err := nonIndexed.Copy(&out, values); err != nil {
+			return nil, fmt.Errorf("decode %s data: %w", updateL1InfoTree.Name, err)
+		}
+	}
+
+	return &out, nil
+}
+
+func mustParseEvent(def, name string) abi.Event {
+	parsed, err := abi.JSON(strings.NewReader(def))
+	if err != nil {
+		panic(err)
+	}
+	ev, ok := parsed.Events[name]
+	if !ok {
+		panic("event " + name + " missing from ABI")
+	}
+	return ev
+}
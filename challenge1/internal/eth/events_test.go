@@ -0,0 +1,46 @@
+package eth
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+func TestDecodeUpdateL1InfoTree(t *testing.T) {
+	mainnet := common.HexToHash("0x1111111111111111111111111111111111111111111111111111111111111111")
+	rollup := common.HexToHash("0x2222222222222222222222222222222222222222222222222222222222222222")
+
+	lg := types.Log{
+		Topics: []common.Hash{
+			common.HexToHash("0x3e54d0825ed78523037d00a81759237eb436ce774bd546993ee67a1b67b6e766"),
+			mainnet,
+			rollup,
+		},
+	}
+
+	got, err := DecodeUpdateL1InfoTree(lg)
+	if err != nil {
+		t.Fatalf("Failed to decode log: %v", err)
+	}
+	if got.MainnetExitRoot != mainnet {
+		t.Errorf("MainnetExitRoot mismatch: got %s, want %s", got.MainnetExitRoot, mainnet)
+	}
+	if got.RollupExitRoot != rollup {
+		t.Errorf("RollupExitRoot mismatch: got %s, want %s", got.RollupExitRoot, rollup)
+	}
+}
+
+func TestDecodeUpdateL1InfoTree_MissingTopics(t *testing.T) {
+	// Only the signature and one root: the ABI expects two indexed roots
+	lg := types.Log{
+		Topics: []common.Hash{common.HexToHash("0x01"), common.HexToHash("0x02")},
+	}
+
+	if _, err := DecodeUpdateL1InfoTree(lg); err == nil {
+		t.Error("Expected error for log with missing topics")
+	}
+	if _, err := DecodeUpdateL1InfoTree(types.Log{}); err == nil {
+		t.Error("Expected error for log without topics")
+	}
+}
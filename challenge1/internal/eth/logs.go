@@ -2,21 +2,46 @@ package eth
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"log"
 	"math/big"
+	"strings"
 
 	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
 )
 
+// rangeLimitErrors are fragments of the errors providers return when an
+// eth_getLogs query spans too many blocks or matches too many logs. They
+// stay specific: a bare "block range" would also match "invalid block
+// range", which no split can fix.
+var rangeLimitErrors = []string{
+	"query returned more than",   // geth, Infura
+	"block range too large",      // Nethermind, public RPCs
+	"exceed maximum block range", // Alchemy, QuickNode
+	"response size exceeded",     // Alchemy
+	"range is too large",         // Ankr
+	"query exceeds max results",  // Erigon
+}
+
+// LogFetcher queries logs over a block range. Providers cap eth_getLogs
+// by block span or result count; when a query hits such a cap the fetcher
+// halves its window and retries, and after each success grows the window
+// back additively, so a dense stretch of blocks slows a backfill down
+// instead of aborting it.
 type LogFetcher struct {
 	client *Client
+	window uint64 // blocks per eth_getLogs call, 0 for the whole range
 }
 
 func NewLogFetcher(client *Client) *LogFetcher {
 	return &LogFetcher{client: client}
 }
 
+// Fetch returns the logs of fromBlock..toBlock in chain order. The window
+// learned by one call carries over to the next.
 func (f *LogFetcher) Fetch(
 	ctx context.Context,
 	contract common.Address,
@@ -25,6 +50,51 @@ func (f *LogFetcher) Fetch(
 	toBlock uint64,
 ) ([]types.Log, error) {
 
+	span := toBlock - fromBlock + 1
+	step := max(span/16, 1)
+
+	var logs []types.Log
+	for from := fromBlock; from <= toBlock; {
+		to := toBlock
+		if f.window != 0 && f.window < span && from+f.window-1 < toBlock {
+			to = from + f.window - 1
+		}
+
+		part, err := f.query(ctx, contract, topic, from, to)
+		if isRangeLimit(err) && to > from {
+			f.window = (to - from + 1) / 2
+			log.Printf("eth_getLogs %d -> %d hit a provider limit, retrying in windows of %d blocks", from, to, f.window)
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("fetch logs %d -> %d: %w", from, to, err)
+		}
+		logs = append(logs, part...)
+		from = to + 1
+
+		// additive increase, back to the full range once it is reached
+		if f.window != 0 {
+			if f.window += step; f.window >= span {
+				f.window = 0
+			}
+		}
+
+		// toBlock may be the last uint64
+		if to == toBlock {
+			break
+		}
+	}
+	return logs, nil
+}
+
+func (f *LogFetcher) query(
+	ctx context.Context,
+	contract common.Address,
+	topic common.Hash,
+	fromBlock uint64,
+	toBlock uint64,
+) ([]types.Log, error) {
+
 	query := ethereum.FilterQuery{
 		FromBlock: uint64ToBig(fromBlock),
 		ToBlock:   uint64ToBig(toBlock),
@@ -35,6 +105,21 @@ func (f *LogFetcher) Fetch(
 	return f.client.FilterLogs(ctx, query)
 }
 
+// isRangeLimit reports whether err is a provider refusing a log query as
+// too large, as opposed to a failure that splitting would not fix
+func isRangeLimit(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	msg := strings.ToLower(err.Error())
+	for _, s := range rangeLimitErrors {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+	return false
+}
+
 // helper
 func uint64ToBig(v uint64) *big.Int {
 	b := new(big.Int)
@@ -0,0 +1,165 @@
+package eth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/zacksfF/sepolia-sh/ch1/internal/eth/ethtest"
+)
+
+var (
+	testContract = common.HexToAddress("0x2968d6d736178f8fe7393cc33c87f29d9c287e78")
+	testTopic    = common.HexToHash("0x3e54d0825ed78523037d00a81759237eb436ce774bd546993ee67a1b67b6e766")
+)
+
+// newLogServer mines blocks 1..n with perBlock matching logs each
+func newLogServer(t *testing.T, n, perBlock int) (*ethtest.Server, *LogFetcher) {
+	t.Helper()
+	srv := ethtest.NewServer(t)
+	for b := 1; b <= n; b++ {
+		logs := make([]types.Log, perBlock)
+		for k := range logs {
+			logs[k] = types.Log{
+				Address: testContract,
+				Topics:  []common.Hash{testTopic, common.BigToHash(big.NewInt(int64(b<<8 | k)))},
+			}
+		}
+		srv.Mine(logs...)
+	}
+
+	client, err := Dial(context.Background(), srv.URL, RetryPolicy{})
+	if err != nil {
+		t.Fatalf("Failed to dial fake node: %v", err)
+	}
+	t.Cleanup(client.Close)
+	return srv, NewLogFetcher(client)
+}
+
+// checkLogs verifies logs are every log of blocks from..to, in chain order
+func checkLogs(t *testing.T, srv *ethtest.Server, logs []types.Log, from, to uint64) {
+	t.Helper()
+	var want []types.Log
+	for b := from; b <= to; b++ {
+		_, blockLogs := srv.Block(b)
+		want = append(want, blockLogs...)
+	}
+	if len(logs) != len(want) {
+		t.Fatalf("log count mismatch: got %d, want %d", len(logs), len(want))
+	}
+	for k := range logs {
+		if logs[k].BlockHash != want[k].BlockHash || logs[k].Index != want[k].Index {
+			t.Errorf("log %d mismatch: got %d/%d, want %d/%d", k, logs[k].BlockNumber, logs[k].Index, want[k].BlockNumber, want[k].Index)
+		}
+	}
+}
+
+func TestLogFetcher_ResultLimit(t *testing.T) {
+	srv, f := newLogServer(t, 40, 2)
+	srv.LimitLogs(10, 0)
+
+	logs, err := f.Fetch(context.Background(), testContract, testTopic, 0, 40)
+	if err != nil {
+		t.Fatalf("Fetch failed: %v", err)
+	}
+	checkLogs(t, srv, logs, 0, 40)
+	// the last query fit, so the window is growing again but not yet whole
+	if f.window == 0 || f.window >= 41 {
+		t.Errorf("window mismatch: got %d, want 1..40", f.window)
+	}
+}
+
+func TestLogFetcher_RangeLimit(t *testing.T) {
+	ctx := context.Background()
+	srv, f := newLogServer(t, 99, 1)
+	srv.LimitLogs(0, 10)
+
+	logs, err := f.Fetch(ctx, testContract, testTopic, 0, 99)
+	if err != nil {
+		t.Fatalf("Fetch failed: %v", err)
+	}
+	checkLogs(t, srv, logs, 0, 99)
+
+	// the window grows back once the limit goes away
+	srv.LimitLogs(0, 0)
+	for n := 0; f.window != 0; n++ {
+		if n == 20 {
+			t.Fatalf("window did not grow back: still %d", f.window)
+		}
+		if _, err := f.Fetch(ctx, testContract, testTopic, 0, 99); err != nil {
+			t.Fatalf("Fetch failed: %v", err)
+		}
+	}
+	before := srv.Calls("eth_getLogs")
+	if _, err := f.Fetch(ctx, testContract, testTopic, 0, 99); err != nil {
+		t.Fatalf("Fetch failed: %v", err)
+	}
+	if got := srv.Calls("eth_getLogs") - before; got != 1 {
+		t.Errorf("eth_getLogs calls mismatch: got %d, want 1", got)
+	}
+}
+
+func TestLogFetcher_SingleBlockOverLimit(t *testing.T) {
+	srv, f := newLogServer(t, 4, 3)
+	srv.LimitLogs(2, 0)
+
+	// one block can't be split any further
+	_, err := f.Fetch(context.Background(), testContract, testTopic, 0, 4)
+	if !isRangeLimit(err) {
+		t.Fatalf("Fetch error mismatch: got %v, want a result limit error", err)
+	}
+}
+
+func TestLogFetcher_OtherErrors(t *testing.T) {
+	srv, f := newLogServer(t, 10, 1)
+	srv.Inject(ethtest.Fault{Method: "eth_getLogs", Message: "query timeout exceeded"})
+
+	// failures splitting can't fix are returned as is
+	if _, err := f.Fetch(context.Background(), testContract, testTopic, 0, 10); err == nil {
+		t.Fatal("Fetch succeeded, want the injected error")
+	}
+	if got := srv.Calls("eth_getLogs"); got != 1 {
+		t.Errorf("eth_getLogs calls mismatch: got %d, want 1", got)
+	}
+}
+
+func TestLogFetcher_InvalidRange(t *testing.T) {
+	srv, f := newLogServer(t, 10, 1)
+	srv.Inject(ethtest.Fault{Method: "eth_getLogs", Code: ethtest.CodeInvalidParams, Message: "invalid block range params"})
+
+	// mentions a block range, but is no provider limit
+	if _, err := f.Fetch(context.Background(), testContract, testTopic, 0, 10); err == nil || isRangeLimit(err) {
+		t.Fatalf("Fetch error mismatch: got %v, want the invalid range error", err)
+	}
+	if got := srv.Calls("eth_getLogs"); got != 1 {
+		t.Errorf("eth_getLogs calls mismatch: got %d, want 1", got)
+	}
+}
+
+func TestIsRangeLimit(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{errors.New("query returned more than 10000 results"), true},
+		{errors.New("block range too large"), true},
+		{errors.New("exceed maximum block range: 5000"), true},
+		{errors.New("Log response size exceeded. You can make eth_getLogs requests with up to a 2K block range"), true},
+		{fmt.Errorf("fetch logs 1 -> 2: %w", errors.New("query returned more than 10000 results")), true},
+		{errors.New("query timeout exceeded"), false},
+		{errors.New("invalid block range params"), false},
+		{errors.New("429 Too Many Requests"), false},
+		{context.DeadlineExceeded, false},
+		{nil, false},
+	}
+
+	for _, tt := range tests {
+		if got := isRangeLimit(tt.err); got != tt.want {
+			t.Errorf("isRangeLimit(%v) mismatch: got %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,248 @@
+package eth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"maps"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/zacksfF/sepolia-sh/ch1/internal/metrics"
+)
+
+// RetryPolicy bounds how a failed call is retried. Delays grow
+// exponentially from BaseDelay up to MaxDelay, each one drawn at random
+// from its upper half so clients that failed together don't retry together.
+// A Retry-After sent by the node replaces a shorter delay. It may exceed
+// MaxDelay as long as it fits the Budget; without a Budget it is capped
+// at MaxDelay like any other delay.
+type RetryPolicy struct {
+	MaxRetries int           // retries per call after the first attempt
+	BaseDelay  time.Duration // delay before the first retry
+	MaxDelay   time.Duration // cap on a single delay, Retry-After within Budget excepted
+	Budget     time.Duration // total time a call may spend waiting, 0 for no limit
+}
+
+// DefaultRetryPolicy rides out the short outages public endpoints have
+// every few minutes without hiding one that lasts.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxRetries: 5,
+	BaseDelay:  500 * time.Millisecond,
+	MaxDelay:   30 * time.Second,
+	Budget:     2 * time.Minute,
+}
+
+// RetryStats counts the retries of one JSON-RPC method
+type RetryStats struct {
+	Retries   uint64 // failed attempts that were retried
+	Exhausted uint64 // calls that failed after using up their retries or budget
+}
+
+// RetryStats returns the retry counts so far, by JSON-RPC method
+func (c *Client) RetryStats() map[string]RetryStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return maps.Clone(c.stats)
+}
+
+// do runs call until it succeeds, fails with an error that retrying won't
+// fix, or runs out of retries or budget
+func (c *Client) do(ctx context.Context, method string, call func(context.Context) error) error {
+	var waited time.Duration
+	for attempt := 0; ; attempt++ {
+		hint := new(retryHint)
+		start := time.Now()
+		err := call(context.WithValue(ctx, retryHintKey{}, hint))
+		metrics.RPCRequests.WithLabelValues(method).Inc()
+		metrics.RPCDuration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+		if err != nil && ctx.Err() == nil {
+			metrics.RPCErrors.WithLabelValues(method, errorType(err)).Inc()
+		}
+		if err == nil || ctx.Err() != nil || !IsRetryable(err) {
+			return err
+		}
+
+		delay := c.retry.delay(attempt, time.Duration(hint.after.Load()))
+		if attempt >= c.retry.MaxRetries || (c.retry.Budget > 0 && waited+delay > c.retry.Budget) {
+			c.count(method, func(s *RetryStats) { s.Exhausted++ })
+			if attempt == 0 {
+				return err
+			}
+			return fmt.Errorf("%s failed after %d retries: %w", method, attempt, err)
+		}
+
+		c.count(method, func(s *RetryStats) { s.Retries++ })
+		metrics.RPCRetries.WithLabelValues(method).Inc()
+		log.Printf("%s failed (attempt %d), retrying in %v: %v", method, attempt+1, delay.Round(time.Millisecond), err)
+
+		t := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		case <-t.C:
+		}
+		waited += delay
+	}
+}
+
+func (c *Client) count(method string, fn func(*RetryStats)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s := c.stats[method]
+	fn(&s)
+	c.stats[method] = s
+}
+
+// delay returns the wait before retry number attempt+1, given the node's
+// Retry-After, if any. Without a Budget to bound it, Retry-After is capped
+// at MaxDelay.
+func (p RetryPolicy) delay(attempt int, retryAfter time.Duration) time.Duration {
+	if p.Budget <= 0 && p.MaxDelay > 0 {
+		retryAfter = min(retryAfter, p.MaxDelay)
+	}
+	return max(p.backoff(attempt), retryAfter)
+}
+
+// backoff returns the jittered delay before retry number attempt+1
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	if p.BaseDelay <= 0 {
+		return 0
+	}
+	d := p.BaseDelay << min(attempt, 30)
+	if p.MaxDelay > 0 && (d > p.MaxDelay || d <= 0) {
+		d = p.MaxDelay
+	}
+	return d/2 + rand.N(d/2+1)
+}
+
+// IsRetryable reports whether err is a transient failure worth retrying:
+// a timeout, a dropped connection, HTTP 408, 429 or 5xx, a JSON-RPC
+// internal error or rate limit (-32005), or a server error (-32000..-32099)
+// whose message says it is transient. Other server errors, like geth's
+// "header not found" and "missing trie node", malformed requests, reverts
+// and log queries over a provider limit are final; retrying returns the same.
+func IsRetryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || isRangeLimit(err) {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+
+	var herr rpc.HTTPError
+	if errors.As(err, &herr) {
+		switch {
+		case herr.StatusCode == http.StatusRequestTimeout, herr.StatusCode == http.StatusTooManyRequests:
+			return true
+		default:
+			return herr.StatusCode >= 500
+		}
+	}
+
+	var rerr rpc.Error
+	if errors.As(err, &rerr) {
+		// -32603 internal error, -32005 limit exceeded; the rest of
+		// -32000..-32099 is implementation defined, so go by the message
+		switch code := rerr.ErrorCode(); {
+		case code == -32603, code == -32005:
+			return true
+		case code <= -32000 && code >= -32099:
+			return isTransientMessage(err.Error())
+		default:
+			return false
+		}
+	}
+
+	var nerr net.Error
+	return errors.As(err, &nerr)
+}
+
+// transientMessages are phrases nodes use in server errors that go away by
+// themselves
+var transientMessages = []string{
+	"timeout", "timed out", "rate limit", "too many requests", "try again",
+	"temporarily", "busy", "overloaded", "unavailable",
+}
+
+func isTransientMessage(msg string) bool {
+	msg = strings.ToLower(msg)
+	for _, m := range transientMessages {
+		if strings.Contains(msg, m) {
+			return true
+		}
+	}
+	return false
+}
+
+// errorType names the kind of a failed request for metrics
+func errorType(err error) string {
+	var herr rpc.HTTPError
+	var rerr rpc.Error
+	var nerr net.Error
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.As(err, &herr) && herr.StatusCode == http.StatusTooManyRequests:
+		return "rate_limit"
+	case errors.As(err, &herr):
+		return "http"
+	case errors.As(err, &rerr):
+		return "rpc"
+	case errors.As(err, &nerr) && nerr.Timeout():
+		return "timeout"
+	case errors.As(err, &nerr), errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return "network"
+	default:
+		return "other"
+	}
+}
+
+type retryHintKey struct{}
+
+// retryHint carries a Retry-After from the transport back to do
+type retryHint struct {
+	after atomic.Int64 // nanoseconds
+}
+
+// retryAfterTransport records the Retry-After header of responses in the
+// request's retryHint
+type retryAfterTransport struct {
+	base http.RoundTripper
+}
+
+func (t retryAfterTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	if hint, ok := req.Context().Value(retryHintKey{}).(*retryHint); ok {
+		if after, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+			hint.after.Store(int64(after))
+		}
+	}
+	return resp, nil
+}
+
+// parseRetryAfter accepts delay-seconds or an HTTP date
+func parseRetryAfter(v string) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		return time.Duration(max(secs, 0)) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return max(time.Until(t), 0), true
+	}
+	return 0, false
+}
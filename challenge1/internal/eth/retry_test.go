@@ -0,0 +1,228 @@
+package eth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/zacksfF/sepolia-sh/ch1/internal/eth/ethtest"
+	"github.com/zacksfF/sepolia-sh/ch1/internal/metrics"
+)
+
+// fastRetry retries quickly enough for tests
+var fastRetry = RetryPolicy{MaxRetries: 3, BaseDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond}
+
+func dialTest(t *testing.T, srv *ethtest.Server, retry RetryPolicy) *Client {
+	t.Helper()
+	client, err := Dial(context.Background(), srv.URL, retry)
+	if err != nil {
+		t.Fatalf("Failed to dial fake node: %v", err)
+	}
+	t.Cleanup(client.Close)
+	return client
+}
+
+func TestClient_RetriesTransient(t *testing.T) {
+	srv := ethtest.NewServer(t)
+	srv.MineEmpty(3)
+	srv.Inject(ethtest.Fault{Method: "eth_blockNumber", Status: http.StatusServiceUnavailable})
+	srv.Inject(ethtest.Fault{Method: "eth_blockNumber", Message: "request timed out"})
+	client := dialTest(t, srv, fastRetry)
+	requests := testutil.ToFloat64(metrics.RPCRequests.WithLabelValues("eth_blockNumber"))
+	httpErrors := testutil.ToFloat64(metrics.RPCErrors.WithLabelValues("eth_blockNumber", "http"))
+	rpcErrors := testutil.ToFloat64(metrics.RPCErrors.WithLabelValues("eth_blockNumber", "rpc"))
+
+	n, err := client.BlockNumber(context.Background())
+	if err != nil {
+		t.Fatalf("BlockNumber failed: %v", err)
+	}
+	if n != 3 {
+		t.Errorf("block number mismatch: got %d, want 3", n)
+	}
+	if got := srv.Calls("eth_blockNumber"); got != 3 {
+		t.Errorf("call count mismatch: got %d, want 3", got)
+	}
+	if got := client.RetryStats()["eth_blockNumber"]; got != (RetryStats{Retries: 2}) {
+		t.Errorf("retry stats mismatch: got %+v, want 2 retries", got)
+	}
+	if got := testutil.ToFloat64(metrics.RPCRequests.WithLabelValues("eth_blockNumber")) - requests; got != 3 {
+		t.Errorf("request metric mismatch: got %v, want 3", got)
+	}
+	if got := testutil.ToFloat64(metrics.RPCErrors.WithLabelValues("eth_blockNumber", "http")) - httpErrors; got != 1 {
+		t.Errorf("http error metric mismatch: got %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(metrics.RPCErrors.WithLabelValues("eth_blockNumber", "rpc")) - rpcErrors; got != 1 {
+		t.Errorf("rpc error metric mismatch: got %v, want 1", got)
+	}
+}
+
+func TestClient_FatalNotRetried(t *testing.T) {
+	srv := ethtest.NewServer(t)
+	srv.Inject(ethtest.Fault{Method: "eth_blockNumber", Code: ethtest.CodeInvalidParams, Message: "invalid params"})
+	client := dialTest(t, srv, fastRetry)
+
+	_, err := client.BlockNumber(context.Background())
+	var rerr rpc.Error
+	if !errors.As(err, &rerr) || rerr.ErrorCode() != ethtest.CodeInvalidParams {
+		t.Fatalf("BlockNumber error mismatch: got %v, want invalid params", err)
+	}
+	if got := srv.Calls("eth_blockNumber"); got != 1 {
+		t.Errorf("call count mismatch: got %d, want 1", got)
+	}
+	if got := len(client.RetryStats()); got != 0 {
+		t.Errorf("retry stats mismatch: got %d methods, want none", got)
+	}
+}
+
+func TestClient_RetriesExhausted(t *testing.T) {
+	srv := ethtest.NewServer(t)
+	srv.Inject(ethtest.Fault{Method: "eth_getLogs", Times: 10, Status: http.StatusBadGateway})
+	client := dialTest(t, srv, fastRetry)
+	f := NewLogFetcher(client)
+
+	// the last error is kept, so callers still see the HTTP status
+	_, err := f.Fetch(context.Background(), testContract, testTopic, 0, 0)
+	var herr rpc.HTTPError
+	if !errors.As(err, &herr) || herr.StatusCode != http.StatusBadGateway {
+		t.Fatalf("Fetch error mismatch: got %v, want HTTP 502", err)
+	}
+	if got := srv.Calls("eth_getLogs"); got != 4 {
+		t.Errorf("call count mismatch: got %d, want 4", got)
+	}
+	if got := client.RetryStats()["eth_getLogs"]; got != (RetryStats{Retries: 3, Exhausted: 1}) {
+		t.Errorf("retry stats mismatch: got %+v, want 3 retries, 1 exhausted", got)
+	}
+}
+
+func TestClient_RetryAfter(t *testing.T) {
+	srv := ethtest.NewServer(t)
+	srv.Inject(ethtest.Fault{Method: "eth_blockNumber", Status: http.StatusTooManyRequests, RetryAfter: time.Second})
+	retry := fastRetry
+	retry.Budget = 5 * time.Second
+	client := dialTest(t, srv, retry)
+
+	// within the budget, Retry-After beats MaxDelay
+
+	start := time.Now()
+	if _, err := client.BlockNumber(context.Background()); err != nil {
+		t.Fatalf("BlockNumber failed: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("Retry-After not honoured: retried after %v, want at least 1s", elapsed)
+	}
+}
+
+func TestClient_RetryBudget(t *testing.T) {
+	srv := ethtest.NewServer(t)
+	srv.Inject(ethtest.Fault{Method: "eth_blockNumber", Status: http.StatusTooManyRequests, RetryAfter: time.Minute})
+	retry := fastRetry
+	retry.Budget = 100 * time.Millisecond
+	client := dialTest(t, srv, retry)
+
+	// a Retry-After past the budget fails at once instead of waiting
+	start := time.Now()
+	if _, err := client.BlockNumber(context.Background()); err == nil {
+		t.Fatal("BlockNumber succeeded, want HTTP 429")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("waited %v, want no wait past the budget", elapsed)
+	}
+	if got := srv.Calls("eth_blockNumber"); got != 1 {
+		t.Errorf("call count mismatch: got %d, want 1", got)
+	}
+	if got := client.RetryStats()["eth_blockNumber"]; got != (RetryStats{Exhausted: 1}) {
+		t.Errorf("retry stats mismatch: got %+v, want 1 exhausted", got)
+	}
+}
+
+func TestClient_RetryAfterCapped(t *testing.T) {
+	srv := ethtest.NewServer(t)
+	srv.Inject(ethtest.Fault{Method: "eth_blockNumber", Status: http.StatusTooManyRequests, RetryAfter: time.Minute})
+	client := dialTest(t, srv, fastRetry)
+
+	// without a budget, Retry-After is capped at MaxDelay
+	start := time.Now()
+	if _, err := client.BlockNumber(context.Background()); err != nil {
+		t.Fatalf("BlockNumber failed: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("waited %v, want at most MaxDelay", elapsed)
+	}
+}
+
+func TestClient_RetryCancelled(t *testing.T) {
+	srv := ethtest.NewServer(t)
+	srv.Inject(ethtest.Fault{Method: "eth_blockNumber", Status: http.StatusServiceUnavailable, RetryAfter: time.Minute})
+	retry := fastRetry
+	retry.MaxDelay = time.Minute
+	client := dialTest(t, srv, retry)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := client.BlockNumber(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("BlockNumber error mismatch: got %v, want deadline exceeded", err)
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{rpc.HTTPError{StatusCode: http.StatusTooManyRequests}, true},
+		{rpc.HTTPError{StatusCode: http.StatusServiceUnavailable}, true},
+		{rpc.HTTPError{StatusCode: http.StatusRequestTimeout}, true},
+		{rpc.HTTPError{StatusCode: http.StatusBadRequest}, false},
+		{rpc.HTTPError{StatusCode: http.StatusUnauthorized}, false},
+		{fmt.Errorf("fetch: %w", rpc.HTTPError{StatusCode: http.StatusBadGateway}), true},
+		{&testRPCError{code: -32000, msg: "request timed out"}, true},
+		{&testRPCError{code: -32005, msg: "daily request count exceeded, request rate limited"}, true},
+		{&testRPCError{code: -32603, msg: "internal error"}, true},
+		{&testRPCError{code: -32000, msg: "header not found"}, false},
+		{&testRPCError{code: -32000, msg: "missing trie node 5a1b (path ) state 0x5a1b is not available"}, false},
+		{&testRPCError{code: -32000, msg: "server busy, try again later"}, true},
+		{&testRPCError{code: -32005, msg: "limit exceeded"}, true},
+		{&testRPCError{code: -32602, msg: "invalid params"}, false},
+		{&testRPCError{code: -32601, msg: "method not found"}, false},
+		{&testRPCError{code: 3, msg: "execution reverted"}, false},
+		{&testRPCError{code: -32005, msg: "query returned more than 10000 results"}, false},
+		{context.DeadlineExceeded, true},
+		{io.ErrUnexpectedEOF, true},
+		{context.Canceled, false},
+		{errors.New("not found"), false},
+		{nil, false},
+	}
+
+	for _, tt := range tests {
+		if got := IsRetryable(tt.err); got != tt.want {
+			t.Errorf("IsRetryable(%v) mismatch: got %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	p := RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+
+	for attempt, ceil := range []time.Duration{100, 200, 400, 800, 1000, 1000} {
+		ceil *= time.Millisecond
+		for range 20 {
+			if d := p.backoff(attempt); d < ceil/2 || d > ceil {
+				t.Errorf("backoff(%d) mismatch: got %v, want %v..%v", attempt, d, ceil/2, ceil)
+			}
+		}
+	}
+}
+
+type testRPCError struct {
+	code int
+	msg  string
+}
+
+func (e *testRPCError) Error() string  { return e.msg }
+func (e *testRPCError) ErrorCode() int { return e.code }
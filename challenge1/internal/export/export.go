@@ -0,0 +1,232 @@
+// Package export streams indexed events to CSV, JSON Lines or Parquet.
+package export
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/zacksfF/sepolia-sh/ch1/internal/model"
+)
+
+// Formats supported by NewWriter.
+const (
+	FormatCSV     = "csv"
+	FormatJSONL   = "jsonl"
+	FormatParquet = "parquet"
+)
+
+// parquetRowGroup bounds how many rows the Parquet writer buffers before
+// flushing a row group, which keeps memory flat on large exports.
+const parquetRowGroup = 64 * 1024
+
+// column is one exportable field. Hashes are rendered as 0x-prefixed hex.
+type column struct {
+	name   string
+	number func(e *model.IndexedEvent) uint64
+	hex    func(e *model.IndexedEvent) string
+}
+
+var allColumns = []column{
+	{name: "index", number: func(e *model.IndexedEvent) uint64 { return e.Index }},
+	{name: "blockNumber", number: func(e *model.IndexedEvent) uint64 { return e.BlockNumber }},
+	{name: "blockTime", number: func(e *model.IndexedEvent) uint64 { return e.BlockTime }},
+	{name: "parentHash", hex: func(e *model.IndexedEvent) string { return e.ParentHash.Hex() }},
+	{name: "txHash", hex: func(e *model.IndexedEvent) string { return e.TxHash.Hex() }},
+	{name: "logIndex", number: func(e *model.IndexedEvent) uint64 { return uint64(e.LogIndex) }},
+	{name: "mainnetExitRoot", hex: func(e *model.IndexedEvent) string { return e.MainnetExitRoot.Hex() }},
+	{name: "rollupExitRoot", hex: func(e *model.IndexedEvent) string { return e.RollupExitRoot.Hex() }},
+	{name: "globalExitRoot", hex: func(e *model.IndexedEvent) string { return e.GlobalExitRoot.Hex() }},
+}
+
+// ColumnNames lists every exportable column in default order.
+func ColumnNames() []string {
+	names := make([]string, len(allColumns))
+	for i, c := range allColumns {
+		names[i] = c.name
+	}
+	return names
+}
+
+// Writer receives events one at a time. Close flushes buffered output but
+// does not close the underlying io.Writer.
+type Writer interface {
+	Write(e *model.IndexedEvent) error
+	Close() error
+}
+
+// NewWriter returns a streaming writer for format that emits the named
+// columns in the given order. No names selects every column.
+func NewWriter(w io.Writer, format string, names []string) (Writer, error) {
+	cols, err := selectColumns(names)
+	if err != nil {
+		return nil, err
+	}
+
+	switch format {
+	case FormatCSV:
+		return newCSVWriter(w, cols)
+	case FormatJSONL:
+		return &jsonlWriter{w: bufio.NewWriter(w), cols: cols}, nil
+	case FormatParquet:
+		return newParquetWriter(w, cols), nil
+	default:
+		return nil, fmt.Errorf("unknown format %q: want csv, jsonl or parquet", format)
+	}
+}
+
+func selectColumns(names []string) ([]column, error) {
+	if len(names) == 0 {
+		return allColumns, nil
+	}
+
+	byName := make(map[string]column, len(allColumns))
+	for _, c := range allColumns {
+		byName[c.name] = c
+	}
+
+	cols := make([]column, 0, len(names))
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		c, ok := byName[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown column %q: want one of %s", name, strings.Join(ColumnNames(), ", "))
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("duplicate column %q", name)
+		}
+		seen[name] = true
+		cols = append(cols, c)
+	}
+	return cols, nil
+}
+
+type csvWriter struct {
+	w    *csv.Writer
+	cols []column
+	rec  []string
+}
+
+func newCSVWriter(w io.Writer, cols []column) (*csvWriter, error) {
+	cw := &csvWriter{w: csv.NewWriter(w), cols: cols, rec: make([]string, len(cols))}
+	for i, c := range cols {
+		cw.rec[i] = c.name
+	}
+	if err := cw.w.Write(cw.rec); err != nil {
+		return nil, err
+	}
+	return cw, nil
+}
+
+func (cw *csvWriter) Write(e *model.IndexedEvent) error {
+	for i, c := range cw.cols {
+		if c.number != nil {
+			cw.rec[i] = strconv.FormatUint(c.number(e), 10)
+		} else {
+			cw.rec[i] = c.hex(e)
+		}
+	}
+	return cw.w.Write(cw.rec)
+}
+
+func (cw *csvWriter) Close() error {
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+type jsonlWriter struct {
+	w    *bufio.Writer
+	cols []column
+}
+
+// Write emits one object per line, keys in column order.
+func (jw *jsonlWriter) Write(e *model.IndexedEvent) error {
+	buf := make([]byte, 0, 512)
+	buf = append(buf, '{')
+	for i, c := range jw.cols {
+		if i > 0 {
+			buf = append(buf, ',')
+		}
+		buf = strconv.AppendQuote(buf, c.name)
+		buf = append(buf, ':')
+		if c.number != nil {
+			buf = strconv.AppendUint(buf, c.number(e), 10)
+		} else {
+			v, err := json.Marshal(c.hex(e))
+			if err != nil {
+				return err
+			}
+			buf = append(buf, v...)
+		}
+	}
+	buf = append(buf, '}', '\n')
+	_, err := jw.w.Write(buf)
+	return err
+}
+
+func (jw *jsonlWriter) Close() error {
+	return jw.w.Flush()
+}
+
+type parquetWriter struct {
+	w    *parquet.Writer
+	cols []column
+	pos  []int // parquet column index of each selected column
+	row  parquet.Row
+}
+
+func newParquetWriter(w io.Writer, cols []column) *parquetWriter {
+	group := parquet.Group{}
+	for _, c := range cols {
+		if c.number != nil {
+			group[c.name] = parquet.Uint(64)
+		} else {
+			group[c.name] = parquet.String()
+		}
+	}
+	schema := parquet.NewSchema("event", group)
+
+	// parquet orders group fields by name, not by selection order
+	leaf := make(map[string]int)
+	for i, path := range schema.Columns() {
+		leaf[path[0]] = i
+	}
+	pos := make([]int, len(cols))
+	for i, c := range cols {
+		pos[i] = leaf[c.name]
+	}
+
+	return &parquetWriter{
+		w: parquet.NewWriter(w, schema,
+			parquet.Compression(&parquet.Snappy),
+			parquet.MaxRowsPerRowGroup(parquetRowGroup),
+		),
+		cols: cols,
+		pos:  pos,
+		row:  make(parquet.Row, len(cols)),
+	}
+}
+
+func (pw *parquetWriter) Write(e *model.IndexedEvent) error {
+	for i, c := range pw.cols {
+		var v parquet.Value
+		if c.number != nil {
+			v = parquet.ValueOf(c.number(e))
+		} else {
+			v = parquet.ValueOf(c.hex(e))
+		}
+		pw.row[pw.pos[i]] = v.Level(0, 0, pw.pos[i])
+	}
+	_, err := pw.w.WriteRows([]parquet.Row{pw.row})
+	return err
+}
+
+func (pw *parquetWriter) Close() error {
+	return pw.w.Close()
+}
@@ -0,0 +1,200 @@
+package export
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/parquet-go/parquet-go"
+	"github.com/zacksfF/sepolia-sh/ch1/internal/model"
+	"github.com/zacksfF/sepolia-sh/ch1/internal/storage"
+	"github.com/zacksfF/sepolia-sh/ch1/internal/storage/memory"
+)
+
+// newTestStore indexes six events in blocks 100, 100, 101, 103, 103, 104.
+func newTestStore(t *testing.T) storage.Store {
+	t.Helper()
+
+	store := memory.New()
+
+	batch := &storage.Batch{
+		Checkpoint: storage.BlockRef{Number: 104, Hash: common.HexToHash("0x104")},
+		Window:     10,
+	}
+	for i, bn := range []uint64{100, 100, 101, 103, 103, 104} {
+		batch.Events = append(batch.Events, &model.IndexedEvent{
+			Index:          uint64(i),
+			BlockNumber:    bn,
+			BlockTime:      1700000000 + bn,
+			TxHash:         common.BigToHash(new(big.Int).SetUint64(bn)),
+			LogIndex:       uint(i),
+			GlobalExitRoot: common.BigToHash(new(big.Int).SetUint64(uint64(1000 + i))),
+		})
+	}
+	batch.NextIndex = uint64(len(batch.Events))
+
+	if err := store.WriteBatch(context.Background(), batch); err != nil {
+		t.Fatalf("Failed to write batch: %v", err)
+	}
+	return store
+}
+
+func u64(v uint64) *uint64 { return &v }
+
+func TestEvents_Ranges(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		name string
+		r    Range
+		want []string
+	}{
+		{"all", Range{}, []string{"0", "1", "2", "3", "4", "5"}},
+		{"index range", Range{FromIndex: u64(2), ToIndex: u64(4)}, []string{"2", "3", "4"}},
+		{"block range", Range{FromBlock: u64(101), ToBlock: u64(103)}, []string{"2", "3", "4"}},
+		{"block gap", Range{FromBlock: u64(102), ToBlock: u64(102)}, nil},
+		{"combined", Range{FromIndex: u64(3), FromBlock: u64(100), ToBlock: u64(103)}, []string{"3", "4"}},
+		{"past end", Range{FromBlock: u64(200)}, nil},
+		{"to index past end", Range{FromIndex: u64(5), ToIndex: u64(99)}, []string{"5"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			w, err := NewWriter(&buf, FormatCSV, []string{"index"})
+			if err != nil {
+				t.Fatalf("Failed to create writer: %v", err)
+			}
+			n, err := Events(ctx, store, w, tt.r)
+			if err != nil {
+				t.Fatalf("Failed to export: %v", err)
+			}
+			if err := w.Close(); err != nil {
+				t.Fatalf("Failed to close writer: %v", err)
+			}
+
+			records, err := csv.NewReader(&buf).ReadAll()
+			if err != nil {
+				t.Fatalf("Failed to read csv: %v", err)
+			}
+			var got []string
+			for _, rec := range records[1:] {
+				got = append(got, rec[0])
+			}
+			if n != uint64(len(tt.want)) || strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("exported mismatch: got %v (n=%d), want %v", got, n, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewWriter_CSV(t *testing.T) {
+	store := newTestStore(t)
+
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, FormatCSV, []string{"txHash", "blockNumber"})
+	if err != nil {
+		t.Fatalf("Failed to create writer: %v", err)
+	}
+	if _, err := Events(context.Background(), store, w, Range{ToIndex: u64(0)}); err != nil {
+		t.Fatalf("Failed to export: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Failed to close writer: %v", err)
+	}
+
+	want := "txHash,blockNumber\n" + common.BigToHash(big.NewInt(100)).Hex() + ",100\n"
+	if buf.String() != want {
+		t.Errorf("csv mismatch: got %q, want %q", buf.String(), want)
+	}
+}
+
+func TestNewWriter_JSONL(t *testing.T) {
+	store := newTestStore(t)
+
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, FormatJSONL, nil)
+	if err != nil {
+		t.Fatalf("Failed to create writer: %v", err)
+	}
+	if _, err := Events(context.Background(), store, w, Range{}); err != nil {
+		t.Fatalf("Failed to export: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Failed to close writer: %v", err)
+	}
+
+	// every line must round-trip into the model, hashes included
+	sc := bufio.NewScanner(&buf)
+	var i uint64
+	for ; sc.Scan(); i++ {
+		var e model.IndexedEvent
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			t.Fatalf("Failed to decode line %d: %v", i, err)
+		}
+		want, err := store.GetEvent(context.Background(), i)
+		if err != nil {
+			t.Fatalf("Failed to get event: %v", err)
+		}
+		if e != *want {
+			t.Errorf("event %d mismatch: got %+v, want %+v", i, e, *want)
+		}
+	}
+	if i != 6 {
+		t.Errorf("line count mismatch: got %d, want 6", i)
+	}
+}
+
+func TestNewWriter_Parquet(t *testing.T) {
+	store := newTestStore(t)
+
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, FormatParquet, []string{"index", "globalExitRoot"})
+	if err != nil {
+		t.Fatalf("Failed to create writer: %v", err)
+	}
+	if _, err := Events(context.Background(), store, w, Range{}); err != nil {
+		t.Fatalf("Failed to export: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Failed to close writer: %v", err)
+	}
+
+	type row struct {
+		Index          uint64 `parquet:"index"`
+		GlobalExitRoot string `parquet:"globalExitRoot"`
+	}
+	rows, err := parquet.Read[row](bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("Failed to read parquet: %v", err)
+	}
+	if len(rows) != 6 {
+		t.Fatalf("row count mismatch: got %d, want 6", len(rows))
+	}
+	for i, r := range rows {
+		want := common.BigToHash(big.NewInt(int64(1000 + i))).Hex()
+		if r.Index != uint64(i) || r.GlobalExitRoot != want {
+			t.Errorf("row %d mismatch: got %+v, want {%d %s}", i, r, i, want)
+		}
+	}
+}
+
+func TestNewWriter_Invalid(t *testing.T) {
+	var buf bytes.Buffer
+	if _, err := NewWriter(&buf, "xml", nil); err == nil {
+		t.Error("expected error for unknown format")
+	}
+	if _, err := NewWriter(&buf, FormatCSV, []string{"index", "nope"}); err == nil {
+		t.Error("expected error for unknown column")
+	}
+	if _, err := NewWriter(&buf, FormatCSV, []string{"index", "index"}); err == nil {
+		t.Error("expected error for duplicate column")
+	}
+}
@@ -0,0 +1,77 @@
+package export
+
+import (
+	"context"
+	"sort"
+
+	"github.com/zacksfF/sepolia-sh/ch1/internal/model"
+	"github.com/zacksfF/sepolia-sh/ch1/internal/storage"
+)
+
+// Range selects the events to export. Bounds are inclusive and nil means
+// open-ended. Index and block bounds may be combined; an event must match
+// both.
+type Range struct {
+	FromIndex, ToIndex *uint64
+	FromBlock, ToBlock *uint64
+}
+
+// Events streams every event in r from store to w and returns how many were
+// written. It holds at most one event at a time.
+func Events(ctx context.Context, store storage.Store, w Writer, r Range) (uint64, error) {
+	next, err := store.GetNextIndex(ctx)
+	if err != nil || next == 0 {
+		return 0, err
+	}
+
+	from, to := uint64(0), next-1
+	if r.FromIndex != nil && *r.FromIndex > from {
+		from = *r.FromIndex
+	}
+	if r.ToIndex != nil && *r.ToIndex < to {
+		to = *r.ToIndex
+	}
+
+	// events are stored in block order, so the first index of the block
+	// range can be found by bisecting instead of scanning
+	if r.FromBlock != nil {
+		first, err := firstIndexAtBlock(ctx, store, *r.FromBlock, next)
+		if err != nil {
+			return 0, err
+		}
+		if first > from {
+			from = first
+		}
+	}
+	if from > to {
+		return 0, nil
+	}
+
+	var n uint64
+	err = store.IterateEvents(ctx, from, to, func(e *model.IndexedEvent) error {
+		if r.ToBlock != nil && e.BlockNumber > *r.ToBlock {
+			return storage.ErrStop
+		}
+		if err := w.Write(e); err != nil {
+			return err
+		}
+		n++
+		return nil
+	})
+	return n, err
+}
+
+// firstIndexAtBlock returns the lowest index whose block is at or after
+// block, or next if there is none.
+func firstIndexAtBlock(ctx context.Context, store storage.Store, block, next uint64) (uint64, error) {
+	var err error
+	i := sort.Search(int(next), func(i int) bool {
+		if err != nil {
+			return true
+		}
+		var e *model.IndexedEvent
+		e, err = store.GetEvent(ctx, uint64(i))
+		return err == nil && e.BlockNumber >= block
+	})
+	return uint64(i), err
+}
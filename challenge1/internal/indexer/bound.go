@@ -0,0 +1,57 @@
+package indexer
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/zacksfF/sepolia-sh/ch1/internal/storage"
+)
+
+// Sync targets: the block tag the indexer is allowed to index up to.
+const (
+	TargetLatest    = "latest"    // unsafe head, may still reorg
+	TargetSafe      = "safe"      // justified by the beacon chain
+	TargetFinalized = "finalized" // cannot reorg without slashing
+)
+
+// syncBound resolves the highest block the indexer may index: the block
+// behind the configured tag, minus the confirmation depth. It also returns
+// the chain head from eth_blockNumber, which lag is measured against. ok is
+// false when the chain is not yet deep enough to index anything.
+func (i *Indexer) syncBound(ctx context.Context) (bound storage.SyncBound, chainHead uint64, ok bool, err error) {
+	bound = storage.SyncBound{
+		Tag:           i.target,
+		Confirmations: i.confirmations,
+	}
+
+	chainHead, err = i.eth.BlockNumber(ctx)
+	if err != nil {
+		return bound, 0, false, err
+	}
+
+	var head uint64
+	switch i.target {
+	case TargetLatest:
+		head = chainHead
+	case TargetSafe, TargetFinalized:
+		tag := rpc.SafeBlockNumber
+		if i.target == TargetFinalized {
+			tag = rpc.FinalizedBlockNumber
+		}
+		header, err := i.eth.HeaderByNumber(ctx, big.NewInt(tag.Int64()))
+		if err != nil {
+			return bound, chainHead, false, fmt.Errorf("fetch %s block: %w", i.target, err)
+		}
+		head = header.Number.Uint64()
+	default:
+		return bound, chainHead, false, fmt.Errorf("unknown sync target %q", i.target)
+	}
+
+	if head < i.confirmations {
+		return bound, chainHead, false, nil
+	}
+	bound.Number = head - i.confirmations
+	return bound, chainHead, true, nil
+}
@@ -2,20 +2,47 @@ package indexer
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
+	"math/big"
+	"time"
 
+	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/zacksfF/sepolia-sh/ch1/internal/eth"
+	"github.com/zacksfF/sepolia-sh/ch1/internal/l1infotree"
+	"github.com/zacksfF/sepolia-sh/ch1/internal/metrics"
 	"github.com/zacksfF/sepolia-sh/ch1/internal/model"
 	"github.com/zacksfF/sepolia-sh/ch1/internal/storage"
 )
 
+// ErrReorgTooDeep is returned when no block in the reorg window is still
+// canonical, so the common ancestor is unknown. Re-index from an earlier
+// block, or raise the window.
+var ErrReorgTooDeep = errors.New("reorg deeper than the reorg window")
+
+// Config holds indexer tuning knobs.
+type Config struct {
+	ReorgWindow   int    // recent block hashes kept for reorg detection
+	Target        string // block tag to index up to: latest, safe or finalized
+	Confirmations uint64 // blocks to stay behind the target
+	FetchWorkers  int    // header requests in flight per batch
+	RPCBatchSize  int    // headers per JSON-RPC batch request
+}
+
 type Indexer struct {
 	eth      *eth.Client
 	store    storage.Store
 	contract common.Address
 	topic    common.Hash
+
+	reorgWindow   int
+	target        string
+	confirmations uint64
+	fetchWorkers  int
+	rpcBatchSize  int
 }
 
 func New(
@@ -23,12 +50,31 @@ func New(
 	store storage.Store,
 	contract common.Address,
 	topic common.Hash,
+	cfg Config,
 ) *Indexer {
+	if cfg.ReorgWindow <= 0 {
+		cfg.ReorgWindow = 64
+	}
+	if cfg.Target == "" {
+		cfg.Target = TargetLatest
+	}
+	if cfg.FetchWorkers <= 0 {
+		cfg.FetchWorkers = 8
+	}
+	if cfg.RPCBatchSize <= 0 {
+		cfg.RPCBatchSize = 100
+	}
+
 	return &Indexer{
-		eth:      ethClient,
-		store:    store,
-		contract: contract,
-		topic:    topic,
+		eth:           ethClient,
+		store:         store,
+		contract:      contract,
+		topic:         topic,
+		reorgWindow:   cfg.ReorgWindow,
+		target:        cfg.Target,
+		confirmations: cfg.Confirmations,
+		fetchWorkers:  cfg.FetchWorkers,
+		rpcBatchSize:  cfg.RPCBatchSize,
 	}
 }
 
@@ -40,31 +86,96 @@ func (i *Indexer) Run(
 ) error {
 
 	logFetcher := eth.NewLogFetcher(i.eth)
-	blockFetcher := eth.NewBlockFetcher(i.eth)
+	blockFetcher := eth.NewBlockFetcher(i.eth, i.rpcBatchSize)
 
 	nextIndex, err := i.store.GetNextIndex(ctx)
 	if err != nil {
 		return err
 	}
 
-	var latest uint64
-	if end == nil {
-		latest, err = i.eth.BlockNumber(ctx)
+	tree, err := l1infotree.Load(ctx, i.store, nextIndex)
+	if err != nil {
+		return err
+	}
+
+	// never index past the configured bound, even when END_BLOCK is higher
+	bound, head, ok, err := i.syncBound(ctx)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		log.Printf("chain is shallower than %d confirmations, nothing to index", i.confirmations)
+		return nil
+	}
+
+	metrics.HeadBlock.Set(float64(head))
+	metrics.BoundBlock.Set(float64(bound.Number))
+
+	latest := bound.Number
+	if end != nil && *end < latest {
+		latest = *end
+	}
+
+	// resume right after the last fully processed block
+	from := start
+	checkpoint, err := i.store.GetCheckpoint(ctx)
+	if err != nil {
+		return err
+	}
+	if checkpoint != nil && checkpoint.Number+1 > from {
+		from = checkpoint.Number + 1
+		log.Printf("resuming from checkpoint: block %d (%s)", checkpoint.Number, checkpoint.Hash.Hex())
+	}
+	if checkpoint != nil {
+		reportProgress(checkpoint.Number, head)
+	}
+
+	// batches record the bound themselves; with none to write, record it
+	// here so the metadata follows a changed target or confirmation depth
+	if from > latest {
+		stored, err := i.store.GetSyncBound(ctx)
 		if err != nil {
 			return err
 		}
-	} else {
-		latest = *end
+		if stored == nil || *stored != bound {
+			if err := i.store.SetSyncBound(ctx, bound); err != nil {
+				return err
+			}
+		}
 	}
 
-	blockCache := make(map[common.Hash]*types.Block)
+	for from <= latest {
+		ancestor, reorged, err := i.detectReorg(ctx)
+		if err != nil {
+			return err
+		}
+		if reorged {
+			nextIndex, err = i.store.Rollback(ctx, ancestor)
+			if err != nil {
+				return err
+			}
+			log.Printf("reorg detected: rolled back to block %d, next index %d", ancestor, nextIndex)
+			metrics.Reorgs.Inc()
+			reportProgress(ancestor, head)
+			if tree, err = l1infotree.Load(ctx, i.store, nextIndex); err != nil {
+				return err
+			}
+			from = ancestor + 1
+			continue
+		}
 
-	for from := start; from <= latest; from += batch {
 		to := from + batch - 1
 		if to > latest {
 			to = latest
 		}
 
+		// read the batch tip before the logs: if the chain reorgs in between,
+		// the stored tip is orphaned and the next check catches it
+		tip, err := i.eth.HeaderByNumber(ctx, new(big.Int).SetUint64(to))
+		if err != nil {
+			return err
+		}
+
 		log.Printf("fetching logs: blocks %d -> %d", from, to)
 
 		logs, err := logFetcher.Fetch(ctx, i.contract, i.topic, from, to)
@@ -72,36 +183,210 @@ func (i *Indexer) Run(
 			return err
 		}
 
-		for _, lg := range logs {
-			blk, ok := blockCache[lg.BlockHash]
-			if !ok {
-				blk, err = blockFetcher.ByHash(ctx, lg.BlockHash)
-				if err != nil {
-					return err
-				}
-				blockCache[lg.BlockHash] = blk
+		// decode first, so a bad log fails the batch before any fetching
+		updates := make([]*eth.UpdateL1InfoTree, len(logs))
+		var hashes []common.Hash
+		seen := make(map[common.Hash]bool)
+		for k, lg := range logs {
+			if updates[k], err = eth.DecodeUpdateL1InfoTree(lg); err != nil {
+				return fmt.Errorf("log %s/%d: %w", lg.TxHash.Hex(), lg.Index, err)
+			}
+			if !seen[lg.BlockHash] {
+				seen[lg.BlockHash] = true
+				hashes = append(hashes, lg.BlockHash)
+			}
+		}
+
+		// each header once, batched and concurrent; logs stay in
+		// (block, logIndex) order
+		headers, err := blockFetcher.ByHashes(ctx, hashes, i.fetchWorkers)
+		if err != nil {
+			return err
+		}
+		byHash := make(map[common.Hash]*types.Header, len(headers))
+		refs := make([]storage.BlockRef, 0, len(headers))
+		for k, h := range headers {
+			byHash[hashes[k]] = h
+			refs = append(refs, storage.BlockRef{Number: h.Number.Uint64(), Hash: h.Hash()})
+		}
+
+		// the logs may come from a fork the chain left again before the
+		// tip was read, and detectReorg only checks the newest window
+		// entry; redo the batch unless every event block is still
+		// canonical next to the tip
+		if len(refs) > 0 {
+			stale, err := i.staleBlocks(ctx, blockFetcher, append(refs, storage.BlockRef{Number: to, Hash: tip.Hash()}))
+			if err != nil {
+				return err
 			}
+			if stale {
+				log.Printf("blocks %d -> %d changed while fetching logs, fetching again", from, to)
+				continue
+			}
+		}
+
+		events := make([]*model.IndexedEvent, 0, len(logs))
+		roots := make([]common.Hash, 0, len(logs))
+		nodes := make(map[[2]uint64]l1infotree.Node)
+
+		for k, lg := range logs {
+			update, header := updates[k], byHash[lg.BlockHash]
 
 			event := &model.IndexedEvent{
 				Index:       nextIndex,
-				BlockNumber: blk.NumberU64(),
-				BlockTime:   blk.Time(),
-				ParentHash:  blk.ParentHash(),
+				BlockNumber: header.Number.Uint64(),
+				BlockTime:   header.Time,
+				ParentHash:  header.ParentHash,
 				TxHash:      lg.TxHash,
 				LogIndex:    lg.Index,
-				InfoRoot:    common.BytesToHash(lg.Data), // assumption documented in README
-			}
 
-			if err := i.store.SaveEvent(ctx, event); err != nil {
-				return err
+				MainnetExitRoot: update.MainnetExitRoot,
+				RollupExitRoot:  update.RollupExitRoot,
+				GlobalExitRoot:  model.GlobalExitRoot(update.MainnetExitRoot, update.RollupExitRoot),
 			}
-
+			events = append(events, event)
 			nextIndex++
-			if err := i.store.SetNextIndex(ctx, nextIndex); err != nil {
-				return err
+
+			// later leaves in the batch overwrite shared upper nodes, keep the last
+			touched, root := tree.Append(l1infotree.LeafHash(event.GlobalExitRoot, event.ParentHash, event.BlockTime))
+			for _, n := range touched {
+				nodes[[2]uint64{uint64(n.Level), n.Pos}] = n
 			}
+			roots = append(roots, root)
+		}
+
+		treeNodes := make([]l1infotree.Node, 0, len(nodes))
+		for _, n := range nodes {
+			treeNodes = append(treeNodes, n)
+		}
+
+		// events, index, checkpoint, window and tree commit in one transaction
+		checkpoint := storage.BlockRef{Number: to, Hash: tip.Hash()}
+		if err := i.store.WriteBatch(ctx, &storage.Batch{
+			Events:     events,
+			NextIndex:  nextIndex,
+			Checkpoint: checkpoint,
+			Blocks:     append(refs, checkpoint),
+			Window:     i.reorgWindow,
+			Bound:      &bound,
+			TreeNodes:  treeNodes,
+			InfoRoots:  roots,
+		}); err != nil {
+			return err
 		}
+		metrics.BlocksProcessed.Add(float64(to - from + 1))
+		metrics.EventsStored.Add(float64(len(events)))
+		reportProgress(to, head)
+
+		if len(roots) > 0 {
+			log.Printf("indexed %d events, l1 info root %s at index %d", len(events), roots[len(roots)-1].Hex(), nextIndex-1)
+		}
+
+		from = to + 1
 	}
 
 	return nil
 }
+
+// reportProgress publishes the indexed block and how far it trails head
+func reportProgress(indexed, head uint64) {
+	metrics.IndexedBlock.Set(float64(indexed))
+	metrics.Lag.Set(float64(head - min(indexed, head)))
+}
+
+// Follow backfills from start like Run and then keeps tailing the chain
+// head, polling for new blocks every interval until ctx is cancelled, when
+// it returns ctx.Err(). A failed sync step, such as an RPC error that
+// outlasted its retries, is logged and tried again on the next tick. Only
+// errors another poll can't fix end it: ErrReorgTooDeep and
+// storage.ErrReindexRequired.
+func (i *Indexer) Follow(
+	ctx context.Context,
+	start uint64,
+	batch uint64,
+	interval time.Duration,
+) error {
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	caughtUp := false
+	for {
+		err := i.Run(ctx, start, nil, batch)
+		var checkpoint *storage.BlockRef
+		if err == nil {
+			checkpoint, err = i.store.GetCheckpoint(ctx)
+		}
+
+		switch {
+		case ctx.Err() != nil:
+			return ctx.Err()
+		case errors.Is(err, ErrReorgTooDeep), errors.Is(err, storage.ErrReindexRequired):
+			return err
+		case err != nil:
+			log.Printf("sync failed, retrying in %v: %v", interval, err)
+		case checkpoint != nil:
+			start = checkpoint.Number + 1
+			if !caughtUp {
+				log.Printf("backfill complete at block %d, following head every %v", checkpoint.Number, interval)
+				caughtUp = true
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// staleBlocks reports whether any of refs is no longer the canonical block
+// at its height, reading them all in one batch
+func (i *Indexer) staleBlocks(ctx context.Context, fetcher *eth.BlockFetcher, refs []storage.BlockRef) (bool, error) {
+	numbers := make([]uint64, len(refs))
+	for k, ref := range refs {
+		numbers[k] = ref.Number
+	}
+
+	headers, err := fetcher.ByNumbers(ctx, numbers, i.fetchWorkers)
+	if errors.Is(err, ethereum.NotFound) {
+		return true, nil // the chain got shorter
+	}
+	if err != nil {
+		return false, err
+	}
+	for k, h := range headers {
+		if h.Hash() != refs[k].Hash {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// detectReorg compares the stored block window against the chain, newest
+// first. If the newest entry is still canonical there is nothing to do;
+// otherwise it returns the most recent entry that is, the common ancestor.
+// The newest entry vouches for the rest because Run only writes a batch
+// whose event blocks were canonical together with its tip.
+func (i *Indexer) detectReorg(ctx context.Context) (uint64, bool, error) {
+	refs, err := i.store.RecentBlocks(ctx)
+	if err != nil {
+		return 0, false, err
+	}
+
+	for n, ref := range refs {
+		header, err := i.eth.HeaderByNumber(ctx, new(big.Int).SetUint64(ref.Number))
+		if err != nil && !errors.Is(err, ethereum.NotFound) {
+			return 0, false, err
+		}
+		if header != nil && header.Hash() == ref.Hash {
+			return ref.Number, n > 0, nil
+		}
+	}
+
+	if len(refs) == 0 {
+		return 0, false, nil
+	}
+	return 0, false, fmt.Errorf("%w of %d blocks", ErrReorgTooDeep, len(refs))
+}
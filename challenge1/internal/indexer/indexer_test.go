@@ -0,0 +1,592 @@
+package indexer
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/zacksfF/sepolia-sh/ch1/internal/eth"
+	"github.com/zacksfF/sepolia-sh/ch1/internal/eth/ethtest"
+	"github.com/zacksfF/sepolia-sh/ch1/internal/l1infotree"
+	"github.com/zacksfF/sepolia-sh/ch1/internal/metrics"
+	"github.com/zacksfF/sepolia-sh/ch1/internal/model"
+	"github.com/zacksfF/sepolia-sh/ch1/internal/storage"
+	"github.com/zacksfF/sepolia-sh/ch1/internal/storage/memory"
+)
+
+var (
+	contract = common.HexToAddress("0x2968d6d736178f8fe7393cc33c87f29d9c287e78")
+	topic    = common.HexToHash("0x3e54d0825ed78523037d00a81759237eb436ce774bd546993ee67a1b67b6e766")
+)
+
+// updateLog is an UpdateL1InfoTree log with roots derived from seed
+func updateLog(seed uint64) types.Log {
+	return types.Log{
+		Address: contract,
+		Topics: []common.Hash{
+			topic,
+			common.BigToHash(new(big.Int).SetUint64(1<<32 | seed)),
+			common.BigToHash(new(big.Int).SetUint64(2<<32 | seed)),
+		},
+	}
+}
+
+// mineChain mines blocks 1..n. Every third block emits one event, every
+// fifth two, and every seventh also carries logs the indexer must skip.
+func mineChain(srv *ethtest.Server, n int, seed uint64) {
+	for b := 1; b <= n; b++ {
+		var logs []types.Log
+		if b%3 == 0 {
+			logs = append(logs, updateLog(seed+uint64(b)))
+		}
+		if b%5 == 0 {
+			logs = append(logs, updateLog(seed+uint64(b)<<8), updateLog(seed+uint64(b)<<8+1))
+		}
+		if b%7 == 0 {
+			other := updateLog(seed + uint64(b))
+			other.Address = common.HexToAddress("0x01")
+			noise := updateLog(seed + uint64(b))
+			noise.Topics[0] = common.HexToHash("0x02")
+			logs = append(logs, other, noise)
+		}
+		srv.Mine(logs...)
+	}
+}
+
+// newTestIndexer dials srv without retries, so every injected fault
+// reaches the indexer
+func newTestIndexer(t *testing.T, srv *ethtest.Server, store storage.Store, cfg Config) *Indexer {
+	t.Helper()
+	client, err := eth.Dial(context.Background(), srv.URL, eth.RetryPolicy{})
+	if err != nil {
+		t.Fatalf("Failed to dial fake node: %v", err)
+	}
+	t.Cleanup(client.Close)
+	return New(client, store, contract, topic, cfg)
+}
+
+// checkSynced compares the store with the canonical chain up to block to
+func checkSynced(t *testing.T, srv *ethtest.Server, store storage.Store, to uint64) {
+	t.Helper()
+	ctx := context.Background()
+
+	var want []*model.IndexedEvent
+	tree := l1infotree.New()
+	var roots []common.Hash
+	for number := uint64(0); number <= to; number++ {
+		header, logs := srv.Block(number)
+		for _, lg := range logs {
+			if lg.Address != contract || lg.Topics[0] != topic {
+				continue
+			}
+			e := &model.IndexedEvent{
+				Index:           uint64(len(want)),
+				BlockNumber:     number,
+				BlockTime:       header.Time,
+				ParentHash:      header.ParentHash,
+				TxHash:          lg.TxHash,
+				LogIndex:        lg.Index,
+				MainnetExitRoot: lg.Topics[1],
+				RollupExitRoot:  lg.Topics[2],
+			}
+			e.GlobalExitRoot = model.GlobalExitRoot(e.MainnetExitRoot, e.RollupExitRoot)
+			want = append(want, e)
+			_, root := tree.Append(l1infotree.LeafHash(e.GlobalExitRoot, e.ParentHash, e.BlockTime))
+			roots = append(roots, root)
+		}
+	}
+
+	var got []*model.IndexedEvent
+	err := store.IterateEvents(ctx, 0, ^uint64(0), func(e *model.IndexedEvent) error {
+		event := *e
+		got = append(got, &event)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to iterate events: %v", err)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("event count mismatch: got %d, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if *got[i] != *want[i] {
+			t.Errorf("event %d mismatch: got %+v, want %+v", i, got[i], want[i])
+		}
+		if root, err := store.GetInfoRoot(ctx, uint64(i)); err != nil || root != roots[i] {
+			t.Errorf("info root %d mismatch: got %s (%v), want %s", i, root.Hex(), err, roots[i].Hex())
+		}
+	}
+
+	if next, err := store.GetNextIndex(ctx); err != nil || next != uint64(len(want)) {
+		t.Errorf("next index mismatch: got %d (%v), want %d", next, err, len(want))
+	}
+	header, _ := srv.Block(to)
+	cp, err := store.GetCheckpoint(ctx)
+	if err != nil || cp == nil || cp.Number != to || cp.Hash != header.Hash() {
+		t.Errorf("checkpoint mismatch: got %+v (%v), want %d %s", cp, err, to, header.Hash().Hex())
+	}
+}
+
+func TestIndexer_Sync(t *testing.T) {
+	srv := ethtest.NewServer(t)
+	mineChain(srv, 40, 0)
+	store := memory.New()
+
+	idx := newTestIndexer(t, srv, store, Config{})
+	if err := idx.Run(context.Background(), 0, nil, 7); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	checkSynced(t, srv, store, 40)
+	// blocks 0..40 in batches of 7
+	if got := srv.Calls("eth_getLogs"); got != 6 {
+		t.Errorf("eth_getLogs calls mismatch: got %d, want 6", got)
+	}
+}
+
+func TestIndexer_Metrics(t *testing.T) {
+	ctx := context.Background()
+	srv := ethtest.NewServer(t)
+	mineChain(srv, 30, 0)
+	store := memory.New()
+	blocks := testutil.ToFloat64(metrics.BlocksProcessed)
+	events := testutil.ToFloat64(metrics.EventsStored)
+
+	// the bound trails the head, and lag is measured from the head
+	end := uint64(20)
+	if err := newTestIndexer(t, srv, store, Config{Confirmations: 5}).Run(ctx, 0, &end, 8); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	next, _ := store.GetNextIndex(ctx)
+
+	if got := testutil.ToFloat64(metrics.BlocksProcessed) - blocks; got != 21 {
+		t.Errorf("blocks processed mismatch: got %v, want 21", got)
+	}
+	if got := testutil.ToFloat64(metrics.EventsStored) - events; got != float64(next) {
+		t.Errorf("events stored mismatch: got %v, want %d", got, next)
+	}
+	if got := testutil.ToFloat64(metrics.IndexedBlock); got != 20 {
+		t.Errorf("indexed block mismatch: got %v, want 20", got)
+	}
+	if got := testutil.ToFloat64(metrics.HeadBlock); got != 30 {
+		t.Errorf("head block mismatch: got %v, want 30", got)
+	}
+	if got := testutil.ToFloat64(metrics.BoundBlock); got != 25 {
+		t.Errorf("bound block mismatch: got %v, want 25", got)
+	}
+	if got := testutil.ToFloat64(metrics.Lag); got != 10 {
+		t.Errorf("lag mismatch: got %v, want 10", got)
+	}
+}
+
+func TestIndexer_ParallelFetch(t *testing.T) {
+	srv := ethtest.NewServer(t)
+	for b := 1; b <= 30; b++ {
+		srv.Mine(updateLog(uint64(b)), updateLog(uint64(b)<<8))
+	}
+	store := memory.New()
+
+	// hold each block request long enough for the others to pile up
+	var inFlight, peak atomic.Int32
+	srv.OnCall(func(method string) {
+		if method != "eth_getBlockByHash" {
+			return
+		}
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+	})
+
+	// one header per request, so every fetch is its own call
+	idx := newTestIndexer(t, srv, store, Config{FetchWorkers: 4, RPCBatchSize: 1})
+	if err := idx.Run(context.Background(), 0, nil, 15); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	checkSynced(t, srv, store, 30)
+	if got := srv.Calls("eth_getBlockByHash"); got != 30 {
+		t.Errorf("block fetches mismatch: got %d, want one per block (30)", got)
+	}
+	if got := peak.Load(); got < 2 || got > 4 {
+		t.Errorf("peak concurrent fetches: got %d, want 2..4", got)
+	}
+}
+
+func TestIndexer_BatchedHeaders(t *testing.T) {
+	srv := ethtest.NewServer(t)
+	for b := 1; b <= 30; b++ {
+		srv.Mine(updateLog(uint64(b)))
+	}
+	store := memory.New()
+
+	idx := newTestIndexer(t, srv, store, Config{RPCBatchSize: 8})
+	if err := idx.Run(context.Background(), 0, nil, 100); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	checkSynced(t, srv, store, 30)
+	// 30 headers in batches of 8, 8, 8 and 6, then the canonical check of
+	// their 30 numbers and the tip in batches of 8, 8, 8 and 7
+	if got := srv.Batches(); got != 8 {
+		t.Errorf("batch requests mismatch: got %d, want 8", got)
+	}
+	if got := srv.Calls("eth_getBlockByHash"); got != 30 {
+		t.Errorf("header lookups mismatch: got %d, want 30", got)
+	}
+}
+
+func TestIndexer_BlockFetchError(t *testing.T) {
+	ctx := context.Background()
+	srv := ethtest.NewServer(t)
+	mineChain(srv, 20, 0)
+	store := memory.New()
+
+	// one failed fetch fails the whole batch
+	srv.Inject(ethtest.Fault{Method: "eth_getBlockByHash", Message: "header not found"})
+	err := newTestIndexer(t, srv, store, Config{FetchWorkers: 4}).Run(ctx, 0, nil, 30)
+	if err == nil || !strings.Contains(err.Error(), "header not found") {
+		t.Fatalf("Run error mismatch: got %v, want header not found", err)
+	}
+	if next, _ := store.GetNextIndex(ctx); next != 0 {
+		t.Errorf("next index after failed batch: got %d, want 0", next)
+	}
+}
+
+func TestIndexer_Resume(t *testing.T) {
+	ctx := context.Background()
+	srv := ethtest.NewServer(t)
+	mineChain(srv, 25, 0)
+	store := memory.New()
+
+	end := uint64(12)
+	if err := newTestIndexer(t, srv, store, Config{}).Run(ctx, 0, &end, 5); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	checkSynced(t, srv, store, 12)
+
+	// a later run, with a longer chain, starts after the checkpoint
+	mineChain(srv, 10, 100)
+	if err := newTestIndexer(t, srv, store, Config{}).Run(ctx, 0, nil, 5); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	checkSynced(t, srv, store, 35)
+
+	// 0-4, 5-9, 10-12, then 13-17 ... 33-35
+	if got := srv.Calls("eth_getLogs"); got != 8 {
+		t.Errorf("eth_getLogs calls mismatch: got %d, want 8", got)
+	}
+}
+
+func TestIndexer_Reorg(t *testing.T) {
+	ctx := context.Background()
+	srv := ethtest.NewServer(t)
+	mineChain(srv, 30, 0)
+	store := memory.New()
+
+	if err := newTestIndexer(t, srv, store, Config{}).Run(ctx, 0, nil, 10); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	// replace blocks 26..30, which emitted events, with a longer fork
+	srv.Reorg(5)
+	for b := 26; b <= 33; b++ {
+		srv.Mine(updateLog(uint64(1000 + b)))
+	}
+
+	if err := newTestIndexer(t, srv, store, Config{}).Run(ctx, 0, nil, 10); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	checkSynced(t, srv, store, 33)
+}
+
+func TestIndexer_ReorgMidSync(t *testing.T) {
+	srv := ethtest.NewServer(t)
+	mineChain(srv, 30, 0)
+	store := memory.New()
+
+	// the chain reorgs while the second batch is being fetched, after its
+	// tip was read, so the indexer stores an orphaned checkpoint
+	srv.OnCall(func(method string) {
+		if method == "eth_getLogs" && srv.Calls(method) == 2 {
+			srv.Reorg(15)
+			for b := 16; b <= 32; b++ {
+				srv.Mine(updateLog(uint64(2000 + b)))
+			}
+		}
+	})
+
+	if err := newTestIndexer(t, srv, store, Config{}).Run(context.Background(), 0, nil, 10); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	checkSynced(t, srv, store, 30)
+}
+
+func TestIndexer_ReorgBackMidBatch(t *testing.T) {
+	srv := ethtest.NewServer(t)
+	mineChain(srv, 30, 0)
+	head := srv.Head().Hash()
+	store := memory.New()
+
+	// after the first batch's tip was read, the node serves its logs from
+	// another fork and then switches back, so the tip is still canonical
+	// but the events are not
+	var step atomic.Int32
+	srv.OnCall(func(method string) {
+		switch {
+		case method == "eth_getLogs" && step.CompareAndSwap(0, 1):
+			srv.Reorg(25)
+			for b := 6; b <= 30; b++ {
+				srv.Mine(updateLog(uint64(3000 + b)))
+			}
+		case method == "eth_getBlockByHash" && step.CompareAndSwap(1, 2):
+			srv.SetHead(head)
+		}
+	})
+
+	if err := newTestIndexer(t, srv, store, Config{}).Run(context.Background(), 0, nil, 10); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if step.Load() != 2 {
+		t.Fatalf("fork switch did not happen: step %d", step.Load())
+	}
+	checkSynced(t, srv, store, 30)
+}
+
+func TestIndexer_ReorgTooDeep(t *testing.T) {
+	ctx := context.Background()
+	srv := ethtest.NewServer(t)
+	mineChain(srv, 20, 0)
+	store := memory.New()
+
+	cfg := Config{ReorgWindow: 3}
+	if err := newTestIndexer(t, srv, store, cfg).Run(ctx, 0, nil, 5); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	srv.Reorg(15)
+	srv.MineEmpty(20)
+	err := newTestIndexer(t, srv, store, cfg).Run(ctx, 0, nil, 5)
+	if !errors.Is(err, ErrReorgTooDeep) {
+		t.Errorf("Run error mismatch: got %v, want ErrReorgTooDeep", err)
+	}
+}
+
+// waitCheckpoint polls until the checkpoint reaches block
+func waitCheckpoint(t *testing.T, store storage.Store, block uint64) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		cp, err := store.GetCheckpoint(context.Background())
+		if err != nil {
+			t.Fatalf("Failed to get checkpoint: %v", err)
+		}
+		if cp != nil && cp.Number >= block {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("checkpoint stuck at %+v, want block %d", cp, block)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestIndexer_Follow(t *testing.T) {
+	srv := ethtest.NewServer(t)
+	mineChain(srv, 20, 0)
+	store := memory.New()
+
+	// the first poll fails, which Follow logs and outlives
+	srv.Inject(ethtest.Fault{Method: "eth_getLogs", Message: "query timeout exceeded"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		done <- newTestIndexer(t, srv, store, Config{}).Follow(ctx, 0, 8, 10*time.Millisecond)
+	}()
+
+	waitCheckpoint(t, store, 20)
+	checkSynced(t, srv, store, 20)
+
+	// blocks mined while following are picked up on a later tick
+	for b := 21; b <= 30; b++ {
+		srv.Mine(updateLog(uint64(b)))
+	}
+	waitCheckpoint(t, store, 30)
+	checkSynced(t, srv, store, 30)
+
+	cancel()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Follow error mismatch: got %v, want context.Canceled", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Follow did not return after cancel")
+	}
+}
+
+func TestIndexer_FollowReorgTooDeep(t *testing.T) {
+	srv := ethtest.NewServer(t)
+	mineChain(srv, 20, 0)
+	store := memory.New()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := newTestIndexer(t, srv, store, Config{ReorgWindow: 3}).Run(ctx, 0, nil, 5); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	// no later poll can find the ancestor, so Follow gives up
+	srv.Reorg(15)
+	srv.MineEmpty(20)
+	err := newTestIndexer(t, srv, store, Config{ReorgWindow: 3}).Follow(ctx, 0, 5, 10*time.Millisecond)
+	if !errors.Is(err, ErrReorgTooDeep) {
+		t.Errorf("Follow error mismatch: got %v, want ErrReorgTooDeep", err)
+	}
+}
+
+func TestIndexer_RPCErrors(t *testing.T) {
+	ctx := context.Background()
+	srv := ethtest.NewServer(t)
+	mineChain(srv, 20, 0)
+	store := memory.New()
+
+	// a failed batch aborts the run and stores nothing from it
+	srv.Inject(ethtest.Fault{Method: "eth_getLogs", Message: "query timeout exceeded"})
+	err := newTestIndexer(t, srv, store, Config{}).Run(ctx, 0, nil, 10)
+	var rerr rpc.Error
+	if !errors.As(err, &rerr) || rerr.ErrorCode() != ethtest.CodeServerError {
+		t.Fatalf("Run error mismatch: got %v, want a JSON-RPC server error", err)
+	}
+	if cp, _ := store.GetCheckpoint(ctx); cp != nil {
+		t.Errorf("checkpoint after failed batch: got %+v, want nil", cp)
+	}
+
+	// a rate limit on the second batch keeps the first
+	srv.OnCall(func(method string) {
+		if method == "eth_getLogs" && srv.Calls(method) == 3 {
+			srv.Inject(ethtest.Fault{Method: method, Status: http.StatusTooManyRequests, RetryAfter: time.Second})
+		}
+	})
+	err = newTestIndexer(t, srv, store, Config{}).Run(ctx, 0, nil, 10)
+	var herr rpc.HTTPError
+	if !errors.As(err, &herr) || herr.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("Run error mismatch: got %v, want HTTP 429", err)
+	}
+	checkSynced(t, srv, store, 9)
+
+	srv.OnCall(nil)
+	if err := newTestIndexer(t, srv, store, Config{}).Run(ctx, 0, nil, 10); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	checkSynced(t, srv, store, 20)
+}
+
+func TestIndexer_RetriesTransientErrors(t *testing.T) {
+	srv := ethtest.NewServer(t)
+	mineChain(srv, 30, 0)
+	store := memory.New()
+
+	client, err := eth.Dial(context.Background(), srv.URL, eth.RetryPolicy{MaxRetries: 3, BaseDelay: time.Millisecond})
+	if err != nil {
+		t.Fatalf("Failed to dial fake node: %v", err)
+	}
+	t.Cleanup(client.Close)
+
+	// a blip on every kind of call the indexer makes
+	srv.Inject(ethtest.Fault{Method: "eth_blockNumber", Status: http.StatusBadGateway})
+	srv.Inject(ethtest.Fault{Method: "eth_getLogs", Times: 2, Message: "request timed out"})
+	srv.Inject(ethtest.Fault{Method: "eth_getBlockByHash", Status: http.StatusTooManyRequests})
+	srv.Inject(ethtest.Fault{Method: "eth_getBlockByNumber", Status: http.StatusServiceUnavailable})
+
+	idx := New(client, store, contract, topic, Config{})
+	if err := idx.Run(context.Background(), 0, nil, 10); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	checkSynced(t, srv, store, 30)
+
+	var retries uint64
+	for _, s := range client.RetryStats() {
+		retries += s.Retries
+	}
+	if retries != 5 {
+		t.Errorf("retries mismatch: got %d, want 5", retries)
+	}
+}
+
+func TestIndexer_LogLimits(t *testing.T) {
+	srv := ethtest.NewServer(t)
+	mineChain(srv, 60, 0)
+	srv.LimitLogs(6, 0)
+	store := memory.New()
+
+	// every batch of 20 blocks holds more than 6 events, so each is split
+	idx := newTestIndexer(t, srv, store, Config{})
+	if err := idx.Run(context.Background(), 0, nil, 20); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	checkSynced(t, srv, store, 60)
+}
+
+func TestIndexer_FinalizedBound(t *testing.T) {
+	ctx := context.Background()
+	srv := ethtest.NewServer(t)
+	mineChain(srv, 30, 0)
+	srv.SetFinalized(20)
+	store := memory.New()
+
+	cfg := Config{Target: TargetFinalized, Confirmations: 2}
+	if err := newTestIndexer(t, srv, store, cfg).Run(ctx, 0, nil, 10); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	checkSynced(t, srv, store, 18)
+
+	bound, err := store.GetSyncBound(ctx)
+	want := storage.SyncBound{Tag: TargetFinalized, Confirmations: 2, Number: 18}
+	if err != nil || bound == nil || *bound != want {
+		t.Errorf("sync bound mismatch: got %+v (%v), want %+v", bound, err, want)
+	}
+}
+
+func TestIndexer_BoundWithoutBatch(t *testing.T) {
+	ctx := context.Background()
+	srv := ethtest.NewServer(t)
+	mineChain(srv, 30, 0)
+	srv.SetFinalized(20)
+	store := memory.New()
+
+	if err := newTestIndexer(t, srv, store, Config{}).Run(ctx, 0, nil, 10); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	checkSynced(t, srv, store, 30)
+
+	// the stricter bound is behind the checkpoint, so there is no batch to
+	// carry it, but the metadata must still show it
+	cfg := Config{Target: TargetFinalized, Confirmations: 2}
+	if err := newTestIndexer(t, srv, store, cfg).Run(ctx, 0, nil, 10); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	checkSynced(t, srv, store, 30)
+
+	bound, err := store.GetSyncBound(ctx)
+	want := storage.SyncBound{Tag: TargetFinalized, Confirmations: 2, Number: 18}
+	if err != nil || bound == nil || *bound != want {
+		t.Errorf("sync bound mismatch: got %+v (%v), want %+v", bound, err, want)
+	}
+}
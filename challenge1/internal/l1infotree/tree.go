@@ -0,0 +1,188 @@
+// Package l1infotree maintains the 32-level append-only sparse Merkle tree
+// whose leaves are the L1 info tree updates, and builds inclusion proofs.
+package l1infotree
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Height is the number of levels above the leaves.
+const Height = 32
+
+// zeroHashes[l] is the root of an empty subtree at level l.
+var zeroHashes = func() [Height + 1]common.Hash {
+	var z [Height + 1]common.Hash
+	for l := 1; l <= Height; l++ {
+		z[l] = hashPair(z[l-1], z[l-1])
+	}
+	return z
+}()
+
+// Node is a tree node as persisted: level 0 holds the leaves and level
+// Height the root. Pos is the node's position within its level.
+type Node struct {
+	Level uint8
+	Pos   uint64
+	Hash  common.Hash
+}
+
+// NodeReader reads persisted nodes. Every node whose subtree is full must
+// be readable; nodes of partially filled subtrees are never trusted.
+type NodeReader interface {
+	GetTreeNode(ctx context.Context, level uint8, pos uint64) (common.Hash, error)
+}
+
+// LeafHash computes keccak256(globalExitRoot, parentHash, blockTime) with
+// the timestamp packed as a big-endian uint64.
+func LeafHash(globalExitRoot, parentHash common.Hash, blockTime uint64) common.Hash {
+	var ts [8]byte
+	binary.BigEndian.PutUint64(ts[:], blockTime)
+	return crypto.Keccak256Hash(globalExitRoot[:], parentHash[:], ts[:])
+}
+
+// Tree appends leaves incrementally. It keeps only the frontier: the root
+// of the most recent full subtree at each level.
+type Tree struct {
+	frontier [Height]common.Hash
+	count    uint64
+}
+
+// New returns an empty tree.
+func New() *Tree {
+	return &Tree{}
+}
+
+// Load restores a tree holding count leaves from persisted nodes.
+func Load(ctx context.Context, r NodeReader, count uint64) (*Tree, error) {
+	t := &Tree{count: count}
+	for l := 0; l < Height; l++ {
+		if (count>>l)&1 == 0 {
+			continue
+		}
+		h, err := r.GetTreeNode(ctx, uint8(l), (count>>l)-1)
+		if err != nil {
+			return nil, fmt.Errorf("load l1 info tree node (%d, %d): %w", l, (count>>l)-1, err)
+		}
+		t.frontier[l] = h
+	}
+	return t, nil
+}
+
+// Count returns the number of leaves.
+func (t *Tree) Count() uint64 {
+	return t.count
+}
+
+// Root returns the current root.
+func (t *Tree) Root() common.Hash {
+	node := zeroHashes[0]
+	for l := 0; l < Height; l++ {
+		if (t.count>>l)&1 == 1 {
+			node = hashPair(t.frontier[l], node)
+		} else {
+			node = hashPair(node, zeroHashes[l])
+		}
+	}
+	return node
+}
+
+// Append adds a leaf and returns the root after it together with every
+// node on the leaf's path, which is exactly the set of nodes that changed.
+func (t *Tree) Append(leaf common.Hash) ([]Node, common.Hash) {
+	index := t.count
+	nodes := make([]Node, 0, Height+1)
+	nodes = append(nodes, Node{Level: 0, Pos: index, Hash: leaf})
+
+	// the new leaf is the rightmost one, so every right sibling is empty
+	cur := leaf
+	for l := 0; l < Height; l++ {
+		if (index>>l)&1 == 1 {
+			cur = hashPair(t.frontier[l], cur)
+		} else {
+			cur = hashPair(cur, zeroHashes[l])
+		}
+		nodes = append(nodes, Node{Level: uint8(l + 1), Pos: index >> (l + 1), Hash: cur})
+	}
+
+	// the lowest set bit of the new count marks the subtree that just filled up
+	t.count++
+	for l := 0; l < Height; l++ {
+		if (t.count>>l)&1 == 1 {
+			t.frontier[l] = nodes[l].Hash
+			break
+		}
+	}
+
+	return nodes, cur
+}
+
+// Proof returns the sibling path of leaf index against the root of the
+// first count leaves, so proofs can target any historical root.
+func Proof(ctx context.Context, r NodeReader, index, count uint64) ([Height]common.Hash, error) {
+	var proof [Height]common.Hash
+	if index >= count {
+		return proof, fmt.Errorf("leaf %d out of range for %d leaves", index, count)
+	}
+
+	for l := 0; l < Height; l++ {
+		h, err := nodeAt(ctx, r, uint8(l), (index>>l)^1, count)
+		if err != nil {
+			return proof, err
+		}
+		proof[l] = h
+	}
+	return proof, nil
+}
+
+// RootAt computes the root of the first count leaves.
+func RootAt(ctx context.Context, r NodeReader, count uint64) (common.Hash, error) {
+	return nodeAt(ctx, r, Height, 0, count)
+}
+
+// VerifyProof checks that leaf sits at index under root.
+func VerifyProof(leaf common.Hash, index uint64, proof [Height]common.Hash, root common.Hash) bool {
+	cur := leaf
+	for l := 0; l < Height; l++ {
+		if (index>>l)&1 == 1 {
+			cur = hashPair(proof[l], cur)
+		} else {
+			cur = hashPair(cur, proof[l])
+		}
+	}
+	return cur == root
+}
+
+// nodeAt returns the node (level, pos) as it was when the tree held count
+// leaves: stored when its subtree was already full, empty when it lay
+// entirely past count, and recomputed from its children otherwise. Only
+// one node per level straddles count, so this is O(Height) reads.
+func nodeAt(ctx context.Context, r NodeReader, level uint8, pos, count uint64) (common.Hash, error) {
+	first := pos << level
+	end := (pos + 1) << level
+
+	switch {
+	case first >= count:
+		return zeroHashes[level], nil
+	case end <= count:
+		return r.GetTreeNode(ctx, level, pos)
+	}
+
+	left, err := nodeAt(ctx, r, level-1, pos*2, count)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	right, err := nodeAt(ctx, r, level-1, pos*2+1, count)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return hashPair(left, right), nil
+}
+
+func hashPair(left, right common.Hash) common.Hash {
+	return crypto.Keccak256Hash(left[:], right[:])
+}
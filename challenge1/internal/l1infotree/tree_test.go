@@ -0,0 +1,140 @@
+package l1infotree
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// mapReader is a NodeReader over nodes collected from Append.
+type mapReader map[[2]uint64]common.Hash
+
+func (m mapReader) GetTreeNode(ctx context.Context, level uint8, pos uint64) (common.Hash, error) {
+	h, ok := m[[2]uint64{uint64(level), pos}]
+	if !ok {
+		return common.Hash{}, fmt.Errorf("node (%d, %d) not found", level, pos)
+	}
+	return h, nil
+}
+
+func (m mapReader) put(nodes []Node) {
+	for _, n := range nodes {
+		m[[2]uint64{uint64(n.Level), n.Pos}] = n.Hash
+	}
+}
+
+// naiveRoot hashes the full tree level by level.
+func naiveRoot(leaves []common.Hash) common.Hash {
+	level := append([]common.Hash(nil), leaves...)
+	for l := 0; l < Height; l++ {
+		if len(level)%2 == 1 {
+			level = append(level, zeroHashes[l])
+		}
+		next := make([]common.Hash, 0, len(level)/2)
+		for i := 0; i < len(level); i += 2 {
+			next = append(next, hashPair(level[i], level[i+1]))
+		}
+		if len(next) == 0 {
+			next = []common.Hash{zeroHashes[l+1]}
+		}
+		level = next
+	}
+	return level[0]
+}
+
+func testLeaves(n int) []common.Hash {
+	leaves := make([]common.Hash, n)
+	for i := range leaves {
+		leaves[i] = LeafHash(common.BigToHash(common.Big1), common.HexToHash(fmt.Sprintf("0x%x", i+1)), uint64(1700000000+i))
+	}
+	return leaves
+}
+
+func TestTree_EmptyRoot(t *testing.T) {
+	if got := New().Root(); got != zeroHashes[Height] {
+		t.Errorf("Empty root mismatch: got %s, want %s", got, zeroHashes[Height])
+	}
+}
+
+func TestTree_AppendMatchesNaiveRoot(t *testing.T) {
+	leaves := testLeaves(37)
+	tree := New()
+
+	for i, leaf := range leaves {
+		_, root := tree.Append(leaf)
+		if want := naiveRoot(leaves[:i+1]); root != want {
+			t.Fatalf("Root after %d leaves: got %s, want %s", i+1, root, want)
+		}
+		if tree.Root() != root {
+			t.Fatalf("Root() disagrees with Append after %d leaves", i+1)
+		}
+	}
+}
+
+func TestTree_ProofsAgainstHistoricalRoots(t *testing.T) {
+	ctx := context.Background()
+	leaves := testLeaves(21)
+	reader := mapReader{}
+	tree := New()
+
+	var roots []common.Hash
+	for _, leaf := range leaves {
+		nodes, root := tree.Append(leaf)
+		reader.put(nodes)
+		roots = append(roots, root)
+	}
+
+	// Every leaf must verify against every root that includes it
+	for count := uint64(1); count <= uint64(len(leaves)); count++ {
+		root, err := RootAt(ctx, reader, count)
+		if err != nil {
+			t.Fatalf("RootAt(%d): %v", count, err)
+		}
+		if root != roots[count-1] {
+			t.Fatalf("RootAt(%d) = %s, want %s", count, root, roots[count-1])
+		}
+
+		for index := uint64(0); index < count; index++ {
+			proof, err := Proof(ctx, reader, index, count)
+			if err != nil {
+				t.Fatalf("Proof(%d, %d): %v", index, count, err)
+			}
+			if !VerifyProof(leaves[index], index, proof, root) {
+				t.Errorf("Proof for leaf %d does not verify against root %d", index, count)
+			}
+		}
+	}
+
+	if _, err := Proof(ctx, reader, 21, 21); err == nil {
+		t.Error("Expected error for leaf index past count")
+	}
+}
+
+func TestTree_LoadResumesAppending(t *testing.T) {
+	ctx := context.Background()
+	leaves := testLeaves(13)
+	reader := mapReader{}
+	tree := New()
+
+	for _, leaf := range leaves[:9] {
+		nodes, _ := tree.Append(leaf)
+		reader.put(nodes)
+	}
+
+	restored, err := Load(ctx, reader, 9)
+	if err != nil {
+		t.Fatalf("Failed to load tree: %v", err)
+	}
+	if restored.Root() != tree.Root() {
+		t.Fatalf("Restored root mismatch: got %s, want %s", restored.Root(), tree.Root())
+	}
+
+	for _, leaf := range leaves[9:] {
+		restored.Append(leaf)
+	}
+	if want := naiveRoot(leaves); restored.Root() != want {
+		t.Errorf("Root after resuming: got %s, want %s", restored.Root(), want)
+	}
+}
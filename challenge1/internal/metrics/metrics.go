@@ -0,0 +1,81 @@
+// Package metrics holds the Prometheus metrics of the indexer. The
+// instrumented packages update them directly; Handler serves them.
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "indexer"
+
+// Registry holds every metric below plus the Go runtime and process
+// collectors. It is separate from the global default registry so that
+// dependencies can't add metrics of their own.
+var Registry = prometheus.NewRegistry()
+
+// Indexer
+var (
+	BlocksProcessed = counter("blocks_processed_total", "Blocks whose logs were fetched and committed.")
+	EventsStored    = counter("events_stored_total", "Events committed to the store.")
+	Reorgs          = counter("reorgs_total", "Reorgs detected and rolled back.")
+
+	IndexedBlock = gauge("indexed_block", "Last block committed as the checkpoint.")
+	HeadBlock    = gauge("head_block", "Chain head reported by eth_blockNumber.")
+	BoundBlock   = gauge("bound_block", "Highest block the indexer may index: the sync target minus confirmations.")
+	Lag          = gauge("lag_blocks", "Blocks between the chain head and the indexed block.")
+)
+
+// Ethereum RPC
+var (
+	RPCRequests = counterVec("rpc_requests_total", "JSON-RPC requests sent, retries included; a batch counts once.", "method")
+	RPCDuration = histogramVec("rpc_request_duration_seconds", "JSON-RPC request latency.", prometheus.DefBuckets, "method")
+	RPCErrors   = counterVec("rpc_errors_total", "Failed JSON-RPC requests by error type: timeout, rate_limit, http, rpc, network or other.", "method", "type")
+	RPCRetries  = counterVec("rpc_retries_total", "Failed JSON-RPC requests that were retried.", "method")
+)
+
+// Bolt storage
+var (
+	BoltWriteDuration = histogramVec("bolt_write_duration_seconds", "Bolt write transaction latency, commit and fsync included.",
+		prometheus.ExponentialBuckets(0.001, 2, 14), "op")
+	DBSize = gauge("db_size_bytes", "Size of the database file.")
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+// Handler serves the registry in the Prometheus text format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
+
+func counter(name, help string) prometheus.Counter {
+	c := prometheus.NewCounter(prometheus.CounterOpts{Namespace: namespace, Name: name, Help: help})
+	Registry.MustRegister(c)
+	return c
+}
+
+func gauge(name, help string) prometheus.Gauge {
+	g := prometheus.NewGauge(prometheus.GaugeOpts{Namespace: namespace, Name: name, Help: help})
+	Registry.MustRegister(g)
+	return g
+}
+
+func counterVec(name, help string, labels ...string) *prometheus.CounterVec {
+	c := prometheus.NewCounterVec(prometheus.CounterOpts{Namespace: namespace, Name: name, Help: help}, labels)
+	Registry.MustRegister(c)
+	return c
+}
+
+func histogramVec(name, help string, buckets []float64, labels ...string) *prometheus.HistogramVec {
+	h := prometheus.NewHistogramVec(prometheus.HistogramOpts{Namespace: namespace, Name: name, Help: help, Buckets: buckets}, labels)
+	Registry.MustRegister(h)
+	return h
+}
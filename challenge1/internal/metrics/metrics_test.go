@@ -0,0 +1,33 @@
+package metrics
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandler(t *testing.T) {
+	BlocksProcessed.Add(3)
+	RPCRequests.WithLabelValues("eth_getLogs").Inc()
+	BoltWriteDuration.WithLabelValues("write_batch").Observe(0.01)
+
+	rec := httptest.NewRecorder()
+	Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body, err := io.ReadAll(rec.Body)
+	if err != nil {
+		t.Fatalf("Failed to read response: %v", err)
+	}
+
+	for _, want := range []string{
+		"indexer_blocks_processed_total 3",
+		`indexer_rpc_requests_total{method="eth_getLogs"} 1`,
+		`indexer_bolt_write_duration_seconds_count{op="write_batch"} 1`,
+		"indexer_lag_blocks 0",
+		"go_goroutines",
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("metrics output missing %q", want)
+		}
+	}
+}
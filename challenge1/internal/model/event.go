@@ -3,39 +3,56 @@ package model
 import (
 	"encoding/binary"
 	"errors"
+	"fmt"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
 )
 
-// IndexedEvent represents a stored L1InfoRoot event with block metadata.
+// IndexedEvent represents a stored UpdateL1InfoTree event with block metadata.
 // Each event is keyed by an incrementing index starting at 0.
 type IndexedEvent struct {
-	Index       uint64      // Sequential index (key in storage)
-	BlockNumber uint64      // Block where the event was emitted
-	BlockTime   uint64      // Timestamp of the block
-	ParentHash  common.Hash // Parent hash of the block
+	Index       uint64      `json:"index"`       // Sequential index (key in storage)
+	BlockNumber uint64      `json:"blockNumber"` // Block where the event was emitted
+	BlockTime   uint64      `json:"blockTime"`   // Timestamp of the block
+	ParentHash  common.Hash `json:"parentHash"`  // Parent hash of the block
 
-	TxHash   common.Hash // Transaction that emitted the event
-	LogIndex uint        // Log index within the block
+	TxHash   common.Hash `json:"txHash"`   // Transaction that emitted the event
+	LogIndex uint        `json:"logIndex"` // Log index within the block
 
-	InfoRoot common.Hash // L1 info root data from the event
+	MainnetExitRoot common.Hash `json:"mainnetExitRoot"` // Topics[1] of the event
+	RollupExitRoot  common.Hash `json:"rollupExitRoot"`  // Topics[2] of the event
+	GlobalExitRoot  common.Hash `json:"globalExitRoot"`  // keccak256(MainnetExitRoot, RollupExitRoot)
 }
 
-// Binary layout (fixed size for efficient storage):
-// - Index:       8 bytes
-// - BlockNumber: 8 bytes
-// - BlockTime:   8 bytes
-// - ParentHash:  32 bytes
-// - TxHash:      32 bytes
-// - LogIndex:    4 bytes (uint32)
-// - InfoRoot:    32 bytes
-// Total: 124 bytes
+// GlobalExitRoot derives the global exit root from its two halves.
+func GlobalExitRoot(mainnetExitRoot, rollupExitRoot common.Hash) common.Hash {
+	return crypto.Keccak256Hash(mainnetExitRoot[:], rollupExitRoot[:])
+}
 
-const binaryEventSize = 8 + 8 + 8 + 32 + 32 + 4 + 32 // 124 bytes
+// Binary layout (fixed size for efficient storage):
+// - Index:           8 bytes
+// - BlockNumber:     8 bytes
+// - BlockTime:       8 bytes
+// - ParentHash:      32 bytes
+// - TxHash:          32 bytes
+// - LogIndex:        4 bytes (uint32)
+// - MainnetExitRoot: 32 bytes
+// - RollupExitRoot:  32 bytes
+// - GlobalExitRoot:  32 bytes
+// Total: 188 bytes
+
+// BinaryEventSize is the length of every encoded event.
+const BinaryEventSize = 8 + 8 + 8 + 32 + 32 + 4 + 32 + 32 + 32 // 188 bytes
+
+// LegacyBinaryEventSize is the length of events encoded before the exit
+// roots were decoded from the event's topics. Those stored a single
+// InfoRoot in place of the three roots, so they can't be converted.
+const LegacyBinaryEventSize = 8 + 8 + 8 + 32 + 32 + 4 + 32 // 124 bytes
 
 // MarshalBinary encodes the event to a compact binary format.
 func (e *IndexedEvent) MarshalBinary() ([]byte, error) {
-	buf := make([]byte, binaryEventSize)
+	buf := make([]byte, BinaryEventSize)
 	offset := 0
 
 	binary.BigEndian.PutUint64(buf[offset:], e.Index)
@@ -56,14 +73,23 @@ func (e *IndexedEvent) MarshalBinary() ([]byte, error) {
 	binary.BigEndian.PutUint32(buf[offset:], uint32(e.LogIndex))
 	offset += 4
 
-	copy(buf[offset:], e.InfoRoot[:])
+	copy(buf[offset:], e.MainnetExitRoot[:])
+	offset += 32
+
+	copy(buf[offset:], e.RollupExitRoot[:])
+	offset += 32
+
+	copy(buf[offset:], e.GlobalExitRoot[:])
 
 	return buf, nil
 }
 
 // UnmarshalBinary decodes an event from its binary representation.
 func (e *IndexedEvent) UnmarshalBinary(data []byte) error {
-	if len(data) != binaryEventSize {
+	if len(data) == LegacyBinaryEventSize {
+		return fmt.Errorf("event in the legacy %d-byte format without exit roots, reindex required", len(data))
+	}
+	if len(data) != BinaryEventSize {
 		return errors.New("invalid binary event size")
 	}
 
@@ -87,7 +113,13 @@ func (e *IndexedEvent) UnmarshalBinary(data []byte) error {
 	e.LogIndex = uint(binary.BigEndian.Uint32(data[offset:]))
 	offset += 4
 
-	copy(e.InfoRoot[:], data[offset:offset+32])
+	copy(e.MainnetExitRoot[:], data[offset:offset+32])
+	offset += 32
+
+	copy(e.RollupExitRoot[:], data[offset:offset+32])
+	offset += 32
+
+	copy(e.GlobalExitRoot[:], data[offset:offset+32])
 
 	return nil
 }
@@ -5,25 +5,49 @@ import (
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"time"
 
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/zacksfF/sepolia-sh/ch1/internal/l1infotree"
+	"github.com/zacksfF/sepolia-sh/ch1/internal/metrics"
 	"github.com/zacksfF/sepolia-sh/ch1/internal/model"
+	"github.com/zacksfF/sepolia-sh/ch1/internal/storage"
 	"go.etcd.io/bbolt"
 )
 
 var (
-	metaBucket   = []byte("meta")
-	eventsBucket = []byte("events")
-	indexKey     = []byte("next_index")
+	metaBucket    = []byte("meta")
+	eventsBucket  = []byte("events")
+	blocksBucket  = []byte("blocks")
+	treeBucket    = []byte("tree")
+	rootsBucket   = []byte("roots")
+	indexKey      = []byte("next_index")
+	checkpointKey = []byte("checkpoint")
+	boundKey      = []byte("sync_bound")
+	formatKey     = []byte("format_version")
 
 	// ErrNotFound is returned when an event doesn't exist
-	ErrNotFound = errors.New("event not found")
+	ErrNotFound = storage.ErrNotFound
 )
 
+// formatVersion is the on-disk format written by this version. Version 1,
+// never stamped, stored events as model.LegacyBinaryEventSize records.
+const formatVersion = 2
+
 // Store implements storage.Store using BoltDB
 type Store struct {
 	db *bbolt.DB
 }
 
+func init() {
+	storage.Register("bolt", func(dsn string, readOnly bool) (storage.Store, error) {
+		if readOnly {
+			return OpenReadOnly(dsn)
+		}
+		return Open(dsn)
+	})
+}
+
 // Open creates a new Store with the given BoltDB file path
 func Open(path string) (*Store, error) {
 	db, err := bbolt.Open(path, 0600, nil)
@@ -38,33 +62,104 @@ func Open(path string) (*Store, error) {
 		if _, err := tx.CreateBucketIfNotExists(eventsBucket); err != nil {
 			return err
 		}
+		if _, err := tx.CreateBucketIfNotExists(blocksBucket); err != nil {
+			return err
+		}
+		if _, err := tx.CreateBucketIfNotExists(treeBucket); err != nil {
+			return err
+		}
+		if _, err := tx.CreateBucketIfNotExists(rootsBucket); err != nil {
+			return err
+		}
+		if err := checkFormat(tx, true); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+
+		// lookup buckets are created together, so one missing means all are
+		backfill := tx.Bucket(byBlockBucket) == nil
+		for _, name := range lookupBuckets {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		if backfill {
+			if err := backfillLookups(tx); err != nil {
+				return err
+			}
+		}
+		metrics.DBSize.Set(float64(tx.Size()))
 		return nil
 	})
 
-	return &Store{db: db}, err
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &Store{db: db}, nil
 }
 
-// GetNextIndex returns the next available event index
-func (s *Store) GetNextIndex(ctx context.Context) (uint64, error) {
-	var idx uint64
+// checkFormat fails with storage.ErrReindexRequired unless the database is
+// in the current format. An unstamped database is stamped if its events
+// are in the current format, or it has none; with stamp false it is only
+// checked.
+func checkFormat(tx *bbolt.Tx, stamp bool) error {
+	meta := tx.Bucket(metaBucket)
+	if v := meta.Get(formatKey); v != nil {
+		if version := binary.BigEndian.Uint64(v); version != formatVersion {
+			return fmt.Errorf("format version %d, this build reads %d: %w", version, formatVersion, storage.ErrReindexRequired)
+		}
+		return nil
+	}
 
-	err := s.db.View(func(tx *bbolt.Tx) error {
-		b := tx.Bucket(metaBucket)
-		v := b.Get(indexKey)
-		if v == nil {
-			idx = 0
-			return nil
+	// Unstamped: new, or written before the format was versioned
+	if _, v := tx.Bucket(eventsBucket).Cursor().First(); v != nil && len(v) != model.BinaryEventSize {
+		return fmt.Errorf("events stored in the legacy %d-byte format without exit roots: %w", len(v), storage.ErrReindexRequired)
+	}
+	if !stamp {
+		return nil
+	}
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, formatVersion)
+	return meta.Put(formatKey, buf)
+}
+
+// OpenReadOnly opens an existing database without taking the writer lock,
+// for readers such as the query server. It fails while a writer holds the
+// file, and if the database was never initialised by the indexer.
+func OpenReadOnly(path string) (*Store, error) {
+	db, err := bbolt.Open(path, 0600, &bbolt.Options{ReadOnly: true, Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.View(func(tx *bbolt.Tx) error {
+		required := [][]byte{metaBucket, eventsBucket, blocksBucket, treeBucket, rootsBucket}
+		for _, name := range append(required, lookupBuckets...) {
+			if tx.Bucket(name) == nil {
+				return fmt.Errorf("bucket %q missing, run the indexer on this database first", name)
+			}
+		}
+		if err := checkFormat(tx, false); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
 		}
-		idx = binary.BigEndian.Uint64(v)
 		return nil
 	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
 
-	return idx, err
+	return &Store{db: db}, nil
+}
+
+// GetNextIndex returns the next available event index
+func (s *Store) GetNextIndex(ctx context.Context) (uint64, error) {
+	return read(s, func(r txReader) (uint64, error) { return r.GetNextIndex(ctx) })
 }
 
 // SetNextIndex updates the next available event index
 func (s *Store) SetNextIndex(ctx context.Context, idx uint64) error {
-	return s.db.Update(func(tx *bbolt.Tx) error {
+	return s.update("set_next_index", func(tx *bbolt.Tx) error {
 		b := tx.Bucket(metaBucket)
 		buf := make([]byte, 8)
 		binary.BigEndian.PutUint64(buf, idx)
@@ -72,7 +167,15 @@ func (s *Store) SetNextIndex(ctx context.Context, idx uint64) error {
 	})
 }
 
-// SaveEvent persists an indexed event using binary serialization
+// SetSyncBound records the bound in effect without writing a batch
+func (s *Store) SetSyncBound(ctx context.Context, bound storage.SyncBound) error {
+	return s.update("set_sync_bound", func(tx *bbolt.Tx) error {
+		return tx.Bucket(metaBucket).Put(boundKey, encodeSyncBound(bound))
+	})
+}
+
+// SaveEvent persists a single event and, for an append, its L1 info tree
+// nodes and root
 func (s *Store) SaveEvent(ctx context.Context, e *model.IndexedEvent) error {
 	if e == nil {
 		return errors.New("nil event")
@@ -83,41 +186,426 @@ func (s *Store) SaveEvent(ctx context.Context, e *model.IndexedEvent) error {
 		return fmt.Errorf("marshal event: %w", err)
 	}
 
-	return s.db.Update(func(tx *bbolt.Tx) error {
-		b := tx.Bucket(eventsBucket)
+	return s.update("save_event", func(tx *bbolt.Tx) error {
+		events := tx.Bucket(eventsBucket)
 
-		key := make([]byte, 8)
-		binary.BigEndian.PutUint64(key, e.Index)
+		var count uint64
+		if k, _ := events.Cursor().Last(); k != nil {
+			count = binary.BigEndian.Uint64(k) + 1
+		}
+		plan, err := storage.PlanSave(ctx, txReader{tx}, count, e)
+		if err != nil {
+			return err
+		}
 
-		return b.Put(key, data)
+		key := uint64Key(e.Index)
+		if err := events.Put(key, data); err != nil {
+			return err
+		}
+		if plan.Old != nil {
+			return storage.ReplaceEvent(lookupTx{tx}, plan.Old, e)
+		}
+
+		tree := tx.Bucket(treeBucket)
+		for _, n := range plan.Nodes {
+			if err := tree.Put(treeKey(n.Level, n.Pos), n.Hash.Bytes()); err != nil {
+				return err
+			}
+		}
+		if err := tx.Bucket(rootsBucket).Put(key, plan.Root.Bytes()); err != nil {
+			return err
+		}
+		if err := tx.Bucket(byInfoRootBucket).Put(plan.Root.Bytes(), key); err != nil {
+			return err
+		}
+		return indexEvent(tx, e)
 	})
 }
 
 // GetEvent retrieves an event by its index
 func (s *Store) GetEvent(ctx context.Context, index uint64) (*model.IndexedEvent, error) {
-	var event model.IndexedEvent
+	return read(s, func(r txReader) (*model.IndexedEvent, error) { return r.GetEvent(ctx, index) })
+}
 
-	err := s.db.View(func(tx *bbolt.Tx) error {
-		b := tx.Bucket(eventsBucket)
+// IterateEvents walks events from..to (inclusive) with a cursor inside one
+// read transaction
+func (s *Store) IterateEvents(ctx context.Context, from, to uint64, fn storage.EventFunc) error {
+	return s.iterate(ctx, from, to, false, fn)
+}
 
-		key := make([]byte, 8)
-		binary.BigEndian.PutUint64(key, index)
+// IterateEventsReverse walks events to..from (inclusive), newest first
+func (s *Store) IterateEventsReverse(ctx context.Context, from, to uint64, fn storage.EventFunc) error {
+	return s.iterate(ctx, from, to, true, fn)
+}
 
-		data := b.Get(key)
-		if data == nil {
-			return ErrNotFound
+func (s *Store) iterate(ctx context.Context, from, to uint64, reverse bool, fn storage.EventFunc) error {
+	if from > to {
+		return nil
+	}
+
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		c := tx.Bucket(eventsBucket).Cursor()
+
+		var k, v []byte
+		if reverse {
+			// Seek lands on the first key >= to+1; step back from there
+			if to == ^uint64(0) {
+				k, v = c.Last()
+			} else if k, v = c.Seek(uint64Key(to + 1)); k == nil {
+				k, v = c.Last()
+			} else {
+				k, v = c.Prev()
+			}
+		} else {
+			k, v = c.Seek(uint64Key(from))
 		}
 
-		return event.UnmarshalBinary(data)
+		for ; k != nil; k, v = step(c, reverse) {
+			idx := binary.BigEndian.Uint64(k)
+			if idx < from || idx > to {
+				return nil
+			}
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			var e model.IndexedEvent
+			if err := e.UnmarshalBinary(v); err != nil {
+				return fmt.Errorf("unmarshal event %d: %w", idx, err)
+			}
+			if err := fn(&e); err != nil {
+				return err
+			}
+		}
+		return nil
 	})
 
-	if err != nil {
-		return nil, err
+	if errors.Is(err, storage.ErrStop) {
+		return nil
 	}
-	return &event, nil
+	return err
+}
+
+func step(c *bbolt.Cursor, reverse bool) ([]byte, []byte) {
+	if reverse {
+		return c.Prev()
+	}
+	return c.Next()
+}
+
+// LatestEvent returns the event with the highest index
+func (s *Store) LatestEvent(ctx context.Context) (*model.IndexedEvent, error) {
+	return read(s, func(r txReader) (*model.IndexedEvent, error) { return r.LatestEvent(ctx) })
+}
+
+// WriteBatch applies a whole sync step in one bbolt transaction: one fsync
+// per batch instead of two per event. Event indices must continue exactly
+// from the stored next_index, which guards against gaps and duplicates.
+func (s *Store) WriteBatch(ctx context.Context, batch *storage.Batch) error {
+	return s.update("write_batch", func(tx *bbolt.Tx) error {
+		meta := tx.Bucket(metaBucket)
+
+		expected := uint64(0)
+		if v := meta.Get(indexKey); v != nil {
+			expected = binary.BigEndian.Uint64(v)
+		}
+
+		events := tx.Bucket(eventsBucket)
+		// keys are strictly increasing, so pack pages full instead of splitting in half
+		events.FillPercent = 1.0
+
+		for _, e := range batch.Events {
+			if e.Index != expected {
+				return fmt.Errorf("non-contiguous event index %d, expected %d", e.Index, expected)
+			}
+			data, err := e.MarshalBinary()
+			if err != nil {
+				return fmt.Errorf("marshal event: %w", err)
+			}
+			if err := events.Put(uint64Key(e.Index), data); err != nil {
+				return err
+			}
+			if err := indexEvent(tx, e); err != nil {
+				return err
+			}
+			expected++
+		}
+		if batch.NextIndex != expected {
+			return fmt.Errorf("batch next index %d, expected %d", batch.NextIndex, expected)
+		}
+
+		tree := tx.Bucket(treeBucket)
+		for _, n := range batch.TreeNodes {
+			if err := tree.Put(treeKey(n.Level, n.Pos), n.Hash.Bytes()); err != nil {
+				return err
+			}
+		}
+
+		roots := tx.Bucket(rootsBucket)
+		roots.FillPercent = 1.0
+		infoRoots := tx.Bucket(byInfoRootBucket)
+		for k, root := range batch.InfoRoots {
+			key := uint64Key(batch.Events[k].Index)
+			if err := roots.Put(key, root.Bytes()); err != nil {
+				return err
+			}
+			if err := infoRoots.Put(root.Bytes(), key); err != nil {
+				return err
+			}
+		}
+
+		if err := meta.Put(indexKey, uint64Key(batch.NextIndex)); err != nil {
+			return err
+		}
+		if err := meta.Put(checkpointKey, encodeBlockRef(batch.Checkpoint)); err != nil {
+			return err
+		}
+		if batch.Bound != nil {
+			if err := meta.Put(boundKey, encodeSyncBound(*batch.Bound)); err != nil {
+				return err
+			}
+		}
+
+		return putBlocks(tx.Bucket(blocksBucket), batch.Blocks, batch.Window)
+	})
+}
+
+// GetCheckpoint returns the last fully processed block, nil if none
+func (s *Store) GetCheckpoint(ctx context.Context) (*storage.BlockRef, error) {
+	return read(s, func(r txReader) (*storage.BlockRef, error) { return r.GetCheckpoint(ctx) })
+}
+
+// GetSyncBound returns the bound recorded with the last batch, nil if none
+func (s *Store) GetSyncBound(ctx context.Context) (*storage.SyncBound, error) {
+	return read(s, func(r txReader) (*storage.SyncBound, error) { return r.GetSyncBound(ctx) })
+}
+
+// RecentBlocks returns the stored block window, newest first
+func (s *Store) RecentBlocks(ctx context.Context) ([]storage.BlockRef, error) {
+	var refs []storage.BlockRef
+
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		c := tx.Bucket(blocksBucket).Cursor()
+		for k, v := c.Last(); k != nil; k, v = c.Prev() {
+			refs = append(refs, storage.BlockRef{
+				Number: binary.BigEndian.Uint64(k),
+				Hash:   common.BytesToHash(v),
+			})
+		}
+		return nil
+	})
+
+	return refs, err
+}
+
+// Rollback deletes all events, their lookup entries, block hashes and L1
+// info tree entries above the given block, rewinds next_index to the first removed event and resets
+// the checkpoint to the given block, all in one transaction.
+func (s *Store) Rollback(ctx context.Context, block uint64) (uint64, error) {
+	var next uint64
+
+	err := s.update("rollback", func(tx *bbolt.Tx) error {
+		meta := tx.Bucket(metaBucket)
+		if v := meta.Get(indexKey); v != nil {
+			next = binary.BigEndian.Uint64(v)
+		}
+
+		events := tx.Bucket(eventsBucket)
+		c := events.Cursor()
+		k, v := c.Last()
+		prev := func() (*model.IndexedEvent, error) {
+			if k == nil {
+				return nil, nil
+			}
+			var e model.IndexedEvent
+			if err := e.UnmarshalBinary(v); err != nil {
+				return nil, fmt.Errorf("unmarshal event: %w", err)
+			}
+			k, v = c.Prev()
+			return &e, nil
+		}
+		var err error
+		next, err = storage.RollbackTail(next, block, prev, func(e *model.IndexedEvent) error {
+			if err := unindexEvent(tx, e); err != nil {
+				return err
+			}
+			return events.Delete(uint64Key(e.Index))
+		})
+		if err != nil {
+			return err
+		}
+
+		blocks := tx.Bucket(blocksBucket)
+		var stale [][]byte
+		bc := blocks.Cursor()
+		for k, _ := bc.Seek(uint64Key(block + 1)); k != nil; k, _ = bc.Next() {
+			stale = append(stale, k)
+		}
+		for _, k := range stale {
+			if err := blocks.Delete(k); err != nil {
+				return err
+			}
+		}
+
+		hash := blocks.Get(uint64Key(block))
+		if cp := storage.RollbackCheckpoint(block, common.BytesToHash(hash), hash != nil); cp != nil {
+			if err := meta.Put(checkpointKey, encodeBlockRef(*cp)); err != nil {
+				return err
+			}
+		} else if err := meta.Delete(checkpointKey); err != nil {
+			return err
+		}
+
+		if err := truncateTree(tx, next); err != nil {
+			return err
+		}
+
+		return meta.Put(indexKey, uint64Key(next))
+	})
+
+	return next, err
+}
+
+// GetTreeNode returns a stored L1 info tree node
+func (s *Store) GetTreeNode(ctx context.Context, level uint8, pos uint64) (common.Hash, error) {
+	return read(s, func(r txReader) (common.Hash, error) { return r.GetTreeNode(ctx, level, pos) })
+}
+
+// GetInfoRoot returns the L1 info root right after the event at index
+func (s *Store) GetInfoRoot(ctx context.Context, index uint64) (common.Hash, error) {
+	return read(s, func(r txReader) (common.Hash, error) { return r.GetInfoRoot(ctx, index) })
 }
 
 // Close releases the database resources
 func (s *Store) Close() error {
 	return s.db.Close()
 }
+
+// update runs fn in a write transaction and records its latency, commit
+// included, and the database size after it
+func (s *Store) update(op string, fn func(*bbolt.Tx) error) error {
+	var size int64
+	start := time.Now()
+	err := s.db.Update(func(tx *bbolt.Tx) error {
+		if err := fn(tx); err != nil {
+			return err
+		}
+		size = tx.Size()
+		return nil
+	})
+	metrics.BoltWriteDuration.WithLabelValues(op).Observe(time.Since(start).Seconds())
+	if err == nil {
+		metrics.DBSize.Set(float64(size))
+	}
+	return err
+}
+
+func uint64Key(v uint64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, v)
+	return key
+}
+
+// encodeBlockRef packs a block ref as number (8 bytes) + hash (32 bytes)
+func encodeBlockRef(ref storage.BlockRef) []byte {
+	buf := make([]byte, 8+common.HashLength)
+	binary.BigEndian.PutUint64(buf, ref.Number)
+	copy(buf[8:], ref.Hash[:])
+	return buf
+}
+
+func decodeBlockRef(data []byte) (storage.BlockRef, error) {
+	if len(data) != 8+common.HashLength {
+		return storage.BlockRef{}, errors.New("invalid block ref size")
+	}
+	return storage.BlockRef{
+		Number: binary.BigEndian.Uint64(data),
+		Hash:   common.BytesToHash(data[8:]),
+	}, nil
+}
+
+// putBlocks records window entries and prunes all but the newest keep
+func putBlocks(b *bbolt.Bucket, refs []storage.BlockRef, keep int) error {
+	for _, ref := range refs {
+		if err := b.Put(uint64Key(ref.Number), ref.Hash.Bytes()); err != nil {
+			return err
+		}
+	}
+
+	// walk from the newest entry and drop everything past the window
+	var stale [][]byte
+	c := b.Cursor()
+	n := 0
+	for k, _ := c.Last(); k != nil; k, _ = c.Prev() {
+		n++
+		if n > keep {
+			stale = append(stale, k)
+		}
+	}
+	for _, k := range stale {
+		if err := b.Delete(k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// encodeSyncBound packs a bound as number (8) + confirmations (8) + tag
+func encodeSyncBound(b storage.SyncBound) []byte {
+	buf := make([]byte, 16+len(b.Tag))
+	binary.BigEndian.PutUint64(buf, b.Number)
+	binary.BigEndian.PutUint64(buf[8:], b.Confirmations)
+	copy(buf[16:], b.Tag)
+	return buf
+}
+
+func decodeSyncBound(data []byte) (storage.SyncBound, error) {
+	if len(data) < 16 {
+		return storage.SyncBound{}, errors.New("invalid sync bound size")
+	}
+	return storage.SyncBound{
+		Number:        binary.BigEndian.Uint64(data),
+		Confirmations: binary.BigEndian.Uint64(data[8:]),
+		Tag:           string(data[16:]),
+	}, nil
+}
+
+// treeKey orders nodes by level, then position
+func treeKey(level uint8, pos uint64) []byte {
+	key := make([]byte, 9)
+	key[0] = level
+	binary.BigEndian.PutUint64(key[1:], pos)
+	return key
+}
+
+// truncateTree drops roots and tree nodes that only cover leaves at or
+// past count.
+func truncateTree(tx *bbolt.Tx, count uint64) error {
+	roots := tx.Bucket(rootsBucket)
+	var stale [][]byte
+	c := roots.Cursor()
+	for k, _ := c.Seek(uint64Key(count)); k != nil; k, _ = c.Next() {
+		stale = append(stale, k)
+	}
+	for _, k := range stale {
+		if err := roots.Delete(k); err != nil {
+			return err
+		}
+	}
+
+	tree := tx.Bucket(treeBucket)
+	stale = stale[:0]
+	tc := tree.Cursor()
+	for level := 0; level <= l1infotree.Height; level++ {
+		first := storage.FirstStaleNode(count, uint8(level))
+		for k, _ := tc.Seek(treeKey(uint8(level), first)); k != nil && k[0] == uint8(level); k, _ = tc.Next() {
+			stale = append(stale, k)
+		}
+	}
+	for _, k := range stale {
+		if err := tree.Delete(k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
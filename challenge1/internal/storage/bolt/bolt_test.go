@@ -2,16 +2,26 @@ package bolt
 
 import (
 	"context"
-	"os"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"path/filepath"
 	"testing"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/zacksfF/sepolia-sh/ch1/internal/l1infotree"
+	"github.com/zacksfF/sepolia-sh/ch1/internal/metrics"
 	"github.com/zacksfF/sepolia-sh/ch1/internal/model"
+	"github.com/zacksfF/sepolia-sh/ch1/internal/storage"
+	"go.etcd.io/bbolt"
 )
 
 func TestStore_SaveAndGetEvent(t *testing.T) {
-	dbPath := "test_bolt.db"
-	defer os.Remove(dbPath)
+	dbPath := filepath.Join(t.TempDir(), "test_bolt.db")
 
 	store, err := Open(dbPath)
 	if err != nil {
@@ -23,14 +33,16 @@ func TestStore_SaveAndGetEvent(t *testing.T) {
 
 	// Create a test event
 	event := &model.IndexedEvent{
-		Index:       0,
-		BlockNumber: 12345,
-		BlockTime:   1700000000,
-		ParentHash:  common.HexToHash("0x1234567890abcdef1234567890abcdef1234567890abcdef1234567890abcdef"),
-		TxHash:      common.HexToHash("0xabcdef1234567890abcdef1234567890abcdef1234567890abcdef1234567890"),
-		LogIndex:    2,
-		InfoRoot:    common.HexToHash("0xdeadbeef1234567890abcdef1234567890abcdef1234567890abcdef12345678"),
+		Index:           0,
+		BlockNumber:     12345,
+		BlockTime:       1700000000,
+		ParentHash:      common.HexToHash("0x1234567890abcdef1234567890abcdef1234567890abcdef1234567890abcdef"),
+		TxHash:          common.HexToHash("0xabcdef1234567890abcdef1234567890abcdef1234567890abcdef1234567890"),
+		LogIndex:        2,
+		MainnetExitRoot: common.HexToHash("0xdeadbeef1234567890abcdef1234567890abcdef1234567890abcdef12345678"),
+		RollupExitRoot:  common.HexToHash("0xfeedface1234567890abcdef1234567890abcdef1234567890abcdef12345678"),
 	}
+	event.GlobalExitRoot = model.GlobalExitRoot(event.MainnetExitRoot, event.RollupExitRoot)
 
 	// Save the event
 	if err := store.SaveEvent(ctx, event); err != nil {
@@ -62,14 +74,19 @@ func TestStore_SaveAndGetEvent(t *testing.T) {
 	if retrieved.LogIndex != event.LogIndex {
 		t.Errorf("LogIndex mismatch: got %d, want %d", retrieved.LogIndex, event.LogIndex)
 	}
-	if retrieved.InfoRoot != event.InfoRoot {
-		t.Errorf("InfoRoot mismatch: got %s, want %s", retrieved.InfoRoot, event.InfoRoot)
+	if retrieved.MainnetExitRoot != event.MainnetExitRoot {
+		t.Errorf("MainnetExitRoot mismatch: got %s, want %s", retrieved.MainnetExitRoot, event.MainnetExitRoot)
+	}
+	if retrieved.RollupExitRoot != event.RollupExitRoot {
+		t.Errorf("RollupExitRoot mismatch: got %s, want %s", retrieved.RollupExitRoot, event.RollupExitRoot)
+	}
+	if retrieved.GlobalExitRoot != event.GlobalExitRoot {
+		t.Errorf("GlobalExitRoot mismatch: got %s, want %s", retrieved.GlobalExitRoot, event.GlobalExitRoot)
 	}
 }
 
 func TestStore_GetEventNotFound(t *testing.T) {
-	dbPath := "test_bolt_notfound.db"
-	defer os.Remove(dbPath)
+	dbPath := filepath.Join(t.TempDir(), "test_bolt_notfound.db")
 
 	store, err := Open(dbPath)
 	if err != nil {
@@ -87,8 +104,7 @@ func TestStore_GetEventNotFound(t *testing.T) {
 }
 
 func TestStore_IndexManagement(t *testing.T) {
-	dbPath := "test_bolt_index.db"
-	defer os.Remove(dbPath)
+	dbPath := filepath.Join(t.TempDir(), "test_bolt_index.db")
 
 	store, err := Open(dbPath)
 	if err != nil {
@@ -123,8 +139,7 @@ func TestStore_IndexManagement(t *testing.T) {
 }
 
 func TestStore_MultipleEventsPerBlock(t *testing.T) {
-	dbPath := "test_bolt_multi.db"
-	defer os.Remove(dbPath)
+	dbPath := filepath.Join(t.TempDir(), "test_bolt_multi.db")
 
 	store, err := Open(dbPath)
 	if err != nil {
@@ -138,31 +153,31 @@ func TestStore_MultipleEventsPerBlock(t *testing.T) {
 	// Simulate multiple events from the same block (different log indices)
 	events := []*model.IndexedEvent{
 		{
-			Index:       0,
-			BlockNumber: 100,
-			BlockTime:   1700000000,
-			ParentHash:  blockHash,
-			TxHash:      common.HexToHash("0x1111"),
-			LogIndex:    0,
-			InfoRoot:    common.HexToHash("0x2222"),
+			Index:           0,
+			BlockNumber:     100,
+			BlockTime:       1700000000,
+			ParentHash:      blockHash,
+			TxHash:          common.HexToHash("0x1111"),
+			LogIndex:        0,
+			MainnetExitRoot: common.HexToHash("0x2222"),
 		},
 		{
-			Index:       1,
-			BlockNumber: 100, // Same block
-			BlockTime:   1700000000,
-			ParentHash:  blockHash,
-			TxHash:      common.HexToHash("0x1111"),
-			LogIndex:    1, // Different log index
-			InfoRoot:    common.HexToHash("0x3333"),
+			Index:           1,
+			BlockNumber:     100, // Same block
+			BlockTime:       1700000000,
+			ParentHash:      blockHash,
+			TxHash:          common.HexToHash("0x1111"),
+			LogIndex:        1, // Different log index
+			MainnetExitRoot: common.HexToHash("0x3333"),
 		},
 		{
-			Index:       2,
-			BlockNumber: 100, // Same block
-			BlockTime:   1700000000,
-			ParentHash:  blockHash,
-			TxHash:      common.HexToHash("0x1111"),
-			LogIndex:    2, // Different log index
-			InfoRoot:    common.HexToHash("0x4444"),
+			Index:           2,
+			BlockNumber:     100, // Same block
+			BlockTime:       1700000000,
+			ParentHash:      blockHash,
+			TxHash:          common.HexToHash("0x1111"),
+			LogIndex:        2, // Different log index
+			MainnetExitRoot: common.HexToHash("0x4444"),
 		},
 	}
 
@@ -187,3 +202,519 @@ func TestStore_MultipleEventsPerBlock(t *testing.T) {
 		}
 	}
 }
+
+func TestStore_BlockWindow(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test_bolt_window.db")
+
+	store, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer store.Close()
+
+	ctx := context.Background()
+
+	// Save 5 blocks but keep only the newest 3
+	var refs []storage.BlockRef
+	for n := uint64(1); n <= 5; n++ {
+		refs = append(refs, storage.BlockRef{Number: n * 10, Hash: common.BigToHash(new(big.Int).SetUint64(n))})
+	}
+	batch := &storage.Batch{Checkpoint: refs[len(refs)-1], Blocks: refs, Window: 3}
+	if err := store.WriteBatch(ctx, batch); err != nil {
+		t.Fatalf("Failed to save blocks: %v", err)
+	}
+
+	got, err := store.RecentBlocks(ctx)
+	if err != nil {
+		t.Fatalf("Failed to get recent blocks: %v", err)
+	}
+	if len(got) != 3 {
+		t.Fatalf("Expected 3 blocks in window, got %d", len(got))
+	}
+
+	// Newest first
+	for i, want := range []uint64{50, 40, 30} {
+		if got[i].Number != want {
+			t.Errorf("Block %d: got number %d, want %d", i, got[i].Number, want)
+		}
+	}
+}
+
+func TestStore_Rollback(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test_bolt_rollback.db")
+
+	store, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer store.Close()
+
+	ctx := context.Background()
+
+	// Two events in block 100, one in 101, two in 102
+	blocks := []uint64{100, 100, 101, 102, 102}
+	var events []*model.IndexedEvent
+	for i, bn := range blocks {
+		events = append(events, &model.IndexedEvent{Index: uint64(i), BlockNumber: bn})
+	}
+
+	refs := []storage.BlockRef{
+		{Number: 100, Hash: common.HexToHash("0x100")},
+		{Number: 101, Hash: common.HexToHash("0x101")},
+		{Number: 102, Hash: common.HexToHash("0x102")},
+	}
+	batch := &storage.Batch{
+		Events:     events,
+		NextIndex:  uint64(len(events)),
+		Checkpoint: refs[2],
+		Blocks:     refs,
+		Window:     10,
+	}
+	if err := store.WriteBatch(ctx, batch); err != nil {
+		t.Fatalf("Failed to write batch: %v", err)
+	}
+
+	// Block 100 is the common ancestor, 101 and 102 were orphaned
+	next, err := store.Rollback(ctx, 100)
+	if err != nil {
+		t.Fatalf("Failed to roll back: %v", err)
+	}
+	if next != 2 {
+		t.Errorf("Expected next index 2 after rollback, got %d", next)
+	}
+
+	idx, err := store.GetNextIndex(ctx)
+	if err != nil {
+		t.Fatalf("Failed to get next index: %v", err)
+	}
+	if idx != 2 {
+		t.Errorf("Expected stored next index 2, got %d", idx)
+	}
+
+	for i := uint64(0); i < 2; i++ {
+		if _, err := store.GetEvent(ctx, i); err != nil {
+			t.Errorf("Event %d should survive rollback: %v", i, err)
+		}
+	}
+	for i := uint64(2); i < 5; i++ {
+		if _, err := store.GetEvent(ctx, i); err != ErrNotFound {
+			t.Errorf("Event %d should be rolled back, got: %v", i, err)
+		}
+	}
+
+	window, err := store.RecentBlocks(ctx)
+	if err != nil {
+		t.Fatalf("Failed to get recent blocks: %v", err)
+	}
+	if len(window) != 1 || window[0].Number != 100 {
+		t.Errorf("Expected window to end at block 100, got %+v", window)
+	}
+
+	// The checkpoint moves back to the ancestor
+	cp, err := store.GetCheckpoint(ctx)
+	if err != nil {
+		t.Fatalf("Failed to get checkpoint: %v", err)
+	}
+	if cp == nil || *cp != refs[0] {
+		t.Errorf("Expected checkpoint %+v after rollback, got %+v", refs[0], cp)
+	}
+}
+
+func TestStore_WriteBatchCheckpoint(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test_bolt_checkpoint.db")
+
+	store, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer store.Close()
+
+	ctx := context.Background()
+
+	// Fresh store has no checkpoint
+	cp, err := store.GetCheckpoint(ctx)
+	if err != nil {
+		t.Fatalf("Failed to get checkpoint: %v", err)
+	}
+	if cp != nil {
+		t.Fatalf("Expected no checkpoint, got %+v", cp)
+	}
+
+	events := []*model.IndexedEvent{
+		{Index: 0, BlockNumber: 10},
+		{Index: 1, BlockNumber: 12},
+	}
+	want := storage.BlockRef{Number: 4999, Hash: common.HexToHash("0xc0ffee")}
+	if err := store.WriteBatch(ctx, &storage.Batch{Events: events, NextIndex: 2, Checkpoint: want}); err != nil {
+		t.Fatalf("Failed to write batch: %v", err)
+	}
+
+	cp, err = store.GetCheckpoint(ctx)
+	if err != nil {
+		t.Fatalf("Failed to get checkpoint: %v", err)
+	}
+	if cp == nil || *cp != want {
+		t.Errorf("Checkpoint mismatch: got %+v, want %+v", cp, want)
+	}
+
+	idx, err := store.GetNextIndex(ctx)
+	if err != nil {
+		t.Fatalf("Failed to get next index: %v", err)
+	}
+	if idx != 2 {
+		t.Errorf("Expected next index 2, got %d", idx)
+	}
+
+	// An empty batch still advances the checkpoint but not the index
+	want = storage.BlockRef{Number: 9999, Hash: common.HexToHash("0xbeef")}
+	if err := store.WriteBatch(ctx, &storage.Batch{NextIndex: 2, Checkpoint: want}); err != nil {
+		t.Fatalf("Failed to write empty batch: %v", err)
+	}
+	cp, err = store.GetCheckpoint(ctx)
+	if err != nil {
+		t.Fatalf("Failed to get checkpoint: %v", err)
+	}
+	if cp == nil || *cp != want {
+		t.Errorf("Checkpoint mismatch: got %+v, want %+v", cp, want)
+	}
+	if idx, _ := store.GetNextIndex(ctx); idx != 2 {
+		t.Errorf("Empty batch changed next index to %d", idx)
+	}
+}
+
+func TestStore_Metrics(t *testing.T) {
+	store, err := Open(filepath.Join(t.TempDir(), "test_bolt_metrics.db"))
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer store.Close()
+
+	writes := func() uint64 {
+		var m dto.Metric
+		if err := metrics.BoltWriteDuration.WithLabelValues("write_batch").(prometheus.Histogram).Write(&m); err != nil {
+			t.Fatalf("Failed to read histogram: %v", err)
+		}
+		return m.GetHistogram().GetSampleCount()
+	}
+	before := writes()
+
+	batch := &storage.Batch{
+		Events:     []*model.IndexedEvent{{Index: 0, BlockNumber: 10}},
+		NextIndex:  1,
+		Checkpoint: storage.BlockRef{Number: 10},
+	}
+	if err := store.WriteBatch(context.Background(), batch); err != nil {
+		t.Fatalf("Failed to write batch: %v", err)
+	}
+
+	if got := writes() - before; got != 1 {
+		t.Errorf("write_batch observations mismatch: got %d, want 1", got)
+	}
+	if size := testutil.ToFloat64(metrics.DBSize); size <= 0 {
+		t.Errorf("DB size mismatch: got %v, want > 0", size)
+	}
+}
+
+func TestStore_WriteBatchRejectsGaps(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test_bolt_gaps.db")
+
+	store, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer store.Close()
+
+	ctx := context.Background()
+
+	// Index 1 without index 0 would leave a hole
+	batch := &storage.Batch{
+		Events:    []*model.IndexedEvent{{Index: 1}},
+		NextIndex: 2,
+	}
+	if err := store.WriteBatch(ctx, batch); err == nil {
+		t.Fatal("Expected error for non-contiguous batch")
+	}
+
+	// Nothing from the failed batch may be visible
+	if _, err := store.GetEvent(ctx, 1); err != ErrNotFound {
+		t.Errorf("Expected ErrNotFound after rejected batch, got: %v", err)
+	}
+	if idx, _ := store.GetNextIndex(ctx); idx != 0 {
+		t.Errorf("Rejected batch changed next index to %d", idx)
+	}
+}
+
+func TestStore_SyncBound(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test_bolt_bound.db")
+
+	store, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer store.Close()
+
+	ctx := context.Background()
+
+	bound, err := store.GetSyncBound(ctx)
+	if err != nil {
+		t.Fatalf("Failed to get sync bound: %v", err)
+	}
+	if bound != nil {
+		t.Fatalf("Expected no sync bound, got %+v", bound)
+	}
+
+	want := storage.SyncBound{Tag: "finalized", Confirmations: 12, Number: 7000000}
+	batch := &storage.Batch{Checkpoint: storage.BlockRef{Number: 7000000}, Bound: &want}
+	if err := store.WriteBatch(ctx, batch); err != nil {
+		t.Fatalf("Failed to write batch: %v", err)
+	}
+
+	bound, err = store.GetSyncBound(ctx)
+	if err != nil {
+		t.Fatalf("Failed to get sync bound: %v", err)
+	}
+	if bound == nil || *bound != want {
+		t.Errorf("Sync bound mismatch: got %+v, want %+v", bound, want)
+	}
+}
+
+func TestStore_L1InfoTree(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test_bolt_tree.db")
+
+	store, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer store.Close()
+
+	ctx := context.Background()
+	tree := l1infotree.New()
+
+	// One event per block 100..105, each batch holding a single event
+	var roots []common.Hash
+	for i := uint64(0); i < 6; i++ {
+		e := &model.IndexedEvent{Index: i, BlockNumber: 100 + i, BlockTime: 1700000000 + i}
+		e.GlobalExitRoot = model.GlobalExitRoot(common.BigToHash(new(big.Int).SetUint64(i)), common.Hash{})
+		nodes, root := tree.Append(l1infotree.LeafHash(e.GlobalExitRoot, e.ParentHash, e.BlockTime))
+		roots = append(roots, root)
+
+		ref := storage.BlockRef{Number: e.BlockNumber, Hash: common.BigToHash(new(big.Int).SetUint64(e.BlockNumber))}
+		batch := &storage.Batch{
+			Events:     []*model.IndexedEvent{e},
+			NextIndex:  i + 1,
+			Checkpoint: ref,
+			Blocks:     []storage.BlockRef{ref},
+			Window:     10,
+			TreeNodes:  nodes,
+			InfoRoots:  []common.Hash{root},
+		}
+		if err := store.WriteBatch(ctx, batch); err != nil {
+			t.Fatalf("Failed to write batch %d: %v", i, err)
+		}
+	}
+
+	for i, want := range roots {
+		got, err := store.GetInfoRoot(ctx, uint64(i))
+		if err != nil {
+			t.Fatalf("Failed to get info root %d: %v", i, err)
+		}
+		if got != want {
+			t.Errorf("Info root %d mismatch: got %s, want %s", i, got, want)
+		}
+	}
+
+	// Orphan blocks 104 and 105: the tree must go back to 4 leaves
+	next, err := store.Rollback(ctx, 103)
+	if err != nil {
+		t.Fatalf("Failed to roll back: %v", err)
+	}
+	if next != 4 {
+		t.Fatalf("Expected next index 4, got %d", next)
+	}
+	if _, err := store.GetInfoRoot(ctx, 4); err != ErrNotFound {
+		t.Errorf("Expected info root 4 to be rolled back, got: %v", err)
+	}
+
+	restored, err := l1infotree.Load(ctx, store, next)
+	if err != nil {
+		t.Fatalf("Failed to load tree: %v", err)
+	}
+	if restored.Root() != roots[3] {
+		t.Errorf("Restored root mismatch: got %s, want %s", restored.Root(), roots[3])
+	}
+
+	proof, err := l1infotree.Proof(ctx, store, 2, next)
+	if err != nil {
+		t.Fatalf("Failed to build proof: %v", err)
+	}
+	e, err := store.GetEvent(ctx, 2)
+	if err != nil {
+		t.Fatalf("Failed to get event: %v", err)
+	}
+	leaf := l1infotree.LeafHash(e.GlobalExitRoot, e.ParentHash, e.BlockTime)
+	if !l1infotree.VerifyProof(leaf, 2, proof, roots[3]) {
+		t.Error("Proof for leaf 2 does not verify after rollback")
+	}
+}
+
+func TestStore_IterateEvents(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test_bolt_iterate.db")
+
+	store, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer store.Close()
+
+	ctx := context.Background()
+
+	var events []*model.IndexedEvent
+	for i := uint64(0); i < 10; i++ {
+		events = append(events, &model.IndexedEvent{Index: i, BlockNumber: 100 + i})
+	}
+	if err := store.WriteBatch(ctx, &storage.Batch{Events: events, NextIndex: 10}); err != nil {
+		t.Fatalf("Failed to write batch: %v", err)
+	}
+
+	collect := func(reverse bool, from, to uint64) []uint64 {
+		var got []uint64
+		fn := func(e *model.IndexedEvent) error {
+			got = append(got, e.Index)
+			return nil
+		}
+		iter := store.IterateEvents
+		if reverse {
+			iter = store.IterateEventsReverse
+		}
+		if err := iter(ctx, from, to, fn); err != nil {
+			t.Fatalf("Failed to iterate %d..%d: %v", from, to, err)
+		}
+		return got
+	}
+
+	tests := []struct {
+		reverse  bool
+		from, to uint64
+		expected []uint64
+	}{
+		{false, 2, 5, []uint64{2, 3, 4, 5}},
+		{true, 2, 5, []uint64{5, 4, 3, 2}},
+		{false, 8, 100, []uint64{8, 9}},
+		{true, 8, 100, []uint64{9, 8}},
+		{true, 0, ^uint64(0), []uint64{9, 8, 7, 6, 5, 4, 3, 2, 1, 0}},
+		{false, 20, 30, nil},
+		{false, 5, 2, nil},
+	}
+
+	for _, tt := range tests {
+		got := collect(tt.reverse, tt.from, tt.to)
+		if fmt.Sprint(got) != fmt.Sprint(tt.expected) {
+			t.Errorf("iterate(reverse=%v, %d, %d) = %v, want %v", tt.reverse, tt.from, tt.to, got, tt.expected)
+		}
+	}
+
+	// ErrStop ends the iteration early without an error
+	var seen int
+	err = store.IterateEvents(ctx, 0, 9, func(e *model.IndexedEvent) error {
+		seen++
+		if seen == 3 {
+			return storage.ErrStop
+		}
+		return nil
+	})
+	if err != nil {
+		t.Errorf("Expected nil error on ErrStop, got: %v", err)
+	}
+	if seen != 3 {
+		t.Errorf("Expected iteration to stop after 3 events, saw %d", seen)
+	}
+}
+
+func TestStore_LatestEvent(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test_bolt_latest.db")
+
+	store, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer store.Close()
+
+	ctx := context.Background()
+
+	if _, err := store.LatestEvent(ctx); err != ErrNotFound {
+		t.Errorf("Expected ErrNotFound on empty store, got: %v", err)
+	}
+
+	events := []*model.IndexedEvent{{Index: 0, BlockNumber: 7}, {Index: 1, BlockNumber: 9}}
+	if err := store.WriteBatch(ctx, &storage.Batch{Events: events, NextIndex: 2}); err != nil {
+		t.Fatalf("Failed to write batch: %v", err)
+	}
+
+	latest, err := store.LatestEvent(ctx)
+	if err != nil {
+		t.Fatalf("Failed to get latest event: %v", err)
+	}
+	if latest.Index != 1 || latest.BlockNumber != 9 {
+		t.Errorf("Latest event mismatch: got index %d block %d", latest.Index, latest.BlockNumber)
+	}
+}
+
+func TestStore_LegacyFormat(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "legacy.db")
+
+	// a database written before the exit roots were decoded: 124-byte
+	// events and no format version
+	db, err := bbolt.Open(dbPath, 0600, nil)
+	if err != nil {
+		t.Fatalf("Failed to create legacy database: %v", err)
+	}
+	err = db.Update(func(tx *bbolt.Tx) error {
+		if _, err := tx.CreateBucket(metaBucket); err != nil {
+			return err
+		}
+		events, err := tx.CreateBucket(eventsBucket)
+		if err != nil {
+			return err
+		}
+		return events.Put(make([]byte, 8), make([]byte, model.LegacyBinaryEventSize))
+	})
+	if err != nil {
+		t.Fatalf("Failed to write legacy event: %v", err)
+	}
+	db.Close()
+
+	if _, err := Open(dbPath); !errors.Is(err, storage.ErrReindexRequired) {
+		t.Fatalf("Open error mismatch: got %v, want ErrReindexRequired", err)
+	}
+}
+
+func TestStore_FormatVersion(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "versioned.db")
+
+	store, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	var version uint64
+	store.db.View(func(tx *bbolt.Tx) error {
+		if v := tx.Bucket(metaBucket).Get(formatKey); v != nil {
+			version = binary.BigEndian.Uint64(v)
+		}
+		return nil
+	})
+	if version != formatVersion {
+		t.Errorf("format version mismatch: got %d, want %d", version, formatVersion)
+	}
+
+	// a newer format is refused rather than misread
+	store.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(metaBucket).Put(formatKey, binary.BigEndian.AppendUint64(nil, formatVersion+1))
+	})
+	store.Close()
+	if _, err := Open(dbPath); !errors.Is(err, storage.ErrReindexRequired) {
+		t.Fatalf("Open error mismatch: got %v, want ErrReindexRequired", err)
+	}
+	if _, err := OpenReadOnly(dbPath); !errors.Is(err, storage.ErrReindexRequired) {
+		t.Fatalf("OpenReadOnly error mismatch: got %v, want ErrReindexRequired", err)
+	}
+}
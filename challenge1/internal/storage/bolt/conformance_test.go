@@ -0,0 +1,25 @@
+package bolt
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/zacksfF/sepolia-sh/ch1/internal/storage"
+	"github.com/zacksfF/sepolia-sh/ch1/internal/storage/storagetest"
+)
+
+func openTest(t *testing.T, dir string) storage.Store {
+	store, err := Open(filepath.Join(dir, "conformance.db"))
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	return store
+}
+
+func TestStore_Conformance(t *testing.T) {
+	storagetest.Run(t, openTest)
+}
+
+func TestStore_Reopen(t *testing.T) {
+	storagetest.RunReopen(t, openTest)
+}
@@ -0,0 +1,187 @@
+package bolt
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/zacksfF/sepolia-sh/ch1/internal/model"
+	"github.com/zacksfF/sepolia-sh/ch1/internal/storage"
+	"go.etcd.io/bbolt"
+)
+
+// Secondary indexes, maintained in the same transaction as the events:
+//   - by_block:     blockNumber -> first index (8) + last index (8)
+//   - by_tx:        txHash + index -> empty, scanned by prefix
+//   - by_ger:       globalExitRoot -> index of its first appearance
+//   - by_info_root: L1 info root -> index that produced it
+var (
+	byBlockBucket    = []byte("by_block")
+	byTxBucket       = []byte("by_tx")
+	byGERBucket      = []byte("by_ger")
+	byInfoRootBucket = []byte("by_info_root")
+
+	lookupBuckets = [][]byte{byBlockBucket, byTxBucket, byGERBucket, byInfoRootBucket}
+)
+
+// EventRangeByBlock returns the first and last index emitted in a block
+func (s *Store) EventRangeByBlock(ctx context.Context, number uint64) (uint64, uint64, error) {
+	var first, last uint64
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		var err error
+		first, last, err = txReader{tx}.EventRangeByBlock(ctx, number)
+		return err
+	})
+	return first, last, err
+}
+
+// IndicesByTx returns every index emitted by a transaction, ascending
+func (s *Store) IndicesByTx(ctx context.Context, hash common.Hash) ([]uint64, error) {
+	return read(s, func(r txReader) ([]uint64, error) { return r.IndicesByTx(ctx, hash) })
+}
+
+// IndexByGlobalExitRoot returns the index at which a global exit root first appeared
+func (s *Store) IndexByGlobalExitRoot(ctx context.Context, root common.Hash) (uint64, error) {
+	return read(s, func(r txReader) (uint64, error) { return r.IndexByGlobalExitRoot(ctx, root) })
+}
+
+// IndexByInfoRoot returns the index whose insertion produced an L1 info root
+func (s *Store) IndexByInfoRoot(ctx context.Context, root common.Hash) (uint64, error) {
+	return read(s, func(r txReader) (uint64, error) { return r.IndexByInfoRoot(ctx, root) })
+}
+
+func (r txReader) EventRangeByBlock(ctx context.Context, number uint64) (uint64, uint64, error) {
+	v := r.tx.Bucket(byBlockBucket).Get(uint64Key(number))
+	if v == nil {
+		return 0, 0, ErrNotFound
+	}
+	return binary.BigEndian.Uint64(v), binary.BigEndian.Uint64(v[8:]), nil
+}
+
+func (r txReader) IndicesByTx(ctx context.Context, hash common.Hash) ([]uint64, error) {
+	var indices []uint64
+	c := r.tx.Bucket(byTxBucket).Cursor()
+	for k, _ := c.Seek(hash.Bytes()); k != nil && common.BytesToHash(k[:common.HashLength]) == hash; k, _ = c.Next() {
+		indices = append(indices, binary.BigEndian.Uint64(k[common.HashLength:]))
+	}
+	if len(indices) == 0 {
+		return nil, ErrNotFound
+	}
+	return indices, nil
+}
+
+func (r txReader) IndexByGlobalExitRoot(ctx context.Context, root common.Hash) (uint64, error) {
+	return r.lookupIndex(byGERBucket, root)
+}
+
+func (r txReader) IndexByInfoRoot(ctx context.Context, root common.Hash) (uint64, error) {
+	return r.lookupIndex(byInfoRootBucket, root)
+}
+
+func (r txReader) lookupIndex(bucket []byte, key common.Hash) (uint64, error) {
+	v := r.tx.Bucket(bucket).Get(key.Bytes())
+	if v == nil {
+		return 0, ErrNotFound
+	}
+	return binary.BigEndian.Uint64(v), nil
+}
+
+// lookupTx maintains the secondary indexes within a write transaction
+type lookupTx struct {
+	tx *bbolt.Tx
+}
+
+var _ storage.LookupWriter = lookupTx{}
+
+func (l lookupTx) BlockRange(number uint64) (uint64, uint64, error) {
+	v := l.tx.Bucket(byBlockBucket).Get(uint64Key(number))
+	if v == nil {
+		return 0, 0, ErrNotFound
+	}
+	return binary.BigEndian.Uint64(v), binary.BigEndian.Uint64(v[8:]), nil
+}
+
+func (l lookupTx) PutBlockRange(number, first, last uint64) error {
+	return l.tx.Bucket(byBlockBucket).Put(uint64Key(number), indexRange(first, last))
+}
+
+func (l lookupTx) DeleteBlockRange(number uint64) error {
+	return l.tx.Bucket(byBlockBucket).Delete(uint64Key(number))
+}
+
+func (l lookupTx) PutTx(hash common.Hash, index uint64) error {
+	return l.tx.Bucket(byTxBucket).Put(txKey(hash, index), nil)
+}
+
+func (l lookupTx) DeleteTx(hash common.Hash, index uint64) error {
+	return l.tx.Bucket(byTxBucket).Delete(txKey(hash, index))
+}
+
+func (l lookupTx) GERIndex(root common.Hash) (uint64, error) {
+	v := l.tx.Bucket(byGERBucket).Get(root.Bytes())
+	if v == nil {
+		return 0, ErrNotFound
+	}
+	return binary.BigEndian.Uint64(v), nil
+}
+
+func (l lookupTx) PutGERIndex(root common.Hash, index uint64) error {
+	return l.tx.Bucket(byGERBucket).Put(root.Bytes(), uint64Key(index))
+}
+
+func (l lookupTx) DeleteGERIndex(root common.Hash) error {
+	return l.tx.Bucket(byGERBucket).Delete(root.Bytes())
+}
+
+// indexEvent adds an event to the block, tx and global exit root indexes
+func indexEvent(tx *bbolt.Tx, e *model.IndexedEvent) error {
+	return storage.IndexEvent(lookupTx{tx}, e)
+}
+
+// unindexEvent removes an event being rolled back from every index,
+// including the L1 info root it produced
+func unindexEvent(tx *bbolt.Tx, e *model.IndexedEvent) error {
+	if err := storage.UnindexEvent(lookupTx{tx}, e); err != nil {
+		return err
+	}
+	if root := tx.Bucket(rootsBucket).Get(uint64Key(e.Index)); root != nil {
+		return tx.Bucket(byInfoRootBucket).Delete(root)
+	}
+	return nil
+}
+
+// backfillLookups builds the secondary indexes for databases written
+// before they existed.
+func backfillLookups(tx *bbolt.Tx) error {
+	roots := tx.Bucket(rootsBucket)
+	infoRoots := tx.Bucket(byInfoRootBucket)
+
+	return tx.Bucket(eventsBucket).ForEach(func(k, v []byte) error {
+		var e model.IndexedEvent
+		if err := e.UnmarshalBinary(v); err != nil {
+			return fmt.Errorf("unmarshal event: %w", err)
+		}
+		if err := indexEvent(tx, &e); err != nil {
+			return err
+		}
+		if root := roots.Get(k); root != nil {
+			return infoRoots.Put(root, k)
+		}
+		return nil
+	})
+}
+
+func indexRange(first, last uint64) []byte {
+	buf := make([]byte, 16)
+	binary.BigEndian.PutUint64(buf, first)
+	binary.BigEndian.PutUint64(buf[8:], last)
+	return buf
+}
+
+func txKey(hash common.Hash, index uint64) []byte {
+	key := make([]byte, common.HashLength+8)
+	copy(key, hash[:])
+	binary.BigEndian.PutUint64(key[common.HashLength:], index)
+	return key
+}
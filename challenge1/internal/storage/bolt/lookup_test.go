@@ -0,0 +1,116 @@
+package bolt
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/zacksfF/sepolia-sh/ch1/internal/model"
+	"github.com/zacksfF/sepolia-sh/ch1/internal/storage"
+)
+
+func TestStore_Lookups(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test_bolt_lookups.db")
+
+	store, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer store.Close()
+
+	ctx := context.Background()
+
+	txA := common.HexToHash("0xaaaa")
+	txB := common.HexToHash("0xbbbb")
+	gerX := common.HexToHash("0x1111")
+	gerY := common.HexToHash("0x2222")
+
+	// Block 100 emits two events from txA, block 105 one from txB, block 107
+	// one from txA again. gerX shows up twice.
+	events := []*model.IndexedEvent{
+		{Index: 0, BlockNumber: 100, TxHash: txA, LogIndex: 0, GlobalExitRoot: gerX},
+		{Index: 1, BlockNumber: 100, TxHash: txA, LogIndex: 1, GlobalExitRoot: gerY},
+		{Index: 2, BlockNumber: 105, TxHash: txB, LogIndex: 4, GlobalExitRoot: gerX},
+		{Index: 3, BlockNumber: 107, TxHash: txA, LogIndex: 0, GlobalExitRoot: common.HexToHash("0x3333")},
+	}
+	infoRoots := []common.Hash{
+		common.HexToHash("0xf0"), common.HexToHash("0xf1"), common.HexToHash("0xf2"), common.HexToHash("0xf3"),
+	}
+	batch := &storage.Batch{
+		Events:     events,
+		NextIndex:  4,
+		Checkpoint: storage.BlockRef{Number: 107, Hash: common.HexToHash("0x107")},
+		Blocks: []storage.BlockRef{
+			{Number: 100, Hash: common.HexToHash("0x100")},
+			{Number: 105, Hash: common.HexToHash("0x105")},
+			{Number: 107, Hash: common.HexToHash("0x107")},
+		},
+		Window:    10,
+		InfoRoots: infoRoots,
+	}
+	if err := store.WriteBatch(ctx, batch); err != nil {
+		t.Fatalf("Failed to write batch: %v", err)
+	}
+
+	first, last, err := store.EventRangeByBlock(ctx, 100)
+	if err != nil {
+		t.Fatalf("Failed to look up block 100: %v", err)
+	}
+	if first != 0 || last != 1 {
+		t.Errorf("Block 100 range: got %d..%d, want 0..1", first, last)
+	}
+	if _, _, err := store.EventRangeByBlock(ctx, 101); err != ErrNotFound {
+		t.Errorf("Expected ErrNotFound for block without events, got: %v", err)
+	}
+
+	indices, err := store.IndicesByTx(ctx, txA)
+	if err != nil {
+		t.Fatalf("Failed to look up tx: %v", err)
+	}
+	if fmt.Sprint(indices) != "[0 1 3]" {
+		t.Errorf("IndicesByTx(txA) = %v, want [0 1 3]", indices)
+	}
+
+	idx, err := store.IndexByGlobalExitRoot(ctx, gerX)
+	if err != nil {
+		t.Fatalf("Failed to look up global exit root: %v", err)
+	}
+	if idx != 0 {
+		t.Errorf("gerX should first appear at index 0, got %d", idx)
+	}
+
+	idx, err = store.IndexByInfoRoot(ctx, infoRoots[2])
+	if err != nil {
+		t.Fatalf("Failed to look up info root: %v", err)
+	}
+	if idx != 2 {
+		t.Errorf("IndexByInfoRoot = %d, want 2", idx)
+	}
+
+	// Orphan blocks 105 and 107: their lookups must disappear with them
+	if _, err := store.Rollback(ctx, 100); err != nil {
+		t.Fatalf("Failed to roll back: %v", err)
+	}
+
+	if _, _, err := store.EventRangeByBlock(ctx, 105); err != ErrNotFound {
+		t.Errorf("Expected block 105 lookup to be rolled back, got: %v", err)
+	}
+	indices, err = store.IndicesByTx(ctx, txA)
+	if err != nil {
+		t.Fatalf("Failed to look up tx: %v", err)
+	}
+	if fmt.Sprint(indices) != "[0 1]" {
+		t.Errorf("IndicesByTx(txA) after rollback = %v, want [0 1]", indices)
+	}
+	if _, err := store.IndicesByTx(ctx, txB); err != ErrNotFound {
+		t.Errorf("Expected txB lookup to be rolled back, got: %v", err)
+	}
+	if idx, err := store.IndexByGlobalExitRoot(ctx, gerX); err != nil || idx != 0 {
+		t.Errorf("gerX first appearance should survive rollback, got %d, %v", idx, err)
+	}
+	if _, err := store.IndexByInfoRoot(ctx, infoRoots[2]); err != ErrNotFound {
+		t.Errorf("Expected info root 2 lookup to be rolled back, got: %v", err)
+	}
+}
@@ -0,0 +1,108 @@
+package bolt
+
+import (
+	"context"
+	"encoding/binary"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/zacksfF/sepolia-sh/ch1/internal/model"
+	"github.com/zacksfF/sepolia-sh/ch1/internal/storage"
+	"go.etcd.io/bbolt"
+)
+
+// View runs fn in one read transaction, which sees the database as of its
+// start
+func (s *Store) View(ctx context.Context, fn func(storage.Reader) error) error {
+	return s.db.View(func(tx *bbolt.Tx) error {
+		return fn(txReader{tx})
+	})
+}
+
+// read runs one reader call in its own read transaction
+func read[T any](s *Store, fn func(txReader) (T, error)) (T, error) {
+	var v T
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		var err error
+		v, err = fn(txReader{tx})
+		return err
+	})
+	return v, err
+}
+
+// txReader reads within a read transaction. Values are copied out, since
+// bbolt's are only valid until the transaction ends.
+type txReader struct {
+	tx *bbolt.Tx
+}
+
+func (r txReader) GetNextIndex(ctx context.Context) (uint64, error) {
+	v := r.tx.Bucket(metaBucket).Get(indexKey)
+	if v == nil {
+		return 0, nil
+	}
+	return binary.BigEndian.Uint64(v), nil
+}
+
+func (r txReader) GetEvent(ctx context.Context, index uint64) (*model.IndexedEvent, error) {
+	data := r.tx.Bucket(eventsBucket).Get(uint64Key(index))
+	if data == nil {
+		return nil, ErrNotFound
+	}
+	var event model.IndexedEvent
+	if err := event.UnmarshalBinary(data); err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
+
+func (r txReader) LatestEvent(ctx context.Context) (*model.IndexedEvent, error) {
+	_, data := r.tx.Bucket(eventsBucket).Cursor().Last()
+	if data == nil {
+		return nil, ErrNotFound
+	}
+	var event model.IndexedEvent
+	if err := event.UnmarshalBinary(data); err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
+
+func (r txReader) GetCheckpoint(ctx context.Context) (*storage.BlockRef, error) {
+	v := r.tx.Bucket(metaBucket).Get(checkpointKey)
+	if v == nil {
+		return nil, nil
+	}
+	ref, err := decodeBlockRef(v)
+	if err != nil {
+		return nil, err
+	}
+	return &ref, nil
+}
+
+func (r txReader) GetSyncBound(ctx context.Context) (*storage.SyncBound, error) {
+	v := r.tx.Bucket(metaBucket).Get(boundKey)
+	if v == nil {
+		return nil, nil
+	}
+	b, err := decodeSyncBound(v)
+	if err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+func (r txReader) GetTreeNode(ctx context.Context, level uint8, pos uint64) (common.Hash, error) {
+	return r.getHash(treeBucket, treeKey(level, pos))
+}
+
+func (r txReader) GetInfoRoot(ctx context.Context, index uint64) (common.Hash, error) {
+	return r.getHash(rootsBucket, uint64Key(index))
+}
+
+func (r txReader) getHash(bucket, key []byte) (common.Hash, error) {
+	v := r.tx.Bucket(bucket).Get(key)
+	if v == nil {
+		return common.Hash{}, ErrNotFound
+	}
+	return common.BytesToHash(v), nil
+}
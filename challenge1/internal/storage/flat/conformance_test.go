@@ -0,0 +1,37 @@
+package flat
+
+import (
+	"testing"
+
+	"github.com/zacksfF/sepolia-sh/ch1/internal/storage"
+	"github.com/zacksfF/sepolia-sh/ch1/internal/storage/storagetest"
+)
+
+func openTest(t *testing.T, dir string) storage.Store {
+	store, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	return store
+}
+
+func TestStore_Conformance(t *testing.T) {
+	storagetest.Run(t, openTest)
+}
+
+func TestStore_Reopen(t *testing.T) {
+	storagetest.RunReopen(t, openTest)
+}
+
+// batched syncs must not change what readers see, and Close commits the rest
+func TestStore_ConformanceSyncEvery(t *testing.T) {
+	open := func(t *testing.T, dir string) storage.Store {
+		store, err := OpenOptions(dir, Options{SyncEvery: 3})
+		if err != nil {
+			t.Fatalf("Failed to open store: %v", err)
+		}
+		return store
+	}
+	storagetest.Run(t, open)
+	storagetest.RunReopen(t, open)
+}
@@ -0,0 +1,725 @@
+// Package flat implements storage.Store on append-only fixed-width files.
+//
+// Indices are dense from 0 and every event encodes to exactly
+// model.BinaryEventSize bytes, so event i lives at offset
+// i*model.BinaryEventSize of events.dat and GetEvent is a single pread.
+// The L1 info roots (roots.dat) and the full-subtree tree nodes (tree.dat)
+// are fixed-width and append-only in the same way.
+//
+// A small header file is the commit point: it records how many records of
+// each file are valid, plus the next index, checkpoint, sync bound and
+// reorg window. It is replaced atomically after the data files are synced,
+// so on open any bytes past the committed length, including a torn last
+// record, are truncated away.
+package flat
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/zacksfF/sepolia-sh/ch1/internal/l1infotree"
+	"github.com/zacksfF/sepolia-sh/ch1/internal/model"
+	"github.com/zacksfF/sepolia-sh/ch1/internal/storage"
+)
+
+const (
+	eventsFile = "events.dat"
+	rootsFile  = "roots.dat"
+	treeFile   = "tree.dat"
+	headerFile = "header"
+
+	eventSize = model.BinaryEventSize
+	hashSize  = common.HashLength
+
+	// iterateChunk is how many events one pread fetches while iterating
+	iterateChunk = 256
+)
+
+// Options tune durability against write throughput.
+type Options struct {
+	// SyncEvery commits the header and fsyncs the data files once every
+	// SyncEvery batches instead of after each one. Batches in between are
+	// visible to readers but lost on a crash; the store still reopens at a
+	// consistent earlier checkpoint. Zero or one syncs every batch.
+	SyncEvery int
+}
+
+// Store implements storage.Store on flat files
+type Store struct {
+	dir      string
+	readOnly bool
+	opts     Options
+	lock     *os.File
+
+	events, roots, tree *os.File
+
+	mu      sync.RWMutex
+	state   state // current state, what readers see
+	pending int   // batches written since the last header commit
+
+	// lookups are built by the first lookup, not on open; writers keep
+	// them current once they exist
+	lookupsOnce sync.Once
+	lookups     *storage.Lookups
+	lookupsErr  error
+}
+
+func init() {
+	storage.Register("flat", func(dsn string, readOnly bool) (storage.Store, error) {
+		dir, opts, err := parseDSN(dsn)
+		if err != nil {
+			return nil, err
+		}
+		if readOnly {
+			return OpenReadOnly(dir)
+		}
+		return OpenOptions(dir, opts)
+	})
+}
+
+// parseDSN splits "dir?sync_every=N" into its parts
+func parseDSN(dsn string) (string, Options, error) {
+	var opts Options
+	dir, query, ok := strings.Cut(dsn, "?")
+	if !ok {
+		return dir, opts, nil
+	}
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		return "", opts, fmt.Errorf("flat dsn: %w", err)
+	}
+	if v := values.Get("sync_every"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return "", opts, fmt.Errorf("flat dsn: invalid sync_every %q", v)
+		}
+		opts.SyncEvery = n
+	}
+	return dir, opts, nil
+}
+
+// Open opens or creates a store in dir, syncing every batch
+func Open(dir string) (*Store, error) {
+	return OpenOptions(dir, Options{})
+}
+
+// OpenOptions opens or creates a store in dir
+func OpenOptions(dir string, opts Options) (*Store, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return open(dir, opts, false)
+}
+
+// OpenReadOnly opens an existing store for reading. Like Bolt it fails
+// while a writer holds the store.
+func OpenReadOnly(dir string) (*Store, error) {
+	if _, err := os.Stat(filepath.Join(dir, headerFile)); err != nil {
+		return nil, fmt.Errorf("open flat store: %w", err)
+	}
+	return open(dir, Options{}, true)
+}
+
+func open(dir string, opts Options, readOnly bool) (_ *Store, err error) {
+	s := &Store{dir: dir, readOnly: readOnly, opts: opts}
+	defer func() {
+		if err != nil {
+			s.closeFiles()
+		}
+	}()
+
+	if s.lock, err = lockDir(dir, readOnly); err != nil {
+		return nil, err
+	}
+
+	flag := os.O_RDWR | os.O_CREATE
+	if readOnly {
+		flag = os.O_RDONLY
+	}
+	for _, f := range []struct {
+		name string
+		dst  **os.File
+	}{{eventsFile, &s.events}, {rootsFile, &s.roots}, {treeFile, &s.tree}} {
+		if *f.dst, err = os.OpenFile(filepath.Join(dir, f.name), flag, 0600); err != nil {
+			return nil, err
+		}
+	}
+
+	if s.state, err = readHeader(filepath.Join(dir, headerFile)); err != nil {
+		return nil, err
+	}
+	if err := s.recover(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// recover checks the data files against the header and, unless read-only,
+// cuts off anything written after the last commit.
+func (s *Store) recover() error {
+	for _, f := range []struct {
+		file *os.File
+		size int64
+	}{
+		{s.events, int64(s.state.count) * eventSize},
+		{s.roots, int64(s.state.count) * hashSize},
+		{s.tree, int64(treeSlots(s.state.count)) * hashSize},
+	} {
+		info, err := f.file.Stat()
+		if err != nil {
+			return err
+		}
+		switch {
+		case info.Size() < f.size:
+			return fmt.Errorf("%s is %d bytes, header commits %d: store is corrupt", info.Name(), info.Size(), f.size)
+		case info.Size() > f.size && !s.readOnly:
+			if err := f.file.Truncate(f.size); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// GetNextIndex returns the next available event index
+func (s *Store) GetNextIndex(ctx context.Context) (uint64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return lockedReader{s}.GetNextIndex(ctx)
+}
+
+// SetNextIndex updates the next available event index
+func (s *Store) SetNextIndex(ctx context.Context, idx uint64) error {
+	return s.update(func() error {
+		s.state.next = idx
+		return s.commit()
+	})
+}
+
+// SetSyncBound records the bound in effect without writing a batch
+func (s *Store) SetSyncBound(ctx context.Context, bound storage.SyncBound) error {
+	return s.update(func() error {
+		s.state.bound = &bound
+		return s.commit()
+	})
+}
+
+// SaveEvent writes a single event. Indices are file offsets, which is why
+// storage.Store asks for them dense. An appended event extends roots.dat
+// and tree.dat like a one-event batch.
+func (s *Store) SaveEvent(ctx context.Context, e *model.IndexedEvent) error {
+	if e == nil {
+		return errors.New("nil event")
+	}
+
+	return s.update(func() error {
+		plan, err := storage.PlanSave(ctx, lockedReader{s}, s.state.count, e)
+		if err != nil {
+			return err
+		}
+		if err := s.writeEvents([]*model.IndexedEvent{e}); err != nil {
+			return err
+		}
+
+		if plan.Old != nil {
+			// an overwrite can move any of the lookups, so rebuild them
+			if s.lookups != nil {
+				lookups, err := s.buildLookups()
+				if err != nil {
+					return err
+				}
+				s.lookups = lookups
+			}
+			return s.commit()
+		}
+
+		if _, err := s.roots.WriteAt(plan.Root[:], int64(e.Index)*hashSize); err != nil {
+			return err
+		}
+		if err := s.writeTree(plan.Nodes, s.state.count, s.state.count+1); err != nil {
+			return err
+		}
+		s.state.count++
+		if s.lookups != nil {
+			if err := storage.IndexEvent(s.lookups, e); err != nil {
+				return err
+			}
+			s.lookups.PutInfoRoot(plan.Root, e.Index)
+		}
+		return s.commit()
+	})
+}
+
+// GetEvent reads one event with a single pread
+func (s *Store) GetEvent(ctx context.Context, index uint64) (*model.IndexedEvent, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return lockedReader{s}.GetEvent(ctx, index)
+}
+
+// IterateEvents walks events from..to (inclusive), reading them in chunks
+func (s *Store) IterateEvents(ctx context.Context, from, to uint64, fn storage.EventFunc) error {
+	return s.iterate(ctx, from, to, false, fn)
+}
+
+// IterateEventsReverse walks events to..from (inclusive), newest first
+func (s *Store) IterateEventsReverse(ctx context.Context, from, to uint64, fn storage.EventFunc) error {
+	return s.iterate(ctx, from, to, true, fn)
+}
+
+// iterate holds the read lock per chunk rather than for the whole scan,
+// so fn may call back into the store. Events below the count never change
+// except through a rollback, which ends the scan early.
+func (s *Store) iterate(ctx context.Context, from, to uint64, reverse bool, fn storage.EventFunc) error {
+	s.mu.RLock()
+	count := s.state.count
+	s.mu.RUnlock()
+
+	if count == 0 || from > to || from >= count {
+		return nil
+	}
+	to = min(to, count-1)
+
+	buf := make([]byte, iterateChunk*eventSize)
+	for lo, hi := from, to; lo <= hi; {
+		// next chunk [start, start+n) from the front or the back
+		n := min(hi-lo+1, iterateChunk)
+		start := lo
+		if reverse {
+			start = hi - n + 1
+		}
+
+		s.mu.RLock()
+		chunk, err := s.readChunk(buf[:n*eventSize], start)
+		s.mu.RUnlock()
+		if err != nil {
+			return err
+		}
+
+		for k := uint64(0); k < uint64(len(chunk)); k++ {
+			i := k
+			if reverse {
+				i = uint64(len(chunk)) - 1 - k
+			}
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			var e model.IndexedEvent
+			if err := e.UnmarshalBinary(chunk[i]); err != nil {
+				return fmt.Errorf("unmarshal event %d: %w", start+i, err)
+			}
+			if err := fn(&e); err != nil {
+				if errors.Is(err, storage.ErrStop) {
+					return nil
+				}
+				return err
+			}
+		}
+		if uint64(len(chunk)) < n {
+			return nil // rolled back underneath us
+		}
+
+		if reverse {
+			if start == lo {
+				break
+			}
+			hi = start - 1
+		} else {
+			lo = start + n
+		}
+	}
+	return nil
+}
+
+// readChunk reads up to len(buf)/eventSize events from start, fewer if
+// the store shrank since the scan began. The caller holds mu.
+func (s *Store) readChunk(buf []byte, start uint64) ([][]byte, error) {
+	n := uint64(len(buf)) / eventSize
+	if start >= s.state.count {
+		return nil, nil
+	}
+	n = min(n, s.state.count-start)
+
+	if _, err := s.events.ReadAt(buf[:n*eventSize], int64(start)*eventSize); err != nil {
+		return nil, err
+	}
+	records := make([][]byte, n)
+	for i := range records {
+		records[i] = buf[i*eventSize : (i+1)*eventSize]
+	}
+	return records, nil
+}
+
+// LatestEvent returns the event with the highest index
+func (s *Store) LatestEvent(ctx context.Context) (*model.IndexedEvent, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return lockedReader{s}.LatestEvent(ctx)
+}
+
+// WriteBatch appends the batch to the data files with one write each and
+// then commits the header, subject to Options.SyncEvery. Event indices
+// must continue exactly from the stored next index.
+func (s *Store) WriteBatch(ctx context.Context, batch *storage.Batch) error {
+	return s.update(func() error {
+		expected := s.state.next
+		if expected != s.state.count {
+			return fmt.Errorf("next index %d is past the %d stored events", expected, s.state.count)
+		}
+		for _, e := range batch.Events {
+			if e.Index != expected {
+				return fmt.Errorf("non-contiguous event index %d, expected %d", e.Index, expected)
+			}
+			expected++
+		}
+		if batch.NextIndex != expected {
+			return fmt.Errorf("batch next index %d, expected %d", batch.NextIndex, expected)
+		}
+
+		if err := s.writeEvents(batch.Events); err != nil {
+			return err
+		}
+		if err := s.writeRoots(batch); err != nil {
+			return err
+		}
+		if err := s.writeTree(batch.TreeNodes, s.state.count, batch.NextIndex); err != nil {
+			return err
+		}
+
+		for k, e := range batch.Events {
+			if s.lookups == nil {
+				break
+			}
+			if err := storage.IndexEvent(s.lookups, e); err != nil {
+				return err
+			}
+			if k < len(batch.InfoRoots) {
+				s.lookups.PutInfoRoot(batch.InfoRoots[k], e.Index)
+			}
+		}
+
+		s.state.count = batch.NextIndex
+		s.state.next = batch.NextIndex
+		cp := batch.Checkpoint
+		s.state.checkpoint = &cp
+		if batch.Bound != nil {
+			bound := *batch.Bound
+			s.state.bound = &bound
+		}
+		s.state.putBlocks(batch.Blocks, batch.Window)
+
+		s.pending++
+		if s.pending < s.opts.SyncEvery {
+			return nil
+		}
+		return s.commit()
+	})
+}
+
+// GetCheckpoint returns the last fully processed block, nil if none
+func (s *Store) GetCheckpoint(ctx context.Context) (*storage.BlockRef, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return lockedReader{s}.GetCheckpoint(ctx)
+}
+
+// GetSyncBound returns the bound recorded with the last batch, nil if none
+func (s *Store) GetSyncBound(ctx context.Context) (*storage.SyncBound, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return lockedReader{s}.GetSyncBound(ctx)
+}
+
+// RecentBlocks returns the stored block window, newest first
+func (s *Store) RecentBlocks(ctx context.Context) ([]storage.BlockRef, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	refs := make([]storage.BlockRef, len(s.state.blocks))
+	for i, ref := range s.state.blocks {
+		refs[len(refs)-1-i] = ref
+	}
+	return refs, nil
+}
+
+// Rollback drops every event above the given block. The shorter header is
+// committed before the files are truncated, so a crash in between leaves
+// only an uncommitted tail that the next open cuts off.
+func (s *Store) Rollback(ctx context.Context, block uint64) (uint64, error) {
+	var next uint64
+
+	err := s.update(func() error {
+		count, tail := s.state.count, s.state.count
+		prev := func() (*model.IndexedEvent, error) {
+			if tail == 0 {
+				return nil, nil
+			}
+			tail--
+			return s.readEvent(tail)
+		}
+		var err error
+		next, err = storage.RollbackTail(s.state.next, block, prev, func(e *model.IndexedEvent) error {
+			count--
+			if s.lookups == nil {
+				return nil
+			}
+			root, err := s.readHash(s.roots, e.Index)
+			if err == nil {
+				s.lookups.DeleteInfoRoot(root, e.Index)
+			} else if !errors.Is(err, storage.ErrNotFound) {
+				return err
+			}
+			return storage.UnindexEvent(s.lookups, e)
+		})
+		if err != nil {
+			return err
+		}
+
+		s.state.count = count
+		s.state.next = next
+		s.state.dropBlocksAbove(block)
+
+		ref, ok := s.state.block(block)
+		s.state.checkpoint = storage.RollbackCheckpoint(block, ref.Hash, ok)
+
+		if err := s.commit(); err != nil {
+			return err
+		}
+		if err := s.events.Truncate(int64(count) * eventSize); err != nil {
+			return err
+		}
+		if err := s.roots.Truncate(int64(count) * hashSize); err != nil {
+			return err
+		}
+		return s.tree.Truncate(int64(treeSlots(count)) * hashSize)
+	})
+
+	return next, err
+}
+
+// GetTreeNode returns a stored L1 info tree node. Only nodes of full
+// subtrees are kept, which is all l1infotree ever reads.
+func (s *Store) GetTreeNode(ctx context.Context, level uint8, pos uint64) (common.Hash, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return lockedReader{s}.GetTreeNode(ctx, level, pos)
+}
+
+// GetInfoRoot returns the L1 info root right after the event at index
+func (s *Store) GetInfoRoot(ctx context.Context, index uint64) (common.Hash, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return lockedReader{s}.GetInfoRoot(ctx, index)
+}
+
+// View runs fn under the read lock, so no write lands until it returns
+func (s *Store) View(ctx context.Context, fn func(storage.Reader) error) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return fn(lockedReader{s})
+}
+
+// lockedReader reads for a caller that already holds mu
+type lockedReader struct {
+	s *Store
+}
+
+func (r lockedReader) GetNextIndex(ctx context.Context) (uint64, error) {
+	return r.s.state.next, nil
+}
+
+func (r lockedReader) GetEvent(ctx context.Context, index uint64) (*model.IndexedEvent, error) {
+	if index >= r.s.state.count {
+		return nil, storage.ErrNotFound
+	}
+	return r.s.readEvent(index)
+}
+
+func (r lockedReader) LatestEvent(ctx context.Context) (*model.IndexedEvent, error) {
+	if r.s.state.count == 0 {
+		return nil, storage.ErrNotFound
+	}
+	return r.s.readEvent(r.s.state.count - 1)
+}
+
+func (r lockedReader) GetCheckpoint(ctx context.Context) (*storage.BlockRef, error) {
+	if r.s.state.checkpoint == nil {
+		return nil, nil
+	}
+	cp := *r.s.state.checkpoint
+	return &cp, nil
+}
+
+func (r lockedReader) GetSyncBound(ctx context.Context) (*storage.SyncBound, error) {
+	if r.s.state.bound == nil {
+		return nil, nil
+	}
+	bound := *r.s.state.bound
+	return &bound, nil
+}
+
+func (r lockedReader) GetTreeNode(ctx context.Context, level uint8, pos uint64) (common.Hash, error) {
+	if level > l1infotree.Height || (pos+1)<<level > r.s.state.count {
+		return common.Hash{}, storage.ErrNotFound
+	}
+	return r.s.readHash(r.s.tree, treeSlot(level, pos))
+}
+
+func (r lockedReader) GetInfoRoot(ctx context.Context, index uint64) (common.Hash, error) {
+	if index >= r.s.state.count {
+		return common.Hash{}, storage.ErrNotFound
+	}
+	return r.s.readHash(r.s.roots, index)
+}
+
+// Close commits any batches still waiting for a sync and releases the files
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var err error
+	if s.pending > 0 {
+		err = s.commit()
+	}
+	return errors.Join(err, s.closeFiles())
+}
+
+func (s *Store) closeFiles() error {
+	var errs []error
+	for _, f := range []*os.File{s.events, s.roots, s.tree, s.lock} {
+		if f != nil {
+			errs = append(errs, f.Close())
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// update runs fn under the write lock
+func (s *Store) update(fn func() error) error {
+	if s.readOnly {
+		return errors.New("flat store is read-only")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return fn()
+}
+
+// commit makes the current state durable: data first, then the header
+// that points at it.
+func (s *Store) commit() error {
+	for _, f := range []*os.File{s.events, s.roots, s.tree} {
+		if err := f.Sync(); err != nil {
+			return err
+		}
+	}
+	if err := writeHeader(s.dir, s.state); err != nil {
+		return err
+	}
+	s.pending = 0
+	return nil
+}
+
+func (s *Store) readEvent(index uint64) (*model.IndexedEvent, error) {
+	buf := make([]byte, eventSize)
+	if _, err := s.events.ReadAt(buf, int64(index)*eventSize); err != nil {
+		return nil, err
+	}
+	var e model.IndexedEvent
+	if err := e.UnmarshalBinary(buf); err != nil {
+		return nil, fmt.Errorf("unmarshal event %d: %w", index, err)
+	}
+	return &e, nil
+}
+
+// readHash reads the hash in slot of f. A zero hash is a slot that was
+// never filled, since real roots and nodes are keccak outputs.
+func (s *Store) readHash(f *os.File, slot uint64) (common.Hash, error) {
+	var h common.Hash
+	if _, err := f.ReadAt(h[:], int64(slot)*hashSize); err != nil {
+		return common.Hash{}, err
+	}
+	if h == (common.Hash{}) {
+		return common.Hash{}, storage.ErrNotFound
+	}
+	return h, nil
+}
+
+// writeEvents writes consecutive events with one pwrite
+func (s *Store) writeEvents(events []*model.IndexedEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+	buf := make([]byte, 0, len(events)*eventSize)
+	for _, e := range events {
+		data, err := e.MarshalBinary()
+		if err != nil {
+			return fmt.Errorf("marshal event: %w", err)
+		}
+		buf = append(buf, data...)
+	}
+	_, err := s.events.WriteAt(buf, int64(events[0].Index)*eventSize)
+	return err
+}
+
+func (s *Store) writeRoots(batch *storage.Batch) error {
+	if len(batch.Events) == 0 {
+		return nil
+	}
+	buf := make([]byte, len(batch.Events)*hashSize)
+	for k, root := range batch.InfoRoots {
+		copy(buf[k*hashSize:], root[:])
+	}
+	_, err := s.roots.WriteAt(buf, int64(batch.Events[0].Index)*hashSize)
+	return err
+}
+
+// writeTree appends the nodes whose subtrees filled up between count and
+// newCount. Nodes of still partial subtrees are dropped; readers recompute
+// those.
+func (s *Store) writeTree(nodes []l1infotree.Node, count, newCount uint64) error {
+	first, end := treeSlots(count), treeSlots(newCount)
+	if first == end {
+		return nil
+	}
+
+	buf := make([]byte, (end-first)*hashSize)
+	for _, n := range nodes {
+		if n.Level > l1infotree.Height || (n.Pos+1)<<n.Level > newCount {
+			continue
+		}
+		if slot := treeSlot(n.Level, n.Pos); slot >= first {
+			copy(buf[(slot-first)*hashSize:], n.Hash[:])
+		}
+	}
+	_, err := s.tree.WriteAt(buf, int64(first)*hashSize)
+	return err
+}
+
+// treeSlots is the number of full-subtree nodes in a tree of count leaves:
+// count>>l at each level l.
+func treeSlots(count uint64) uint64 {
+	var n uint64
+	for l := 0; l <= l1infotree.Height; l++ {
+		n += count >> l
+	}
+	return n
+}
+
+// treeSlot is where the full-subtree node (level, pos) sits in tree.dat.
+// Nodes are appended as their subtree fills: after every node filled by an
+// earlier leaf, and after the lower levels filled by the same leaf.
+func treeSlot(level uint8, pos uint64) uint64 {
+	last := (pos+1)<<level - 1 // the leaf that fills the subtree
+	return treeSlots(last) + uint64(level)
+}
@@ -0,0 +1,297 @@
+package flat
+
+import (
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/zacksfF/sepolia-sh/ch1/internal/model"
+	"github.com/zacksfF/sepolia-sh/ch1/internal/storage"
+)
+
+func newEvent(index, block uint64) *model.IndexedEvent {
+	e := &model.IndexedEvent{
+		Index:           index,
+		BlockNumber:     block,
+		BlockTime:       1700000000 + block*12,
+		TxHash:          common.BytesToHash([]byte{3, byte(index)}),
+		MainnetExitRoot: common.BytesToHash([]byte{4, byte(index)}),
+		RollupExitRoot:  common.BytesToHash([]byte{5, byte(index)}),
+	}
+	e.GlobalExitRoot = model.GlobalExitRoot(e.MainnetExitRoot, e.RollupExitRoot)
+	return e
+}
+
+// writeBlocks writes one batch per block with one event each
+func writeBlocks(t *testing.T, s *Store, from, to uint64) {
+	t.Helper()
+	for block := from; block <= to; block++ {
+		ref := storage.BlockRef{Number: block, Hash: common.BytesToHash([]byte{byte(block)})}
+		batch := &storage.Batch{
+			Events:     []*model.IndexedEvent{newEvent(block, block)},
+			NextIndex:  block + 1,
+			Checkpoint: ref,
+			Blocks:     []storage.BlockRef{ref},
+			Window:     10,
+		}
+		if err := s.WriteBatch(t.Context(), batch); err != nil {
+			t.Fatalf("Failed to write block %d: %v", block, err)
+		}
+	}
+}
+
+func TestTreeSlot(t *testing.T) {
+	// full-subtree nodes in the order their subtrees fill up
+	order := []struct {
+		level uint8
+		pos   uint64
+	}{
+		{0, 0},
+		{0, 1}, {1, 0},
+		{0, 2},
+		{0, 3}, {1, 1}, {2, 0},
+		{0, 4},
+	}
+	for slot, n := range order {
+		if got := treeSlot(n.level, n.pos); got != uint64(slot) {
+			t.Errorf("treeSlot(%d, %d) mismatch: got %d, want %d", n.level, n.pos, got, slot)
+		}
+	}
+	if got := treeSlots(5); got != 8 {
+		t.Errorf("treeSlots(5) mismatch: got %d, want 8", got)
+	}
+}
+
+func TestStore_TornTail(t *testing.T) {
+	dir := t.TempDir()
+
+	s, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	writeBlocks(t, s, 0, 2)
+	if err := s.Close(); err != nil {
+		t.Fatalf("Failed to close store: %v", err)
+	}
+
+	// a crash mid-append leaves half a record behind the committed ones
+	f, err := os.OpenFile(filepath.Join(dir, eventsFile), os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatalf("Failed to open events file: %v", err)
+	}
+	if _, err := f.Write(make([]byte, eventSize/2)); err != nil {
+		t.Fatalf("Failed to write torn record: %v", err)
+	}
+	f.Close()
+
+	s, err = Open(dir)
+	if err != nil {
+		t.Fatalf("Failed to reopen store: %v", err)
+	}
+	defer s.Close()
+
+	info, err := os.Stat(filepath.Join(dir, eventsFile))
+	if err != nil {
+		t.Fatalf("Failed to stat events file: %v", err)
+	}
+	if info.Size() != 3*eventSize {
+		t.Errorf("events file size mismatch: got %d, want %d", info.Size(), 3*eventSize)
+	}
+
+	// appends continue right after the last committed record
+	writeBlocks(t, s, 3, 3)
+	got, err := s.GetEvent(t.Context(), 3)
+	if err != nil {
+		t.Fatalf("Failed to get event: %v", err)
+	}
+	if *got != *newEvent(3, 3) {
+		t.Errorf("event mismatch: got %+v, want %+v", got, newEvent(3, 3))
+	}
+}
+
+func TestStore_TruncatedFile(t *testing.T) {
+	dir := t.TempDir()
+
+	s, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	writeBlocks(t, s, 0, 2)
+	s.Close()
+
+	// losing committed records is corruption, not something to paper over
+	if err := os.Truncate(filepath.Join(dir, eventsFile), eventSize); err != nil {
+		t.Fatalf("Failed to truncate events file: %v", err)
+	}
+	if _, err := Open(dir); err == nil {
+		t.Error("expected error opening a store missing committed records")
+	}
+}
+
+func TestStore_SyncEvery(t *testing.T) {
+	dir := t.TempDir()
+
+	s, err := OpenOptions(dir, Options{SyncEvery: 4})
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	writeBlocks(t, s, 0, 5)
+
+	// all six batches are readable, only the first four are committed
+	if next, err := s.GetNextIndex(t.Context()); err != nil || next != 6 {
+		t.Errorf("next index mismatch: got %d (%v), want 6", next, err)
+	}
+	st, err := readHeader(filepath.Join(dir, headerFile))
+	if err != nil {
+		t.Fatalf("Failed to read header: %v", err)
+	}
+	if st.count != 4 || st.checkpoint == nil || st.checkpoint.Number != 3 {
+		t.Errorf("committed state mismatch: got count %d checkpoint %+v, want 4 at block 3", st.count, st.checkpoint)
+	}
+
+	if err := s.Close(); err != nil {
+		t.Fatalf("Failed to close store: %v", err)
+	}
+	if st, err = readHeader(filepath.Join(dir, headerFile)); err != nil || st.count != 6 {
+		t.Errorf("committed count after close: got %d (%v), want 6", st.count, err)
+	}
+}
+
+func TestStore_Locking(t *testing.T) {
+	dir := t.TempDir()
+
+	if _, err := OpenReadOnly(dir); err == nil {
+		t.Fatal("expected error opening a missing store read-only")
+	}
+
+	s, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	writeBlocks(t, s, 0, 0)
+
+	if _, err := Open(dir); err == nil {
+		t.Error("expected error opening a store twice for writing")
+	}
+	if _, err := OpenReadOnly(dir); err == nil {
+		t.Error("expected error opening a store read-only while it is written")
+	}
+	s.Close()
+
+	r, err := OpenReadOnly(dir)
+	if err != nil {
+		t.Fatalf("Failed to open read-only: %v", err)
+	}
+	defer r.Close()
+
+	if err := r.SetNextIndex(t.Context(), 5); err == nil {
+		t.Error("expected write through a read-only store to fail")
+	}
+	if e, err := r.GetEvent(t.Context(), 0); err != nil || *e != *newEvent(0, 0) {
+		t.Errorf("event mismatch: got %+v (%v), want %+v", e, err, newEvent(0, 0))
+	}
+}
+
+func TestStore_LazyLookups(t *testing.T) {
+	ctx := t.Context()
+	dir := t.TempDir()
+
+	s, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	writeBlocks(t, s, 0, 5)
+	s.Close()
+
+	s, err = Open(dir)
+	if err != nil {
+		t.Fatalf("Failed to reopen store: %v", err)
+	}
+	defer s.Close()
+	if s.lookups != nil {
+		t.Fatal("expected open to leave the lookups unbuilt")
+	}
+
+	// writes before the first lookup land in the scan instead
+	writeBlocks(t, s, 6, 7)
+	if _, err := s.Rollback(ctx, 6); err != nil {
+		t.Fatalf("Failed to roll back: %v", err)
+	}
+	if s.lookups != nil {
+		t.Fatal("expected writes to leave the lookups unbuilt")
+	}
+
+	// concurrent first lookups build once
+	var wg sync.WaitGroup
+	for block := uint64(0); block <= 7; block++ {
+		wg.Go(func() {
+			first, last, err := s.EventRangeByBlock(ctx, block)
+			switch {
+			case block == 7 && !errors.Is(err, storage.ErrNotFound):
+				t.Errorf("block 7: got %d-%d (%v), want ErrNotFound", first, last, err)
+			case block < 7 && (err != nil || first != block || last != block):
+				t.Errorf("block %d range mismatch: got %d-%d (%v)", block, first, last, err)
+			}
+		})
+	}
+	wg.Wait()
+
+	// once built, writes keep them current
+	writeBlocks(t, s, 7, 8)
+	if idx, err := s.IndicesByTx(ctx, newEvent(8, 8).TxHash); err != nil || len(idx) != 1 || idx[0] != 8 {
+		t.Errorf("tx lookup mismatch: got %v (%v), want [8]", idx, err)
+	}
+}
+
+func TestParseDSN(t *testing.T) {
+	dir, opts, err := parseDSN("/data/events?sync_every=16")
+	if err != nil || dir != "/data/events" || opts.SyncEvery != 16 {
+		t.Errorf("parseDSN mismatch: got %q %+v (%v)", dir, opts, err)
+	}
+	if _, _, err := parseDSN("/data/events?sync_every=x"); err == nil {
+		t.Error("expected error for an invalid sync_every")
+	}
+}
+
+// BenchmarkFirstLookup measures opening a store plus its first lookup,
+// which scans every event and root once.
+func BenchmarkFirstLookup(b *testing.B) {
+	const events = 100_000
+	dir := b.TempDir()
+
+	s, err := Open(dir)
+	if err != nil {
+		b.Fatalf("Failed to open store: %v", err)
+	}
+	for start := uint64(0); start < events; start += 1000 {
+		batch := &storage.Batch{NextIndex: start + 1000, Window: 1}
+		for i := start; i < start+1000; i++ {
+			// unique hashes, so every lookup gets an entry per event
+			e := newEvent(i, i/2)
+			e.TxHash = common.BigToHash(new(big.Int).SetUint64(i + 1))
+			e.GlobalExitRoot = common.BigToHash(new(big.Int).SetUint64(events + i))
+			batch.Events = append(batch.Events, e)
+			batch.InfoRoots = append(batch.InfoRoots, common.BigToHash(new(big.Int).SetUint64(2*events+i)))
+		}
+		if err := s.WriteBatch(b.Context(), batch); err != nil {
+			b.Fatalf("Failed to write batch: %v", err)
+		}
+	}
+	s.Close()
+
+	b.ReportAllocs()
+	for b.Loop() {
+		s, err := Open(dir)
+		if err != nil {
+			b.Fatalf("Failed to open store: %v", err)
+		}
+		if _, err := s.IndicesByTx(b.Context(), common.Hash{1}); err != nil && !errors.Is(err, storage.ErrNotFound) {
+			b.Fatalf("Failed to look up tx: %v", err)
+		}
+		s.Close()
+	}
+}
@@ -0,0 +1,246 @@
+package flat
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/zacksfF/sepolia-sh/ch1/internal/storage"
+)
+
+// headerMagic starts every header; the trailing byte is the format version
+var headerMagic = [8]byte{'s', 'e', 'p', 'f', 'l', 'a', 't', 1}
+
+// state is everything the header commits
+type state struct {
+	next       uint64 // next index handed out by the indexer
+	count      uint64 // records committed to events.dat and roots.dat
+	checkpoint *storage.BlockRef
+	bound      *storage.SyncBound
+	blocks     []storage.BlockRef // reorg window, ascending
+}
+
+// putBlocks records window entries and prunes all but the newest keep
+func (st *state) putBlocks(refs []storage.BlockRef, keep int) {
+	for _, ref := range refs {
+		i := sort.Search(len(st.blocks), func(i int) bool { return st.blocks[i].Number >= ref.Number })
+		if i < len(st.blocks) && st.blocks[i].Number == ref.Number {
+			st.blocks[i] = ref
+			continue
+		}
+		st.blocks = append(st.blocks, storage.BlockRef{})
+		copy(st.blocks[i+1:], st.blocks[i:])
+		st.blocks[i] = ref
+	}
+	if drop := len(st.blocks) - max(keep, 0); drop > 0 {
+		st.blocks = append([]storage.BlockRef(nil), st.blocks[drop:]...)
+	}
+}
+
+// dropBlocksAbove removes window entries above block
+func (st *state) dropBlocksAbove(block uint64) {
+	i := sort.Search(len(st.blocks), func(i int) bool { return st.blocks[i].Number > block })
+	st.blocks = st.blocks[:i]
+}
+
+// block returns the window entry for number
+func (st *state) block(number uint64) (storage.BlockRef, bool) {
+	i := sort.Search(len(st.blocks), func(i int) bool { return st.blocks[i].Number >= number })
+	if i < len(st.blocks) && st.blocks[i].Number == number {
+		return st.blocks[i], true
+	}
+	return storage.BlockRef{}, false
+}
+
+// marshal encodes the state as
+//
+//	magic (8) | next (8) | count (8)
+//	| has checkpoint (1) [| number (8) | hash (32)]
+//	| has bound (1) [| number (8) | confirmations (8) | tag length (1) | tag]
+//	| window length (4) | (number (8) | hash (32))...
+//	| crc32 of everything before (4)
+func (st *state) marshal() ([]byte, error) {
+	buf := append([]byte(nil), headerMagic[:]...)
+	buf = binary.BigEndian.AppendUint64(buf, st.next)
+	buf = binary.BigEndian.AppendUint64(buf, st.count)
+
+	if st.checkpoint == nil {
+		buf = append(buf, 0)
+	} else {
+		buf = append(buf, 1)
+		buf = appendBlockRef(buf, *st.checkpoint)
+	}
+
+	if st.bound == nil {
+		buf = append(buf, 0)
+	} else {
+		if len(st.bound.Tag) > 0xff {
+			return nil, fmt.Errorf("sync bound tag too long: %q", st.bound.Tag)
+		}
+		buf = append(buf, 1)
+		buf = binary.BigEndian.AppendUint64(buf, st.bound.Number)
+		buf = binary.BigEndian.AppendUint64(buf, st.bound.Confirmations)
+		buf = append(buf, byte(len(st.bound.Tag)))
+		buf = append(buf, st.bound.Tag...)
+	}
+
+	buf = binary.BigEndian.AppendUint32(buf, uint32(len(st.blocks)))
+	for _, ref := range st.blocks {
+		buf = appendBlockRef(buf, ref)
+	}
+
+	return binary.BigEndian.AppendUint32(buf, crc32.ChecksumIEEE(buf)), nil
+}
+
+func (st *state) unmarshal(data []byte) error {
+	if len(data) < len(headerMagic)+4 || [8]byte(data[:8]) != headerMagic {
+		return errors.New("not a flat store header")
+	}
+	body, sum := data[:len(data)-4], binary.BigEndian.Uint32(data[len(data)-4:])
+	if crc32.ChecksumIEEE(body) != sum {
+		return errors.New("header checksum mismatch")
+	}
+
+	r := reader{buf: body[len(headerMagic):]}
+	st.next = r.uint64()
+	st.count = r.uint64()
+
+	st.checkpoint = nil
+	if r.byte() == 1 {
+		ref := r.blockRef()
+		st.checkpoint = &ref
+	}
+
+	st.bound = nil
+	if r.byte() == 1 {
+		var b storage.SyncBound
+		b.Number = r.uint64()
+		b.Confirmations = r.uint64()
+		b.Tag = string(r.bytes(int(r.byte())))
+		st.bound = &b
+	}
+
+	st.blocks = nil
+	for n := r.uint32(); n > 0 && r.err == nil; n-- {
+		st.blocks = append(st.blocks, r.blockRef())
+	}
+
+	if r.err == nil && len(r.buf) != 0 {
+		r.err = errors.New("trailing bytes")
+	}
+	if r.err != nil {
+		return fmt.Errorf("decode header: %w", r.err)
+	}
+	return nil
+}
+
+// readHeader loads the committed state from path. A missing header is a
+// new, empty store.
+func readHeader(path string) (state, error) {
+	var st state
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return st, nil
+	}
+	if err != nil {
+		return st, err
+	}
+	if err := st.unmarshal(data); err != nil {
+		return st, fmt.Errorf("%s: %w", path, err)
+	}
+	return st, nil
+}
+
+// writeHeader atomically replaces the header in dir: the new one is
+// written and synced beside it, renamed over it, and the directory synced
+// so the rename itself survives a crash.
+func writeHeader(dir string, st state) error {
+	data, err := st.marshal()
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(dir, headerFile)
+	tmp := path + ".tmp"
+
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+func appendBlockRef(buf []byte, ref storage.BlockRef) []byte {
+	buf = binary.BigEndian.AppendUint64(buf, ref.Number)
+	return append(buf, ref.Hash[:]...)
+}
+
+// reader decodes big-endian fields, remembering the first short read
+type reader struct {
+	buf []byte
+	err error
+}
+
+func (r *reader) bytes(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if len(r.buf) < n {
+		r.err = errors.New("truncated")
+		return nil
+	}
+	b := r.buf[:n]
+	r.buf = r.buf[n:]
+	return b
+}
+
+func (r *reader) byte() byte {
+	if b := r.bytes(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (r *reader) uint32() uint32 {
+	if b := r.bytes(4); b != nil {
+		return binary.BigEndian.Uint32(b)
+	}
+	return 0
+}
+
+func (r *reader) uint64() uint64 {
+	if b := r.bytes(8); b != nil {
+		return binary.BigEndian.Uint64(b)
+	}
+	return 0
+}
+
+func (r *reader) blockRef() storage.BlockRef {
+	return storage.BlockRef{Number: r.uint64(), Hash: common.BytesToHash(r.bytes(common.HashLength))}
+}
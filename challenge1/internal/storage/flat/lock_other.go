@@ -0,0 +1,11 @@
+//go:build !unix
+
+package flat
+
+import "os"
+
+// lockDir is a no-op where flock is unavailable; callers must make sure
+// only one process writes the store.
+func lockDir(dir string, readOnly bool) (*os.File, error) {
+	return nil, nil
+}
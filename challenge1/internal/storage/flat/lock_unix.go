@@ -0,0 +1,36 @@
+//go:build unix
+
+package flat
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+const lockFile = "lock"
+
+// lockDir takes an exclusive flock for writers and a shared one for
+// readers, so a reader can't open the store while it is being written.
+// The lock is held until the returned file is closed.
+func lockDir(dir string, readOnly bool) (*os.File, error) {
+	flag, how := os.O_RDWR|os.O_CREATE, syscall.LOCK_EX
+	if readOnly {
+		flag, how = os.O_RDONLY, syscall.LOCK_SH
+	}
+
+	f, err := os.OpenFile(filepath.Join(dir, lockFile), flag, 0600)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Flock(int(f.Fd()), how|syscall.LOCK_NB); err != nil {
+		f.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return nil, fmt.Errorf("flat store %s is in use", dir)
+		}
+		return nil, err
+	}
+	return f, nil
+}
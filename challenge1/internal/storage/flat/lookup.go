@@ -0,0 +1,114 @@
+package flat
+
+import (
+	"context"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/zacksfF/sepolia-sh/ch1/internal/model"
+	"github.com/zacksfF/sepolia-sh/ch1/internal/storage"
+)
+
+// loadLookups returns the lookups, building them on the first call. The
+// caller holds mu; under the read lock the sync.Once keeps concurrent
+// readers from building twice, and writers skip lookups nobody built yet.
+func (s *Store) loadLookups() (*storage.Lookups, error) {
+	s.lookupsOnce.Do(func() {
+		s.lookups, s.lookupsErr = s.buildLookups()
+	})
+	return s.lookups, s.lookupsErr
+}
+
+// buildLookups scans the committed events and roots. The files only
+// support lookup by index, so the secondary indexes live in memory and are
+// built by this scan, once per open store.
+func (s *Store) buildLookups() (*storage.Lookups, error) {
+	l := storage.NewLookups()
+
+	buf := make([]byte, iterateChunk*eventSize)
+	roots := make([]byte, iterateChunk*hashSize)
+	for start := uint64(0); start < s.state.count; start += iterateChunk {
+		n := min(s.state.count-start, iterateChunk)
+
+		chunk, err := s.readChunk(buf[:n*eventSize], start)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := s.roots.ReadAt(roots[:n*hashSize], int64(start)*hashSize); err != nil {
+			return nil, err
+		}
+
+		for i, data := range chunk {
+			var e model.IndexedEvent
+			if err := e.UnmarshalBinary(data); err != nil {
+				return nil, err
+			}
+			if err := storage.IndexEvent(l, &e); err != nil {
+				return nil, err
+			}
+			if root := common.BytesToHash(roots[i*hashSize : (i+1)*hashSize]); root != (common.Hash{}) {
+				l.PutInfoRoot(root, e.Index)
+			}
+		}
+	}
+	return l, nil
+}
+
+// EventRangeByBlock returns the first and last index emitted in a block
+func (s *Store) EventRangeByBlock(ctx context.Context, number uint64) (uint64, uint64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return lockedReader{s}.EventRangeByBlock(ctx, number)
+}
+
+// IndicesByTx returns every index emitted by a transaction, ascending
+func (s *Store) IndicesByTx(ctx context.Context, hash common.Hash) ([]uint64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return lockedReader{s}.IndicesByTx(ctx, hash)
+}
+
+// IndexByGlobalExitRoot returns the index at which a global exit root first appeared
+func (s *Store) IndexByGlobalExitRoot(ctx context.Context, root common.Hash) (uint64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return lockedReader{s}.IndexByGlobalExitRoot(ctx, root)
+}
+
+// IndexByInfoRoot returns the index whose insertion produced an L1 info root
+func (s *Store) IndexByInfoRoot(ctx context.Context, root common.Hash) (uint64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return lockedReader{s}.IndexByInfoRoot(ctx, root)
+}
+
+func (r lockedReader) EventRangeByBlock(ctx context.Context, number uint64) (uint64, uint64, error) {
+	l, err := r.s.loadLookups()
+	if err != nil {
+		return 0, 0, err
+	}
+	return l.BlockRange(number)
+}
+
+func (r lockedReader) IndicesByTx(ctx context.Context, hash common.Hash) ([]uint64, error) {
+	l, err := r.s.loadLookups()
+	if err != nil {
+		return nil, err
+	}
+	return l.TxIndices(hash)
+}
+
+func (r lockedReader) IndexByGlobalExitRoot(ctx context.Context, root common.Hash) (uint64, error) {
+	l, err := r.s.loadLookups()
+	if err != nil {
+		return 0, err
+	}
+	return l.GERIndex(root)
+}
+
+func (r lockedReader) IndexByInfoRoot(ctx context.Context, root common.Hash) (uint64, error) {
+	l, err := r.s.loadLookups()
+	if err != nil {
+		return 0, err
+	}
+	return l.InfoRootIndex(root)
+}
@@ -0,0 +1,256 @@
+package storage
+
+import (
+	"errors"
+	"maps"
+	"slices"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/zacksfF/sepolia-sh/ch1/internal/model"
+)
+
+// LookupWriter is the backend-specific I/O behind the block, tx and global
+// exit root indexes. IndexEvent and UnindexEvent decide what to write.
+// Reads return ErrNotFound for a missing entry.
+type LookupWriter interface {
+	BlockRange(number uint64) (first, last uint64, err error)
+	PutBlockRange(number, first, last uint64) error
+	DeleteBlockRange(number uint64) error
+
+	PutTx(hash common.Hash, index uint64) error
+	DeleteTx(hash common.Hash, index uint64) error
+
+	GERIndex(root common.Hash) (uint64, error)
+	PutGERIndex(root common.Hash, index uint64) error
+	DeleteGERIndex(root common.Hash) error
+}
+
+// IndexEvent adds an event to the block, tx and global exit root indexes.
+// A block range grows to cover the event, and a global exit root keeps
+// the index of its first appearance.
+func IndexEvent(w LookupWriter, e *model.IndexedEvent) error {
+	if err := extendBlockRange(w, e.BlockNumber, e.Index); err != nil {
+		return err
+	}
+
+	if err := w.PutTx(e.TxHash, e.Index); err != nil {
+		return err
+	}
+
+	idx, err := w.GERIndex(e.GlobalExitRoot)
+	switch {
+	case errors.Is(err, ErrNotFound) || err == nil && e.Index < idx:
+		return w.PutGERIndex(e.GlobalExitRoot, e.Index)
+	default:
+		return err
+	}
+}
+
+// UnindexEvent reverses IndexEvent for an event being rolled back. Events
+// are removed from the tail, so the whole block range goes with it.
+func UnindexEvent(w LookupWriter, e *model.IndexedEvent) error {
+	if err := w.DeleteBlockRange(e.BlockNumber); err != nil {
+		return err
+	}
+	if err := w.DeleteTx(e.TxHash, e.Index); err != nil {
+		return err
+	}
+
+	// only drop the root if this was its first appearance; any later ones
+	// sit further along the tail and are being removed too
+	idx, err := w.GERIndex(e.GlobalExitRoot)
+	switch {
+	case err == nil && idx == e.Index:
+		return w.DeleteGERIndex(e.GlobalExitRoot)
+	case errors.Is(err, ErrNotFound):
+		return nil
+	default:
+		return err
+	}
+}
+
+// ReplaceEvent moves the lookups of an overwritten event from old to e.
+// Unlike UnindexEvent it spares the neighbours: old's block range shrinks
+// around it rather than disappearing. SaveEvent only overwrites an event
+// with the same tree leaf, so the global exit root, and its index, stay.
+func ReplaceEvent(w LookupWriter, old, e *model.IndexedEvent) error {
+	if old.BlockNumber != e.BlockNumber {
+		if err := shrinkBlockRange(w, old.BlockNumber, old.Index); err != nil {
+			return err
+		}
+		if err := extendBlockRange(w, e.BlockNumber, e.Index); err != nil {
+			return err
+		}
+	}
+
+	if old.TxHash != e.TxHash {
+		if err := w.DeleteTx(old.TxHash, old.Index); err != nil {
+			return err
+		}
+		return w.PutTx(e.TxHash, e.Index)
+	}
+	return nil
+}
+
+// extendBlockRange grows the range of block number to cover index
+func extendBlockRange(w LookupWriter, number, index uint64) error {
+	first, last, err := w.BlockRange(number)
+	switch {
+	case errors.Is(err, ErrNotFound):
+		first, last = index, index
+	case err != nil:
+		return err
+	}
+	return w.PutBlockRange(number, min(first, index), max(last, index))
+}
+
+// shrinkBlockRange takes index out of the range of block number. An index
+// inside the range leaves it as it is, still spanning the other events.
+func shrinkBlockRange(w LookupWriter, number, index uint64) error {
+	first, last, err := w.BlockRange(number)
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return nil
+	case err != nil:
+		return err
+	case first == last:
+		return w.DeleteBlockRange(number)
+	case index == first:
+		return w.PutBlockRange(number, first+1, last)
+	case index == last:
+		return w.PutBlockRange(number, first, last-1)
+	}
+	return nil
+}
+
+// Lookups holds the secondary indexes in memory, for backends that have
+// nowhere else to keep them. It is not safe for concurrent use.
+type Lookups struct {
+	byBlock    map[uint64][2]uint64 // number -> first, last index
+	byTx       map[common.Hash][]uint64
+	byGER      map[common.Hash]uint64 // global exit root -> first index
+	byInfoRoot map[common.Hash]uint64 // L1 info root -> index
+}
+
+var _ LookupWriter = (*Lookups)(nil)
+
+// NewLookups returns empty indexes
+func NewLookups() *Lookups {
+	return &Lookups{
+		byBlock:    make(map[uint64][2]uint64),
+		byTx:       make(map[common.Hash][]uint64),
+		byGER:      make(map[common.Hash]uint64),
+		byInfoRoot: make(map[common.Hash]uint64),
+	}
+}
+
+// Clone returns an independent copy of the indexes
+func (l *Lookups) Clone() *Lookups {
+	c := &Lookups{
+		byBlock:    maps.Clone(l.byBlock),
+		byTx:       make(map[common.Hash][]uint64, len(l.byTx)),
+		byGER:      maps.Clone(l.byGER),
+		byInfoRoot: maps.Clone(l.byInfoRoot),
+	}
+	for h, indices := range l.byTx {
+		c.byTx[h] = slices.Clone(indices)
+	}
+	return c
+}
+
+// ClearEvents empties the block, tx and global exit root indexes, keeping
+// the L1 info roots, so the events can be indexed again.
+func (l *Lookups) ClearEvents() {
+	clear(l.byBlock)
+	clear(l.byTx)
+	clear(l.byGER)
+}
+
+// BlockRange returns the first and last index emitted in a block
+func (l *Lookups) BlockRange(number uint64) (uint64, uint64, error) {
+	r, ok := l.byBlock[number]
+	if !ok {
+		return 0, 0, ErrNotFound
+	}
+	return r[0], r[1], nil
+}
+
+func (l *Lookups) PutBlockRange(number, first, last uint64) error {
+	l.byBlock[number] = [2]uint64{first, last}
+	return nil
+}
+
+func (l *Lookups) DeleteBlockRange(number uint64) error {
+	delete(l.byBlock, number)
+	return nil
+}
+
+// TxIndices returns every index emitted by a transaction, ascending
+func (l *Lookups) TxIndices(hash common.Hash) ([]uint64, error) {
+	indices, ok := l.byTx[hash]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return slices.Clone(indices), nil
+}
+
+func (l *Lookups) PutTx(hash common.Hash, index uint64) error {
+	indices := l.byTx[hash]
+	if i, found := slices.BinarySearch(indices, index); !found {
+		l.byTx[hash] = slices.Insert(indices, i, index)
+	}
+	return nil
+}
+
+func (l *Lookups) DeleteTx(hash common.Hash, index uint64) error {
+	indices := l.byTx[hash]
+	if i, found := slices.BinarySearch(indices, index); found {
+		indices = slices.Delete(indices, i, i+1)
+	}
+	if len(indices) == 0 {
+		delete(l.byTx, hash)
+	} else {
+		l.byTx[hash] = indices
+	}
+	return nil
+}
+
+// GERIndex returns the index at which a global exit root first appeared
+func (l *Lookups) GERIndex(root common.Hash) (uint64, error) {
+	return lookup(l.byGER, root)
+}
+
+func (l *Lookups) PutGERIndex(root common.Hash, index uint64) error {
+	l.byGER[root] = index
+	return nil
+}
+
+func (l *Lookups) DeleteGERIndex(root common.Hash) error {
+	delete(l.byGER, root)
+	return nil
+}
+
+// InfoRootIndex returns the index whose insertion produced an L1 info root
+func (l *Lookups) InfoRootIndex(root common.Hash) (uint64, error) {
+	return lookup(l.byInfoRoot, root)
+}
+
+// PutInfoRoot records the L1 info root produced by the event at index
+func (l *Lookups) PutInfoRoot(root common.Hash, index uint64) {
+	l.byInfoRoot[root] = index
+}
+
+// DeleteInfoRoot removes root if the event at index produced it
+func (l *Lookups) DeleteInfoRoot(root common.Hash, index uint64) {
+	if idx, ok := l.byInfoRoot[root]; ok && idx == index {
+		delete(l.byInfoRoot, root)
+	}
+}
+
+func lookup(m map[common.Hash]uint64, k common.Hash) (uint64, error) {
+	idx, ok := m[k]
+	if !ok {
+		return 0, ErrNotFound
+	}
+	return idx, nil
+}
@@ -0,0 +1,15 @@
+package memory
+
+import (
+	"testing"
+
+	"github.com/zacksfF/sepolia-sh/ch1/internal/storage"
+	"github.com/zacksfF/sepolia-sh/ch1/internal/storage/storagetest"
+)
+
+// nothing persists, so only Run applies; RunReopen needs a durable store
+func TestStore_Conformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T, dir string) storage.Store {
+		return New()
+	})
+}
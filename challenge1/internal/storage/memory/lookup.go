@@ -0,0 +1,63 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/zacksfF/sepolia-sh/ch1/internal/storage"
+)
+
+// EventRangeByBlock returns the first and last index emitted in a block
+func (s *Store) EventRangeByBlock(ctx context.Context, number uint64) (uint64, uint64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return lockedReader{s}.EventRangeByBlock(ctx, number)
+}
+
+// IndicesByTx returns every index emitted by a transaction, ascending
+func (s *Store) IndicesByTx(ctx context.Context, hash common.Hash) ([]uint64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return lockedReader{s}.IndicesByTx(ctx, hash)
+}
+
+// IndexByGlobalExitRoot returns the index at which a global exit root first appeared
+func (s *Store) IndexByGlobalExitRoot(ctx context.Context, root common.Hash) (uint64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return lockedReader{s}.IndexByGlobalExitRoot(ctx, root)
+}
+
+// IndexByInfoRoot returns the index whose insertion produced an L1 info root
+func (s *Store) IndexByInfoRoot(ctx context.Context, root common.Hash) (uint64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return lockedReader{s}.IndexByInfoRoot(ctx, root)
+}
+
+func (r lockedReader) EventRangeByBlock(ctx context.Context, number uint64) (uint64, uint64, error) {
+	return r.s.lookups.BlockRange(number)
+}
+
+func (r lockedReader) IndicesByTx(ctx context.Context, hash common.Hash) ([]uint64, error) {
+	return r.s.lookups.TxIndices(hash)
+}
+
+func (r lockedReader) IndexByGlobalExitRoot(ctx context.Context, root common.Hash) (uint64, error) {
+	return r.s.lookups.GERIndex(root)
+}
+
+func (r lockedReader) IndexByInfoRoot(ctx context.Context, root common.Hash) (uint64, error) {
+	return r.s.lookups.InfoRootIndex(root)
+}
+
+// reindex rebuilds the block, tx and global exit root indexes from scratch
+func (s *Store) reindex() error {
+	s.lookups.ClearEvents()
+	for _, idx := range s.indices {
+		if err := storage.IndexEvent(s.lookups, s.events[idx]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
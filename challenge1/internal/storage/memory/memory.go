@@ -0,0 +1,429 @@
+// Package memory implements storage.Store in memory. It is meant for tests
+// and short-lived tools: nothing survives Close, but it behaves like the
+// persistent backends, ErrNotFound included, and is safe for concurrent use.
+package memory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/zacksfF/sepolia-sh/ch1/internal/model"
+	"github.com/zacksfF/sepolia-sh/ch1/internal/storage"
+)
+
+type treeKey struct {
+	level uint8
+	pos   uint64
+}
+
+// Store implements storage.Store with maps guarded by a RWMutex. Stored
+// events are never modified in place, so readers can share them.
+type Store struct {
+	mu sync.RWMutex
+
+	next       uint64
+	events     map[uint64]*model.IndexedEvent
+	indices    []uint64 // keys of events, ascending
+	checkpoint *storage.BlockRef
+	bound      *storage.SyncBound
+	blocks     map[uint64]common.Hash // reorg window
+	tree       map[treeKey]common.Hash
+	roots      map[uint64]common.Hash // index -> L1 info root
+
+	lookups *storage.Lookups
+}
+
+// New returns an empty store
+func New() *Store {
+	return &Store{
+		events:  make(map[uint64]*model.IndexedEvent),
+		blocks:  make(map[uint64]common.Hash),
+		tree:    make(map[treeKey]common.Hash),
+		roots:   make(map[uint64]common.Hash),
+		lookups: storage.NewLookups(),
+	}
+}
+
+// Clone returns an independent copy of the store at this instant. Writes
+// to either store are not seen by the other, so a clone is a snapshot a
+// test can compare against or roll back to.
+func (s *Store) Clone() *Store {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	c := &Store{
+		next:    s.next,
+		events:  maps.Clone(s.events),
+		indices: slices.Clone(s.indices),
+		blocks:  maps.Clone(s.blocks),
+		tree:    maps.Clone(s.tree),
+		roots:   maps.Clone(s.roots),
+		lookups: s.lookups.Clone(),
+	}
+	if s.checkpoint != nil {
+		cp := *s.checkpoint
+		c.checkpoint = &cp
+	}
+	if s.bound != nil {
+		bound := *s.bound
+		c.bound = &bound
+	}
+	return c
+}
+
+// GetNextIndex returns the next available event index
+func (s *Store) GetNextIndex(ctx context.Context) (uint64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return lockedReader{s}.GetNextIndex(ctx)
+}
+
+// SetNextIndex updates the next available event index
+func (s *Store) SetNextIndex(ctx context.Context, idx uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.next = idx
+	return nil
+}
+
+// SetSyncBound records the bound in effect without writing a batch
+func (s *Store) SetSyncBound(ctx context.Context, bound storage.SyncBound) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.bound = &bound
+	return nil
+}
+
+// SaveEvent stores a copy of an event and, for an append, its L1 info
+// tree nodes and root
+func (s *Store) SaveEvent(ctx context.Context, e *model.IndexedEvent) error {
+	if e == nil {
+		return errors.New("nil event")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var count uint64
+	if n := len(s.indices); n > 0 {
+		count = s.indices[n-1] + 1
+	}
+	plan, err := storage.PlanSave(ctx, lockedReader{s}, count, e)
+	if err != nil {
+		return err
+	}
+
+	if err := s.putEvent(e); err != nil {
+		return err
+	}
+	if plan.Old == nil {
+		for _, n := range plan.Nodes {
+			s.tree[treeKey{n.Level, n.Pos}] = n.Hash
+		}
+		s.roots[e.Index] = plan.Root
+		s.lookups.PutInfoRoot(plan.Root, e.Index)
+	}
+	return nil
+}
+
+// GetEvent retrieves an event by its index
+func (s *Store) GetEvent(ctx context.Context, index uint64) (*model.IndexedEvent, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return lockedReader{s}.GetEvent(ctx, index)
+}
+
+// IterateEvents walks events from..to (inclusive)
+func (s *Store) IterateEvents(ctx context.Context, from, to uint64, fn storage.EventFunc) error {
+	return s.iterate(ctx, from, to, false, fn)
+}
+
+// IterateEventsReverse walks events to..from (inclusive), newest first
+func (s *Store) IterateEventsReverse(ctx context.Context, from, to uint64, fn storage.EventFunc) error {
+	return s.iterate(ctx, from, to, true, fn)
+}
+
+// iterate collects the range under the read lock and visits it after
+// releasing it, so the view is consistent and fn can't deadlock against a
+// writer.
+func (s *Store) iterate(ctx context.Context, from, to uint64, reverse bool, fn storage.EventFunc) error {
+	if from > to {
+		return nil
+	}
+
+	s.mu.RLock()
+	lo, _ := slices.BinarySearch(s.indices, from)
+	hi, found := slices.BinarySearch(s.indices, to)
+	if found {
+		hi++
+	}
+	events := make([]*model.IndexedEvent, 0, hi-lo)
+	for _, idx := range s.indices[lo:hi] {
+		events = append(events, s.events[idx])
+	}
+	s.mu.RUnlock()
+
+	if reverse {
+		slices.Reverse(events)
+	}
+	for _, e := range events {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		event := *e
+		if err := fn(&event); err != nil {
+			if errors.Is(err, storage.ErrStop) {
+				return nil
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// LatestEvent returns the event with the highest index
+func (s *Store) LatestEvent(ctx context.Context) (*model.IndexedEvent, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return lockedReader{s}.LatestEvent(ctx)
+}
+
+// WriteBatch applies a whole sync step under one lock. The batch is
+// checked before anything changes, so a rejected batch leaves no trace.
+func (s *Store) WriteBatch(ctx context.Context, batch *storage.Batch) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	expected := s.next
+	for _, e := range batch.Events {
+		if e.Index != expected {
+			return fmt.Errorf("non-contiguous event index %d, expected %d", e.Index, expected)
+		}
+		expected++
+	}
+	if batch.NextIndex != expected {
+		return fmt.Errorf("batch next index %d, expected %d", batch.NextIndex, expected)
+	}
+
+	for _, e := range batch.Events {
+		if err := s.putEvent(e); err != nil {
+			return err
+		}
+	}
+	for _, n := range batch.TreeNodes {
+		s.tree[treeKey{n.Level, n.Pos}] = n.Hash
+	}
+	for k, root := range batch.InfoRoots {
+		idx := batch.Events[k].Index
+		s.roots[idx] = root
+		s.lookups.PutInfoRoot(root, idx)
+	}
+
+	s.next = batch.NextIndex
+	cp := batch.Checkpoint
+	s.checkpoint = &cp
+	if batch.Bound != nil {
+		bound := *batch.Bound
+		s.bound = &bound
+	}
+	s.putBlocks(batch.Blocks, batch.Window)
+	return nil
+}
+
+// GetCheckpoint returns the last fully processed block, nil if none
+func (s *Store) GetCheckpoint(ctx context.Context) (*storage.BlockRef, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return lockedReader{s}.GetCheckpoint(ctx)
+}
+
+// GetSyncBound returns the bound recorded with the last batch, nil if none
+func (s *Store) GetSyncBound(ctx context.Context) (*storage.SyncBound, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return lockedReader{s}.GetSyncBound(ctx)
+}
+
+// RecentBlocks returns the stored block window, newest first
+func (s *Store) RecentBlocks(ctx context.Context) ([]storage.BlockRef, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var refs []storage.BlockRef
+	for _, number := range slices.Backward(slices.Sorted(maps.Keys(s.blocks))) {
+		refs = append(refs, storage.BlockRef{Number: number, Hash: s.blocks[number]})
+	}
+	return refs, nil
+}
+
+// Rollback deletes all events, their lookup entries, block hashes and L1
+// info tree entries above the given block, and moves the checkpoint back
+// to it.
+func (s *Store) Rollback(ctx context.Context, block uint64) (uint64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tail := len(s.indices)
+	prev := func() (*model.IndexedEvent, error) {
+		if tail == 0 {
+			return nil, nil
+		}
+		tail--
+		return s.events[s.indices[tail]], nil
+	}
+	// nothing in memory can fail, so the error is always nil
+	next, _ := storage.RollbackTail(s.next, block, prev, func(e *model.IndexedEvent) error {
+		delete(s.events, e.Index)
+		s.indices = s.indices[:len(s.indices)-1]
+		return storage.UnindexEvent(s.lookups, e)
+	})
+
+	for number := range s.blocks {
+		if number > block {
+			delete(s.blocks, number)
+		}
+	}
+
+	hash, ok := s.blocks[block]
+	s.checkpoint = storage.RollbackCheckpoint(block, hash, ok)
+
+	s.truncateTree(next)
+	s.next = next
+	return next, nil
+}
+
+// GetTreeNode returns a stored L1 info tree node
+func (s *Store) GetTreeNode(ctx context.Context, level uint8, pos uint64) (common.Hash, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return lockedReader{s}.GetTreeNode(ctx, level, pos)
+}
+
+// GetInfoRoot returns the L1 info root right after the event at index
+func (s *Store) GetInfoRoot(ctx context.Context, index uint64) (common.Hash, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return lockedReader{s}.GetInfoRoot(ctx, index)
+}
+
+// View runs fn under the read lock, so no write lands until it returns
+func (s *Store) View(ctx context.Context, fn func(storage.Reader) error) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return fn(lockedReader{s})
+}
+
+// lockedReader reads for a caller that already holds mu
+type lockedReader struct {
+	s *Store
+}
+
+func (r lockedReader) GetNextIndex(ctx context.Context) (uint64, error) {
+	return r.s.next, nil
+}
+
+func (r lockedReader) GetEvent(ctx context.Context, index uint64) (*model.IndexedEvent, error) {
+	e, ok := r.s.events[index]
+	if !ok {
+		return nil, storage.ErrNotFound
+	}
+	event := *e
+	return &event, nil
+}
+
+func (r lockedReader) LatestEvent(ctx context.Context) (*model.IndexedEvent, error) {
+	if len(r.s.indices) == 0 {
+		return nil, storage.ErrNotFound
+	}
+	event := *r.s.events[r.s.indices[len(r.s.indices)-1]]
+	return &event, nil
+}
+
+func (r lockedReader) GetCheckpoint(ctx context.Context) (*storage.BlockRef, error) {
+	if r.s.checkpoint == nil {
+		return nil, nil
+	}
+	cp := *r.s.checkpoint
+	return &cp, nil
+}
+
+func (r lockedReader) GetSyncBound(ctx context.Context) (*storage.SyncBound, error) {
+	if r.s.bound == nil {
+		return nil, nil
+	}
+	bound := *r.s.bound
+	return &bound, nil
+}
+
+func (r lockedReader) GetTreeNode(ctx context.Context, level uint8, pos uint64) (common.Hash, error) {
+	h, ok := r.s.tree[treeKey{level, pos}]
+	if !ok {
+		return common.Hash{}, storage.ErrNotFound
+	}
+	return h, nil
+}
+
+func (r lockedReader) GetInfoRoot(ctx context.Context, index uint64) (common.Hash, error) {
+	h, ok := r.s.roots[index]
+	if !ok {
+		return common.Hash{}, storage.ErrNotFound
+	}
+	return h, nil
+}
+
+// Close is a no-op; the store stays usable until it is garbage collected
+func (s *Store) Close() error {
+	return nil
+}
+
+// putEvent stores a copy of e and indexes it, returning any lookup error.
+// The caller holds mu.
+func (s *Store) putEvent(e *model.IndexedEvent) error {
+	event := *e
+	if _, ok := s.events[e.Index]; ok {
+		// an overwrite can move any of the lookups, so rebuild them
+		s.events[e.Index] = &event
+		return s.reindex()
+	}
+
+	i, _ := slices.BinarySearch(s.indices, e.Index)
+	s.indices = slices.Insert(s.indices, i, e.Index)
+	s.events[e.Index] = &event
+	return storage.IndexEvent(s.lookups, &event)
+}
+
+// putBlocks records window entries and prunes all but the newest keep
+func (s *Store) putBlocks(refs []storage.BlockRef, keep int) {
+	for _, ref := range refs {
+		s.blocks[ref.Number] = ref.Hash
+	}
+	if len(s.blocks) <= keep {
+		return
+	}
+	numbers := slices.Sorted(maps.Keys(s.blocks))
+	for _, number := range numbers[:len(numbers)-max(keep, 0)] {
+		delete(s.blocks, number)
+	}
+}
+
+// truncateTree drops roots and tree nodes that only cover leaves at or
+// past count.
+func (s *Store) truncateTree(count uint64) {
+	for idx, root := range s.roots {
+		if idx >= count {
+			delete(s.roots, idx)
+			s.lookups.DeleteInfoRoot(root, idx)
+		}
+	}
+	for k := range s.tree {
+		if k.pos >= storage.FirstStaleNode(count, k.level) {
+			delete(s.tree, k)
+		}
+	}
+}
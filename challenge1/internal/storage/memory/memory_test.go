@@ -0,0 +1,122 @@
+package memory
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/zacksfF/sepolia-sh/ch1/internal/model"
+	"github.com/zacksfF/sepolia-sh/ch1/internal/storage"
+)
+
+func newEvent(index, block uint64) *model.IndexedEvent {
+	return &model.IndexedEvent{
+		Index:          index,
+		BlockNumber:    block,
+		TxHash:         common.BytesToHash([]byte{3, byte(index)}),
+		GlobalExitRoot: common.BytesToHash([]byte{4, byte(index)}),
+	}
+}
+
+// blockBatch is a batch of one block with one event
+func blockBatch(block uint64) *storage.Batch {
+	ref := storage.BlockRef{Number: block, Hash: common.BytesToHash([]byte{byte(block)})}
+	return &storage.Batch{
+		Events:     []*model.IndexedEvent{newEvent(block, block)},
+		NextIndex:  block + 1,
+		Checkpoint: ref,
+		Blocks:     []storage.BlockRef{ref},
+		Window:     10,
+	}
+}
+
+func writeBlock(t *testing.T, s *Store, block uint64) {
+	t.Helper()
+	if err := s.WriteBatch(t.Context(), blockBatch(block)); err != nil {
+		t.Fatalf("Failed to write block %d: %v", block, err)
+	}
+}
+
+func TestStore_Clone(t *testing.T) {
+	ctx := t.Context()
+	s := New()
+	writeBlock(t, s, 0)
+	writeBlock(t, s, 1)
+
+	snap := s.Clone()
+	writeBlock(t, s, 2)
+	if _, err := snap.Rollback(ctx, 0); err != nil {
+		t.Fatalf("Failed to roll back clone: %v", err)
+	}
+
+	// each side only sees its own writes
+	if next, _ := s.GetNextIndex(ctx); next != 3 {
+		t.Errorf("original next index mismatch: got %d, want 3", next)
+	}
+	if next, _ := snap.GetNextIndex(ctx); next != 1 {
+		t.Errorf("clone next index mismatch: got %d, want 1", next)
+	}
+	if _, err := s.GetEvent(ctx, 1); err != nil {
+		t.Errorf("original lost event 1: %v", err)
+	}
+	if _, err := snap.GetEvent(ctx, 2); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("clone GetEvent(2): got %v, want ErrNotFound", err)
+	}
+	if got, err := s.IndicesByTx(ctx, newEvent(1, 1).TxHash); err != nil || len(got) != 1 {
+		t.Errorf("original tx lookup mismatch: got %v (%v), want [1]", got, err)
+	}
+	if _, err := snap.IndicesByTx(ctx, newEvent(1, 1).TxHash); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("clone tx lookup: got %v, want ErrNotFound", err)
+	}
+}
+
+func TestStore_ReturnsCopies(t *testing.T) {
+	ctx := t.Context()
+	s := New()
+	writeBlock(t, s, 0)
+
+	e, err := s.GetEvent(ctx, 0)
+	if err != nil {
+		t.Fatalf("Failed to get event: %v", err)
+	}
+	e.BlockNumber = 99
+
+	if got, _ := s.GetEvent(ctx, 0); got.BlockNumber != 0 {
+		t.Errorf("stored event changed through a returned copy: block %d", got.BlockNumber)
+	}
+}
+
+// run with -race: readers and a writer share the store
+func TestStore_Concurrent(t *testing.T) {
+	ctx := t.Context()
+	s := New()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for block := uint64(0); block < 200; block++ {
+			if err := s.WriteBatch(ctx, blockBatch(block)); err != nil {
+				t.Errorf("Failed to write block %d: %v", block, err)
+				return
+			}
+		}
+	}()
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 200 {
+				_ = s.IterateEvents(ctx, 0, ^uint64(0), func(*model.IndexedEvent) error { return nil })
+				_, _ = s.LatestEvent(ctx)
+				_ = s.Clone()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if next, _ := s.GetNextIndex(ctx); next != 200 {
+		t.Errorf("next index mismatch: got %d, want 200", next)
+	}
+}
@@ -0,0 +1,30 @@
+package pebble
+
+import (
+	"testing"
+
+	"github.com/zacksfF/sepolia-sh/ch1/internal/storage"
+	"github.com/zacksfF/sepolia-sh/ch1/internal/storage/storagetest"
+)
+
+func openTest(t *testing.T, dir string) storage.Store {
+	store, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	return store
+}
+
+func TestStore_Conformance(t *testing.T) {
+	storagetest.Run(t, openTest)
+}
+
+func TestStore_Reopen(t *testing.T) {
+	storagetest.RunReopen(t, openTest)
+}
+
+func TestStore_OpenReadOnly(t *testing.T) {
+	if _, err := OpenReadOnly(t.TempDir()); err == nil {
+		t.Error("expected error opening a missing database read-only")
+	}
+}
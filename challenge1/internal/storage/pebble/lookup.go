@@ -0,0 +1,156 @@
+package pebble
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+
+	"github.com/cockroachdb/pebble"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/zacksfF/sepolia-sh/ch1/internal/model"
+	"github.com/zacksfF/sepolia-sh/ch1/internal/storage"
+)
+
+// EventRangeByBlock returns the first and last index emitted in a block
+func (s *Store) EventRangeByBlock(ctx context.Context, number uint64) (uint64, uint64, error) {
+	return reader{s.db}.EventRangeByBlock(ctx, number)
+}
+
+// IndicesByTx returns every index emitted by a transaction, ascending
+func (s *Store) IndicesByTx(ctx context.Context, hash common.Hash) ([]uint64, error) {
+	return reader{s.db}.IndicesByTx(ctx, hash)
+}
+
+// IndexByGlobalExitRoot returns the index at which a global exit root first appeared
+func (s *Store) IndexByGlobalExitRoot(ctx context.Context, root common.Hash) (uint64, error) {
+	return reader{s.db}.IndexByGlobalExitRoot(ctx, root)
+}
+
+// IndexByInfoRoot returns the index whose insertion produced an L1 info root
+func (s *Store) IndexByInfoRoot(ctx context.Context, root common.Hash) (uint64, error) {
+	return reader{s.db}.IndexByInfoRoot(ctx, root)
+}
+
+func (r reader) EventRangeByBlock(ctx context.Context, number uint64) (uint64, uint64, error) {
+	var first, last uint64
+
+	err := get(r.r, key(prefixByBlock, uint64Bytes(number)), func(v []byte) error {
+		first = binary.BigEndian.Uint64(v)
+		last = binary.BigEndian.Uint64(v[8:])
+		return nil
+	})
+
+	return first, last, err
+}
+
+func (r reader) IndicesByTx(ctx context.Context, hash common.Hash) ([]uint64, error) {
+	lower := key(prefixByTx, hash.Bytes())
+	it, err := r.r.NewIter(&pebble.IterOptions{
+		LowerBound: lower,
+		UpperBound: key(prefixByTx, hash.Bytes(), uint64Bytes(^uint64(0)), []byte{0}),
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer it.Close()
+
+	var indices []uint64
+	for valid := it.First(); valid; valid = it.Next() {
+		indices = append(indices, binary.BigEndian.Uint64(it.Key()[len(lower):]))
+	}
+	if err := it.Error(); err != nil {
+		return nil, err
+	}
+	if len(indices) == 0 {
+		return nil, storage.ErrNotFound
+	}
+	return indices, nil
+}
+
+func (r reader) IndexByGlobalExitRoot(ctx context.Context, root common.Hash) (uint64, error) {
+	return lookupIndex(r.r, key(prefixByGER, root.Bytes()))
+}
+
+func (r reader) IndexByInfoRoot(ctx context.Context, root common.Hash) (uint64, error) {
+	return lookupIndex(r.r, key(prefixByInfoRoot, root.Bytes()))
+}
+
+func lookupIndex(r pebble.Reader, k []byte) (uint64, error) {
+	var idx uint64
+	err := get(r, k, func(v []byte) error {
+		idx = binary.BigEndian.Uint64(v)
+		return nil
+	})
+	return idx, err
+}
+
+// lookupBatch maintains the secondary indexes within an indexed batch
+type lookupBatch struct {
+	b *pebble.Batch
+}
+
+var _ storage.LookupWriter = lookupBatch{}
+
+func (l lookupBatch) BlockRange(number uint64) (uint64, uint64, error) {
+	var first, last uint64
+	err := get(l.b, key(prefixByBlock, uint64Bytes(number)), func(v []byte) error {
+		first = binary.BigEndian.Uint64(v)
+		last = binary.BigEndian.Uint64(v[8:])
+		return nil
+	})
+	return first, last, err
+}
+
+func (l lookupBatch) PutBlockRange(number, first, last uint64) error {
+	return l.b.Set(key(prefixByBlock, uint64Bytes(number)), append(uint64Bytes(first), uint64Bytes(last)...), nil)
+}
+
+func (l lookupBatch) DeleteBlockRange(number uint64) error {
+	return l.b.Delete(key(prefixByBlock, uint64Bytes(number)), nil)
+}
+
+func (l lookupBatch) PutTx(hash common.Hash, index uint64) error {
+	return l.b.Set(txKey(hash, index), nil, nil)
+}
+
+func (l lookupBatch) DeleteTx(hash common.Hash, index uint64) error {
+	return l.b.Delete(txKey(hash, index), nil)
+}
+
+func (l lookupBatch) GERIndex(root common.Hash) (uint64, error) {
+	return lookupIndex(l.b, key(prefixByGER, root.Bytes()))
+}
+
+func (l lookupBatch) PutGERIndex(root common.Hash, index uint64) error {
+	return l.b.Set(key(prefixByGER, root.Bytes()), uint64Bytes(index), nil)
+}
+
+func (l lookupBatch) DeleteGERIndex(root common.Hash) error {
+	return l.b.Delete(key(prefixByGER, root.Bytes()), nil)
+}
+
+// indexEvent adds an event to the block, tx and global exit root indexes
+func indexEvent(b *pebble.Batch, e *model.IndexedEvent) error {
+	return storage.IndexEvent(lookupBatch{b}, e)
+}
+
+// unindexEvent removes an event being rolled back from every index,
+// including the L1 info root it produced
+func unindexEvent(b *pebble.Batch, e *model.IndexedEvent) error {
+	if err := storage.UnindexEvent(lookupBatch{b}, e); err != nil {
+		return err
+	}
+
+	root, err := getHash(b, key(prefixRoot, uint64Bytes(e.Index)))
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return b.Delete(key(prefixByInfoRoot, root.Bytes()), nil)
+}
+
+func txKey(hash common.Hash, index uint64) []byte {
+	return key(prefixByTx, hash.Bytes(), uint64Bytes(index))
+}
@@ -0,0 +1,590 @@
+// Package pebble implements storage.Store on Pebble, an LSM key-value
+// store. It suits write-heavy syncs better than Bolt's B+tree: batches
+// append to a log instead of rewriting pages.
+package pebble
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/cockroachdb/pebble"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/zacksfF/sepolia-sh/ch1/internal/l1infotree"
+	"github.com/zacksfF/sepolia-sh/ch1/internal/model"
+	"github.com/zacksfF/sepolia-sh/ch1/internal/storage"
+)
+
+// Pebble has one keyspace, so every record kind gets a one-byte prefix
+// playing the role of a Bolt bucket. Keys after the prefix use the same
+// big-endian layout as the Bolt store, so they sort the same way.
+const (
+	prefixMeta       byte = 'm' // name -> value
+	prefixEvent      byte = 'e' // index -> binary event
+	prefixBlock      byte = 'b' // number -> hash (reorg window)
+	prefixTree       byte = 't' // level + pos -> node hash
+	prefixRoot       byte = 'r' // index -> L1 info root
+	prefixByBlock    byte = 'B' // number -> first index + last index
+	prefixByTx       byte = 'x' // txHash + index -> empty
+	prefixByGER      byte = 'g' // global exit root -> first index
+	prefixByInfoRoot byte = 'i' // L1 info root -> index
+)
+
+var (
+	indexKey      = metaKey("next_index")
+	checkpointKey = metaKey("checkpoint")
+	boundKey      = metaKey("sync_bound")
+)
+
+// Store implements storage.Store using Pebble
+type Store struct {
+	db *pebble.DB
+
+	// Pebble batches don't conflict-check, so read-modify-write sequences
+	// are serialised here, like Bolt's single writer
+	mu sync.Mutex
+}
+
+func init() {
+	storage.Register("pebble", func(dsn string, readOnly bool) (storage.Store, error) {
+		if readOnly {
+			return OpenReadOnly(dsn)
+		}
+		return Open(dsn)
+	})
+}
+
+// Open opens or creates a Pebble database in the directory dir
+func Open(dir string) (*Store, error) {
+	db, err := pebble.Open(dir, &pebble.Options{})
+	if err != nil {
+		return nil, err
+	}
+	return &Store{db: db}, nil
+}
+
+// OpenReadOnly opens an existing database for reading. Pebble locks the
+// directory even in read-only mode, so this fails while the indexer runs.
+func OpenReadOnly(dir string) (*Store, error) {
+	db, err := pebble.Open(dir, &pebble.Options{ReadOnly: true, ErrorIfNotExists: true})
+	if err != nil {
+		return nil, err
+	}
+	return &Store{db: db}, nil
+}
+
+// GetNextIndex returns the next available event index
+func (s *Store) GetNextIndex(ctx context.Context) (uint64, error) {
+	return reader{s.db}.GetNextIndex(ctx)
+}
+
+// SetNextIndex updates the next available event index
+func (s *Store) SetNextIndex(ctx context.Context, idx uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.db.Set(indexKey, uint64Bytes(idx), pebble.Sync)
+}
+
+// SetSyncBound records the bound in effect without writing a batch
+func (s *Store) SetSyncBound(ctx context.Context, bound storage.SyncBound) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.db.Set(boundKey, encodeSyncBound(bound), pebble.Sync)
+}
+
+// SaveEvent persists a single event and, for an append, its L1 info tree
+// nodes and root
+func (s *Store) SaveEvent(ctx context.Context, e *model.IndexedEvent) error {
+	if e == nil {
+		return errors.New("nil event")
+	}
+
+	data, err := e.MarshalBinary()
+	if err != nil {
+		return fmt.Errorf("marshal event: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b := s.db.NewIndexedBatch()
+	defer b.Close()
+
+	count, err := eventCount(b)
+	if err != nil {
+		return err
+	}
+	plan, err := storage.PlanSave(ctx, reader{b}, count, e)
+	if err != nil {
+		return err
+	}
+
+	idx := uint64Bytes(e.Index)
+	if err := b.Set(key(prefixEvent, idx), data, nil); err != nil {
+		return err
+	}
+	if plan.Old != nil {
+		if err := storage.ReplaceEvent(lookupBatch{b}, plan.Old, e); err != nil {
+			return err
+		}
+		return b.Commit(pebble.Sync)
+	}
+
+	for _, n := range plan.Nodes {
+		if err := b.Set(treeKey(n.Level, n.Pos), n.Hash.Bytes(), nil); err != nil {
+			return err
+		}
+	}
+	if err := b.Set(key(prefixRoot, idx), plan.Root.Bytes(), nil); err != nil {
+		return err
+	}
+	if err := b.Set(key(prefixByInfoRoot, plan.Root.Bytes()), idx, nil); err != nil {
+		return err
+	}
+	if err := indexEvent(b, e); err != nil {
+		return err
+	}
+	return b.Commit(pebble.Sync)
+}
+
+// GetEvent retrieves an event by its index
+func (s *Store) GetEvent(ctx context.Context, index uint64) (*model.IndexedEvent, error) {
+	return reader{s.db}.GetEvent(ctx, index)
+}
+
+// IterateEvents walks events from..to (inclusive). A Pebble iterator reads
+// a consistent point-in-time view, like a Bolt read transaction.
+func (s *Store) IterateEvents(ctx context.Context, from, to uint64, fn storage.EventFunc) error {
+	return s.iterate(ctx, from, to, false, fn)
+}
+
+// IterateEventsReverse walks events to..from (inclusive), newest first
+func (s *Store) IterateEventsReverse(ctx context.Context, from, to uint64, fn storage.EventFunc) error {
+	return s.iterate(ctx, from, to, true, fn)
+}
+
+func (s *Store) iterate(ctx context.Context, from, to uint64, reverse bool, fn storage.EventFunc) error {
+	if from > to {
+		return nil
+	}
+
+	it, err := s.db.NewIter(&pebble.IterOptions{
+		LowerBound: key(prefixEvent, uint64Bytes(from)),
+		UpperBound: upperBound(prefixEvent, to),
+	})
+	if err != nil {
+		return err
+	}
+	defer it.Close()
+
+	valid := it.First()
+	if reverse {
+		valid = it.Last()
+	}
+	for ; valid; valid = step(it, reverse) {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		var e model.IndexedEvent
+		if err := e.UnmarshalBinary(it.Value()); err != nil {
+			return fmt.Errorf("unmarshal event %d: %w", binary.BigEndian.Uint64(it.Key()[1:]), err)
+		}
+		if err := fn(&e); err != nil {
+			if errors.Is(err, storage.ErrStop) {
+				return nil
+			}
+			return err
+		}
+	}
+	return it.Error()
+}
+
+func step(it *pebble.Iterator, reverse bool) bool {
+	if reverse {
+		return it.Prev()
+	}
+	return it.Next()
+}
+
+// LatestEvent returns the event with the highest index
+func (s *Store) LatestEvent(ctx context.Context) (*model.IndexedEvent, error) {
+	return reader{s.db}.LatestEvent(ctx)
+}
+
+// WriteBatch applies a whole sync step as one synced Pebble batch. Event
+// indices must continue exactly from the stored next_index.
+func (s *Store) WriteBatch(ctx context.Context, batch *storage.Batch) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b := s.db.NewIndexedBatch()
+	defer b.Close()
+
+	expected, err := getNextIndex(b)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range batch.Events {
+		if e.Index != expected {
+			return fmt.Errorf("non-contiguous event index %d, expected %d", e.Index, expected)
+		}
+		data, err := e.MarshalBinary()
+		if err != nil {
+			return fmt.Errorf("marshal event: %w", err)
+		}
+		if err := b.Set(key(prefixEvent, uint64Bytes(e.Index)), data, nil); err != nil {
+			return err
+		}
+		if err := indexEvent(b, e); err != nil {
+			return err
+		}
+		expected++
+	}
+	if batch.NextIndex != expected {
+		return fmt.Errorf("batch next index %d, expected %d", batch.NextIndex, expected)
+	}
+
+	for _, n := range batch.TreeNodes {
+		if err := b.Set(treeKey(n.Level, n.Pos), n.Hash.Bytes(), nil); err != nil {
+			return err
+		}
+	}
+
+	for k, root := range batch.InfoRoots {
+		idx := uint64Bytes(batch.Events[k].Index)
+		if err := b.Set(key(prefixRoot, idx), root.Bytes(), nil); err != nil {
+			return err
+		}
+		if err := b.Set(key(prefixByInfoRoot, root.Bytes()), idx, nil); err != nil {
+			return err
+		}
+	}
+
+	if err := b.Set(indexKey, uint64Bytes(batch.NextIndex), nil); err != nil {
+		return err
+	}
+	if err := b.Set(checkpointKey, encodeBlockRef(batch.Checkpoint), nil); err != nil {
+		return err
+	}
+	if batch.Bound != nil {
+		if err := b.Set(boundKey, encodeSyncBound(*batch.Bound), nil); err != nil {
+			return err
+		}
+	}
+	if err := putBlocks(b, batch.Blocks, batch.Window); err != nil {
+		return err
+	}
+
+	return b.Commit(pebble.Sync)
+}
+
+// GetCheckpoint returns the last fully processed block, nil if none
+func (s *Store) GetCheckpoint(ctx context.Context) (*storage.BlockRef, error) {
+	return reader{s.db}.GetCheckpoint(ctx)
+}
+
+// GetSyncBound returns the bound recorded with the last batch, nil if none
+func (s *Store) GetSyncBound(ctx context.Context) (*storage.SyncBound, error) {
+	return reader{s.db}.GetSyncBound(ctx)
+}
+
+// RecentBlocks returns the stored block window, newest first
+func (s *Store) RecentBlocks(ctx context.Context) ([]storage.BlockRef, error) {
+	it, err := s.db.NewIter(prefixBounds(prefixBlock))
+	if err != nil {
+		return nil, err
+	}
+	defer it.Close()
+
+	var refs []storage.BlockRef
+	for valid := it.Last(); valid; valid = it.Prev() {
+		refs = append(refs, storage.BlockRef{
+			Number: binary.BigEndian.Uint64(it.Key()[1:]),
+			Hash:   common.BytesToHash(it.Value()),
+		})
+	}
+	return refs, it.Error()
+}
+
+// Rollback deletes all events, their lookup entries, block hashes and L1
+// info tree entries above the given block in one batch, and moves the
+// checkpoint back to it.
+func (s *Store) Rollback(ctx context.Context, block uint64) (uint64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b := s.db.NewIndexedBatch()
+	defer b.Close()
+
+	next, err := getNextIndex(b)
+	if err != nil {
+		return 0, err
+	}
+
+	it, err := b.NewIter(prefixBounds(prefixEvent))
+	if err != nil {
+		return 0, err
+	}
+	valid := it.Last()
+	prev := func() (*model.IndexedEvent, error) {
+		if !valid {
+			return nil, it.Error()
+		}
+		var e model.IndexedEvent
+		if err := e.UnmarshalBinary(it.Value()); err != nil {
+			return nil, fmt.Errorf("unmarshal event: %w", err)
+		}
+		valid = it.Prev()
+		return &e, nil
+	}
+	next, err = storage.RollbackTail(next, block, prev, func(e *model.IndexedEvent) error {
+		if err := unindexEvent(b, e); err != nil {
+			return err
+		}
+		return b.Delete(key(prefixEvent, uint64Bytes(e.Index)), nil)
+	})
+	if cerr := it.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if err := deleteFrom(b, key(prefixBlock, uint64Bytes(block+1)), prefixBlock); err != nil {
+		return 0, err
+	}
+
+	hash, err := getHash(b, key(prefixBlock, uint64Bytes(block)))
+	if err != nil && !errors.Is(err, storage.ErrNotFound) {
+		return 0, err
+	}
+	if cp := storage.RollbackCheckpoint(block, hash, err == nil); cp != nil {
+		err = b.Set(checkpointKey, encodeBlockRef(*cp), nil)
+	} else {
+		err = b.Delete(checkpointKey, nil)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if err := truncateTree(b, next); err != nil {
+		return 0, err
+	}
+	if err := b.Set(indexKey, uint64Bytes(next), nil); err != nil {
+		return 0, err
+	}
+
+	return next, b.Commit(pebble.Sync)
+}
+
+// GetTreeNode returns a stored L1 info tree node
+func (s *Store) GetTreeNode(ctx context.Context, level uint8, pos uint64) (common.Hash, error) {
+	return reader{s.db}.GetTreeNode(ctx, level, pos)
+}
+
+// GetInfoRoot returns the L1 info root right after the event at index
+func (s *Store) GetInfoRoot(ctx context.Context, index uint64) (common.Hash, error) {
+	return reader{s.db}.GetInfoRoot(ctx, index)
+}
+
+// Close releases the database resources
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// putBlocks records window entries and prunes all but the newest keep
+func putBlocks(b *pebble.Batch, refs []storage.BlockRef, keep int) error {
+	for _, ref := range refs {
+		if err := b.Set(key(prefixBlock, uint64Bytes(ref.Number)), ref.Hash.Bytes(), nil); err != nil {
+			return err
+		}
+	}
+
+	it, err := b.NewIter(prefixBounds(prefixBlock))
+	if err != nil {
+		return err
+	}
+	defer it.Close()
+
+	var stale [][]byte
+	n := 0
+	for valid := it.Last(); valid; valid = it.Prev() {
+		n++
+		if n > keep {
+			stale = append(stale, append([]byte(nil), it.Key()...))
+		}
+	}
+	if err := it.Error(); err != nil {
+		return err
+	}
+	for _, k := range stale {
+		if err := b.Delete(k, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// truncateTree drops roots and tree nodes that only cover leaves at or
+// past count.
+func truncateTree(b *pebble.Batch, count uint64) error {
+	if err := deleteFrom(b, key(prefixRoot, uint64Bytes(count)), prefixRoot); err != nil {
+		return err
+	}
+	for level := 0; level <= l1infotree.Height; level++ {
+		first := storage.FirstStaleNode(count, uint8(level))
+		end := treeKey(uint8(level), ^uint64(0))
+		if err := b.DeleteRange(treeKey(uint8(level), first), end, nil); err != nil {
+			return err
+		}
+		if err := b.Delete(end, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteFrom deletes every key from start to the end of prefix
+func deleteFrom(b *pebble.Batch, start []byte, prefix byte) error {
+	return b.DeleteRange(start, []byte{prefix + 1}, nil)
+}
+
+func getNextIndex(r pebble.Reader) (uint64, error) {
+	var idx uint64
+	err := get(r, indexKey, func(v []byte) error {
+		idx = binary.BigEndian.Uint64(v)
+		return nil
+	})
+	if errors.Is(err, storage.ErrNotFound) {
+		return 0, nil
+	}
+	return idx, err
+}
+
+func getHash(r pebble.Reader, k []byte) (common.Hash, error) {
+	var h common.Hash
+	err := get(r, k, func(v []byte) error {
+		h = common.BytesToHash(v)
+		return nil
+	})
+	return h, err
+}
+
+// get passes the value under k to fn, which must not retain it, or returns
+// storage.ErrNotFound
+func get(r pebble.Reader, k []byte, fn func(v []byte) error) error {
+	v, closer, err := r.Get(k)
+	if errors.Is(err, pebble.ErrNotFound) {
+		return storage.ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	defer closer.Close()
+	return fn(v)
+}
+
+// last passes the highest key under prefix to fn, or returns
+// storage.ErrNotFound if there is none
+func last(r pebble.Reader, prefix byte, fn func(k, v []byte) error) error {
+	it, err := r.NewIter(prefixBounds(prefix))
+	if err != nil {
+		return err
+	}
+	defer it.Close()
+
+	if !it.Last() {
+		if err := it.Error(); err != nil {
+			return err
+		}
+		return storage.ErrNotFound
+	}
+	return fn(it.Key(), it.Value())
+}
+
+// eventCount returns how many events are stored, which is one past the
+// highest index since indices are dense
+func eventCount(r pebble.Reader) (uint64, error) {
+	var count uint64
+	err := last(r, prefixEvent, func(k, _ []byte) error {
+		count = binary.BigEndian.Uint64(k[1:]) + 1
+		return nil
+	})
+	if errors.Is(err, storage.ErrNotFound) {
+		return 0, nil
+	}
+	return count, err
+}
+
+func key(prefix byte, parts ...[]byte) []byte {
+	k := []byte{prefix}
+	for _, p := range parts {
+		k = append(k, p...)
+	}
+	return k
+}
+
+func metaKey(name string) []byte {
+	return key(prefixMeta, []byte(name))
+}
+
+func uint64Bytes(v uint64) []byte {
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, v)
+	return buf
+}
+
+// upperBound is the exclusive iterator bound just past index to
+func upperBound(prefix byte, to uint64) []byte {
+	if to == ^uint64(0) {
+		return []byte{prefix + 1}
+	}
+	return key(prefix, uint64Bytes(to+1))
+}
+
+func prefixBounds(prefix byte) *pebble.IterOptions {
+	return &pebble.IterOptions{LowerBound: []byte{prefix}, UpperBound: []byte{prefix + 1}}
+}
+
+// treeKey orders nodes by level, then position
+func treeKey(level uint8, pos uint64) []byte {
+	return key(prefixTree, []byte{level}, uint64Bytes(pos))
+}
+
+// encodeBlockRef packs a block ref as number (8 bytes) + hash (32 bytes)
+func encodeBlockRef(ref storage.BlockRef) []byte {
+	return append(uint64Bytes(ref.Number), ref.Hash[:]...)
+}
+
+func decodeBlockRef(data []byte) (storage.BlockRef, error) {
+	if len(data) != 8+common.HashLength {
+		return storage.BlockRef{}, errors.New("invalid block ref size")
+	}
+	return storage.BlockRef{
+		Number: binary.BigEndian.Uint64(data),
+		Hash:   common.BytesToHash(data[8:]),
+	}, nil
+}
+
+// encodeSyncBound packs a bound as number (8) + confirmations (8) + tag
+func encodeSyncBound(b storage.SyncBound) []byte {
+	buf := append(uint64Bytes(b.Number), uint64Bytes(b.Confirmations)...)
+	return append(buf, b.Tag...)
+}
+
+func decodeSyncBound(data []byte) (storage.SyncBound, error) {
+	if len(data) < 16 {
+		return storage.SyncBound{}, errors.New("invalid sync bound size")
+	}
+	return storage.SyncBound{
+		Number:        binary.BigEndian.Uint64(data),
+		Confirmations: binary.BigEndian.Uint64(data[8:]),
+		Tag:           string(data[16:]),
+	}, nil
+}
@@ -0,0 +1,93 @@
+package pebble
+
+import (
+	"context"
+	"errors"
+
+	"github.com/cockroachdb/pebble"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/zacksfF/sepolia-sh/ch1/internal/model"
+	"github.com/zacksfF/sepolia-sh/ch1/internal/storage"
+)
+
+// View runs fn on a snapshot taken when it starts
+func (s *Store) View(ctx context.Context, fn func(storage.Reader) error) error {
+	snap := s.db.NewSnapshot()
+	defer snap.Close()
+	return fn(reader{snap})
+}
+
+// reader reads from the database, a snapshot or a batch
+type reader struct {
+	r pebble.Reader
+}
+
+func (r reader) GetNextIndex(ctx context.Context) (uint64, error) {
+	return getNextIndex(r.r)
+}
+
+func (r reader) GetEvent(ctx context.Context, index uint64) (*model.IndexedEvent, error) {
+	var event model.IndexedEvent
+
+	err := get(r.r, key(prefixEvent, uint64Bytes(index)), func(v []byte) error {
+		return event.UnmarshalBinary(v)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
+
+func (r reader) LatestEvent(ctx context.Context) (*model.IndexedEvent, error) {
+	var event model.IndexedEvent
+
+	err := last(r.r, prefixEvent, func(_, v []byte) error {
+		return event.UnmarshalBinary(v)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
+
+func (r reader) GetCheckpoint(ctx context.Context) (*storage.BlockRef, error) {
+	var cp *storage.BlockRef
+
+	err := get(r.r, checkpointKey, func(v []byte) error {
+		ref, err := decodeBlockRef(v)
+		if err != nil {
+			return err
+		}
+		cp = &ref
+		return nil
+	})
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, nil
+	}
+	return cp, err
+}
+
+func (r reader) GetSyncBound(ctx context.Context) (*storage.SyncBound, error) {
+	var bound *storage.SyncBound
+
+	err := get(r.r, boundKey, func(v []byte) error {
+		b, err := decodeSyncBound(v)
+		if err != nil {
+			return err
+		}
+		bound = &b
+		return nil
+	})
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, nil
+	}
+	return bound, err
+}
+
+func (r reader) GetTreeNode(ctx context.Context, level uint8, pos uint64) (common.Hash, error) {
+	return getHash(r.r, treeKey(level, pos))
+}
+
+func (r reader) GetInfoRoot(ctx context.Context, index uint64) (common.Hash, error) {
+	return getHash(r.r, key(prefixRoot, uint64Bytes(index)))
+}
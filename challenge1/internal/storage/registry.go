@@ -0,0 +1,66 @@
+package storage
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Opener opens a backend from its DSN. With readOnly set it must open an
+// existing store without writing to it, and fail if there is none.
+type Opener func(dsn string, readOnly bool) (Store, error)
+
+var (
+	backendsMu sync.RWMutex
+	backends   = make(map[string]Opener)
+)
+
+// Register makes a backend available under name. Backends register
+// themselves from init, so a binary only needs to import them. It panics
+// if name is registered twice.
+func Register(name string, open Opener) {
+	backendsMu.Lock()
+	defer backendsMu.Unlock()
+
+	if open == nil {
+		panic("storage: Register opener is nil")
+	}
+	if _, dup := backends[name]; dup {
+		panic("storage: Register called twice for backend " + name)
+	}
+	backends[name] = open
+}
+
+// Backends returns the names of the registered backends, sorted.
+func Backends() []string {
+	backendsMu.RLock()
+	defer backendsMu.RUnlock()
+
+	names := make([]string, 0, len(backends))
+	for name := range backends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Open opens the named backend for reading and writing.
+func Open(backend, dsn string) (Store, error) {
+	return open(backend, dsn, false)
+}
+
+// OpenReadOnly opens an existing store of the named backend for reading.
+func OpenReadOnly(backend, dsn string) (Store, error) {
+	return open(backend, dsn, true)
+}
+
+func open(backend, dsn string, readOnly bool) (Store, error) {
+	backendsMu.RLock()
+	opener, ok := backends[backend]
+	backendsMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown storage backend %q (registered: %v)", backend, Backends())
+	}
+	return opener(dsn, readOnly)
+}
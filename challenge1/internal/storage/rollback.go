@@ -0,0 +1,57 @@
+package storage
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/zacksfF/sepolia-sh/ch1/internal/model"
+)
+
+// RollbackTail finds the events above block, which a rollback removes.
+// Events are appended in block order, so orphaned ones sit at the tail:
+// prev returns the stored events newest first, nil once there are none
+// left, and the walk stops at the first event at or below block.
+//
+// Every orphan is passed to drop, newest first, after the walk ends, so
+// drop may modify what prev reads. RollbackTail returns the new next
+// index: the index of the oldest orphan, or next if there are none.
+func RollbackTail(next, block uint64, prev func() (*model.IndexedEvent, error), drop func(*model.IndexedEvent) error) (uint64, error) {
+	var orphaned []*model.IndexedEvent
+	for {
+		e, err := prev()
+		if err != nil {
+			return 0, err
+		}
+		if e == nil || e.BlockNumber <= block {
+			break
+		}
+		orphaned = append(orphaned, e)
+		next = e.Index
+	}
+
+	for _, e := range orphaned {
+		if err := drop(e); err != nil {
+			return 0, err
+		}
+	}
+	return next, nil
+}
+
+// RollbackCheckpoint returns the checkpoint after a rollback to block,
+// given its hash in the reorg window if found. The ancestor is canonical
+// by definition, so it becomes the checkpoint; if it fell out of the
+// window there is none.
+func RollbackCheckpoint(block uint64, hash common.Hash, found bool) *BlockRef {
+	if !found {
+		return nil
+	}
+	return &BlockRef{Number: block, Hash: hash}
+}
+
+// FirstStaleNode is the first tree position at level whose subtree starts
+// at or after count, once a rollback leaves count leaves. Nodes from there
+// on only cover removed leaves and are deleted along with their roots.
+//
+// Nodes straddling count keep stale values, which is fine: readers only
+// trust nodes of full subtrees and the next append rewrites them.
+func FirstStaleNode(count uint64, level uint8) uint64 {
+	return (count + (1 << level) - 1) >> level
+}
@@ -0,0 +1,68 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/zacksfF/sepolia-sh/ch1/internal/model"
+)
+
+func TestFirstStaleNode(t *testing.T) {
+	tests := []struct {
+		count uint64
+		level uint8
+		want  uint64
+	}{
+		{0, 0, 0},
+		{5, 0, 5},
+		{5, 1, 3}, // node 2 covers leaves 4 and 5, straddling count
+		{5, 2, 2},
+		{8, 3, 1},
+		{9, 3, 2},
+		{1, 32, 1},
+	}
+	for _, tt := range tests {
+		if got := FirstStaleNode(tt.count, tt.level); got != tt.want {
+			t.Errorf("FirstStaleNode(%d, %d) = %d, want %d", tt.count, tt.level, got, tt.want)
+		}
+	}
+}
+
+func TestRollbackTail(t *testing.T) {
+	var events []*model.IndexedEvent
+	for i, block := range []uint64{10, 10, 11, 12, 12} {
+		events = append(events, &model.IndexedEvent{Index: uint64(i), BlockNumber: block})
+	}
+
+	tail := len(events)
+	prev := func() (*model.IndexedEvent, error) {
+		if tail == 0 {
+			return nil, nil
+		}
+		tail--
+		return events[tail], nil
+	}
+	var dropped []uint64
+	next, err := RollbackTail(5, 10, prev, func(e *model.IndexedEvent) error {
+		dropped = append(dropped, e.Index)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to roll back: %v", err)
+	}
+	if next != 2 {
+		t.Errorf("next mismatch: got %d, want 2", next)
+	}
+	if len(dropped) != 3 || dropped[0] != 4 || dropped[2] != 2 {
+		t.Errorf("dropped mismatch: got %v, want [4 3 2]", dropped)
+	}
+
+	// nothing above the block leaves next alone
+	tail = 2
+	next, err = RollbackTail(7, 10, prev, func(*model.IndexedEvent) error {
+		t.Error("unexpected drop")
+		return nil
+	})
+	if err != nil || next != 7 {
+		t.Errorf("next mismatch: got %d, %v, want 7", next, err)
+	}
+}
@@ -0,0 +1,48 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/zacksfF/sepolia-sh/ch1/internal/l1infotree"
+	"github.com/zacksfF/sepolia-sh/ch1/internal/model"
+)
+
+// SavePlan is what SaveEvent writes for one event: either an overwrite of
+// Old, which leaves the L1 info tree alone, or an append that writes Nodes
+// and Root like a one-event batch.
+type SavePlan struct {
+	Old   *model.IndexedEvent // event being overwritten, nil for an append
+	Nodes []l1infotree.Node   // tree nodes touched by an append
+	Root  common.Hash         // L1 info root after an append
+}
+
+// PlanSave checks e against the count events stored in r and works out
+// what SaveEvent has to write. Indices are dense, so e must overwrite a
+// stored event or directly follow the last one. An overwrite may not
+// change the event's leaf, since every later root depends on it.
+func PlanSave(ctx context.Context, r Reader, count uint64, e *model.IndexedEvent) (*SavePlan, error) {
+	leaf := l1infotree.LeafHash(e.GlobalExitRoot, e.ParentHash, e.BlockTime)
+
+	switch {
+	case e.Index > count:
+		return nil, fmt.Errorf("event %d leaves a gap after %d stored events", e.Index, count)
+	case e.Index < count:
+		old, err := r.GetEvent(ctx, e.Index)
+		if err != nil {
+			return nil, fmt.Errorf("get event %d: %w", e.Index, err)
+		}
+		if l1infotree.LeafHash(old.GlobalExitRoot, old.ParentHash, old.BlockTime) != leaf {
+			return nil, fmt.Errorf("overwriting event %d would change its L1 info tree leaf", e.Index)
+		}
+		return &SavePlan{Old: old}, nil
+	}
+
+	tree, err := l1infotree.Load(ctx, r, count)
+	if err != nil {
+		return nil, err
+	}
+	nodes, root := tree.Append(leaf)
+	return &SavePlan{Nodes: nodes, Root: root}, nil
+}
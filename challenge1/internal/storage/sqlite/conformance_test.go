@@ -0,0 +1,49 @@
+package sqlite
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/zacksfF/sepolia-sh/ch1/internal/storage"
+	"github.com/zacksfF/sepolia-sh/ch1/internal/storage/storagetest"
+)
+
+func openTest(t *testing.T, dir string) storage.Store {
+	store, err := Open(filepath.Join(dir, "conformance.sqlite"))
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	return store
+}
+
+func TestStore_Conformance(t *testing.T) {
+	storagetest.Run(t, openTest)
+}
+
+func TestStore_Reopen(t *testing.T) {
+	storagetest.RunReopen(t, openTest)
+}
+
+func TestStore_OpenReadOnly(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ro.sqlite")
+	if _, err := OpenReadOnly(path); err == nil {
+		t.Fatal("expected error opening a missing database read-only")
+	}
+
+	w, err := Open(path)
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer w.Close()
+
+	// the reader shares the file with a live writer
+	r, err := OpenReadOnly(path)
+	if err != nil {
+		t.Fatalf("Failed to open read-only: %v", err)
+	}
+	defer r.Close()
+
+	if err := r.SetNextIndex(t.Context(), 5); err == nil {
+		t.Error("expected write through a read-only store to fail")
+	}
+}
@@ -0,0 +1,522 @@
+// Package sqlite implements storage.Store on SQLite, with events as plain
+// rows so the database can be queried directly with SQL.
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/zacksfF/sepolia-sh/ch1/internal/l1infotree"
+	"github.com/zacksfF/sepolia-sh/ch1/internal/model"
+	"github.com/zacksfF/sepolia-sh/ch1/internal/storage"
+
+	_ "modernc.org/sqlite" // pure-Go driver, no cgo
+)
+
+// SQLite integers are signed, so indices and block numbers are stored as
+// int64. Hashes are 32-byte blobs; use hex() to read them in SQL.
+const schema = `
+CREATE TABLE IF NOT EXISTS meta (
+	id                  INTEGER PRIMARY KEY CHECK (id = 1),
+	next_index          INTEGER NOT NULL DEFAULT 0,
+	checkpoint_number   INTEGER,
+	checkpoint_hash     BLOB,
+	bound_tag           TEXT,
+	bound_confirmations INTEGER,
+	bound_number        INTEGER
+);
+INSERT OR IGNORE INTO meta (id) VALUES (1);
+
+CREATE TABLE IF NOT EXISTS events (
+	idx               INTEGER PRIMARY KEY,
+	block_number      INTEGER NOT NULL,
+	block_time        INTEGER NOT NULL,
+	parent_hash       BLOB NOT NULL,
+	tx_hash           BLOB NOT NULL,
+	log_index         INTEGER NOT NULL,
+	mainnet_exit_root BLOB NOT NULL,
+	rollup_exit_root  BLOB NOT NULL,
+	global_exit_root  BLOB NOT NULL
+);
+CREATE INDEX IF NOT EXISTS events_block ON events (block_number);
+CREATE INDEX IF NOT EXISTS events_tx ON events (tx_hash);
+CREATE INDEX IF NOT EXISTS events_ger ON events (global_exit_root);
+
+CREATE TABLE IF NOT EXISTS blocks (
+	number INTEGER PRIMARY KEY,
+	hash   BLOB NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS tree (
+	level INTEGER NOT NULL,
+	pos   INTEGER NOT NULL,
+	hash  BLOB NOT NULL,
+	PRIMARY KEY (level, pos)
+) WITHOUT ROWID;
+
+CREATE TABLE IF NOT EXISTS roots (
+	idx  INTEGER PRIMARY KEY,
+	root BLOB NOT NULL
+);
+CREATE INDEX IF NOT EXISTS roots_root ON roots (root);
+`
+
+const eventColumns = `idx, block_number, block_time, parent_hash, tx_hash, log_index,
+	mainnet_exit_root, rollup_exit_root, global_exit_root`
+
+// Store implements storage.Store using SQLite
+type Store struct {
+	db *sql.DB
+}
+
+func init() {
+	storage.Register("sqlite", func(dsn string, readOnly bool) (storage.Store, error) {
+		if readOnly {
+			return OpenReadOnly(dsn)
+		}
+		return Open(dsn)
+	})
+}
+
+// Open opens or creates the database at dsn, a file path or a file: URI.
+// It runs in WAL mode, so readers in other processes, such as the query
+// server, don't block the indexer.
+func Open(dsn string) (*Store, error) {
+	return open(dsn, false)
+}
+
+// OpenReadOnly opens an existing database for reading. Unlike Bolt and
+// Pebble this works while the indexer is writing to the same file.
+func OpenReadOnly(dsn string) (*Store, error) {
+	return open(dsn, true)
+}
+
+func open(dsn string, readOnly bool) (*Store, error) {
+	if !strings.HasPrefix(dsn, "file:") {
+		dsn = "file:" + dsn
+	}
+	sep := "?"
+	if strings.Contains(dsn, "?") {
+		sep = "&"
+	}
+	dsn += sep + "_pragma=busy_timeout(5000)"
+	if readOnly {
+		dsn += "&mode=ro"
+	} else {
+		// take the write lock at BEGIN, so concurrent writers wait on
+		// busy_timeout instead of failing to upgrade a read lock
+		dsn += "&_pragma=journal_mode(WAL)&_pragma=synchronous(FULL)&_txlock=immediate"
+	}
+
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	if readOnly {
+		err = db.QueryRow(`SELECT count(*) FROM meta`).Scan(new(int))
+	} else {
+		_, err = db.Exec(schema)
+	}
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &Store{db: db}, nil
+}
+
+// GetNextIndex returns the next available event index
+func (s *Store) GetNextIndex(ctx context.Context) (uint64, error) {
+	return reader{s.db}.GetNextIndex(ctx)
+}
+
+// SetNextIndex updates the next available event index
+func (s *Store) SetNextIndex(ctx context.Context, idx uint64) error {
+	_, err := s.db.ExecContext(ctx, `UPDATE meta SET next_index = ?`, toInt(idx))
+	return err
+}
+
+// SetSyncBound records the bound in effect without writing a batch
+func (s *Store) SetSyncBound(ctx context.Context, bound storage.SyncBound) error {
+	_, err := s.db.ExecContext(ctx,
+		`UPDATE meta SET bound_tag = ?, bound_confirmations = ?, bound_number = ?`,
+		bound.Tag, toInt(bound.Confirmations), toInt(bound.Number),
+	)
+	return err
+}
+
+// SaveEvent persists a single event and, for an append, its L1 info tree
+// nodes and root
+func (s *Store) SaveEvent(ctx context.Context, e *model.IndexedEvent) error {
+	if e == nil {
+		return errors.New("nil event")
+	}
+
+	return s.update(ctx, func(tx *sql.Tx) error {
+		var count int64
+		if err := tx.QueryRowContext(ctx, `SELECT coalesce(max(idx) + 1, 0) FROM events`).Scan(&count); err != nil {
+			return err
+		}
+		plan, err := storage.PlanSave(ctx, reader{tx}, uint64(count), e)
+		if err != nil {
+			return err
+		}
+
+		if err := insertEvent(ctx, tx, e, true); err != nil {
+			return err
+		}
+		if plan.Old != nil {
+			return nil
+		}
+		for _, n := range plan.Nodes {
+			if _, err := tx.ExecContext(ctx,
+				`INSERT OR REPLACE INTO tree (level, pos, hash) VALUES (?, ?, ?)`,
+				n.Level, toInt(n.Pos), n.Hash.Bytes(),
+			); err != nil {
+				return err
+			}
+		}
+		_, err = tx.ExecContext(ctx,
+			`INSERT OR REPLACE INTO roots (idx, root) VALUES (?, ?)`,
+			toInt(e.Index), plan.Root.Bytes(),
+		)
+		return err
+	})
+}
+
+// GetEvent retrieves an event by its index
+func (s *Store) GetEvent(ctx context.Context, index uint64) (*model.IndexedEvent, error) {
+	return reader{s.db}.GetEvent(ctx, index)
+}
+
+// IterateEvents walks events from..to (inclusive). A single SELECT reads
+// one consistent snapshot.
+func (s *Store) IterateEvents(ctx context.Context, from, to uint64, fn storage.EventFunc) error {
+	return s.iterate(ctx, from, to, "ASC", fn)
+}
+
+// IterateEventsReverse walks events to..from (inclusive), newest first
+func (s *Store) IterateEventsReverse(ctx context.Context, from, to uint64, fn storage.EventFunc) error {
+	return s.iterate(ctx, from, to, "DESC", fn)
+}
+
+func (s *Store) iterate(ctx context.Context, from, to uint64, order string, fn storage.EventFunc) error {
+	if from > to || from > math.MaxInt64 {
+		return nil
+	}
+
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT `+eventColumns+` FROM events WHERE idx BETWEEN ? AND ? ORDER BY idx `+order,
+		toInt(from), toInt(to))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		e, err := scanEvent(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(e); err != nil {
+			if errors.Is(err, storage.ErrStop) {
+				return nil
+			}
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// LatestEvent returns the event with the highest index
+func (s *Store) LatestEvent(ctx context.Context) (*model.IndexedEvent, error) {
+	return reader{s.db}.LatestEvent(ctx)
+}
+
+// EventRangeByBlock returns the first and last index emitted in a block
+func (s *Store) EventRangeByBlock(ctx context.Context, number uint64) (uint64, uint64, error) {
+	return reader{s.db}.EventRangeByBlock(ctx, number)
+}
+
+// IndicesByTx returns every index emitted by a transaction, ascending
+func (s *Store) IndicesByTx(ctx context.Context, hash common.Hash) ([]uint64, error) {
+	return reader{s.db}.IndicesByTx(ctx, hash)
+}
+
+// IndexByGlobalExitRoot returns the index at which a global exit root first appeared
+func (s *Store) IndexByGlobalExitRoot(ctx context.Context, root common.Hash) (uint64, error) {
+	return reader{s.db}.IndexByGlobalExitRoot(ctx, root)
+}
+
+// IndexByInfoRoot returns the index whose insertion produced an L1 info root
+func (s *Store) IndexByInfoRoot(ctx context.Context, root common.Hash) (uint64, error) {
+	return reader{s.db}.IndexByInfoRoot(ctx, root)
+}
+
+// WriteBatch applies a whole sync step in one SQLite transaction. Event
+// indices must continue exactly from the stored next_index.
+func (s *Store) WriteBatch(ctx context.Context, batch *storage.Batch) error {
+	return s.update(ctx, func(tx *sql.Tx) error {
+		expected, err := getNextIndex(ctx, tx)
+		if err != nil {
+			return err
+		}
+
+		for _, e := range batch.Events {
+			if e.Index != expected {
+				return fmt.Errorf("non-contiguous event index %d, expected %d", e.Index, expected)
+			}
+			if err := insertEvent(ctx, tx, e, false); err != nil {
+				return err
+			}
+			expected++
+		}
+		if batch.NextIndex != expected {
+			return fmt.Errorf("batch next index %d, expected %d", batch.NextIndex, expected)
+		}
+
+		for _, n := range batch.TreeNodes {
+			if _, err := tx.ExecContext(ctx,
+				`INSERT OR REPLACE INTO tree (level, pos, hash) VALUES (?, ?, ?)`,
+				n.Level, toInt(n.Pos), n.Hash.Bytes(),
+			); err != nil {
+				return err
+			}
+		}
+		for k, root := range batch.InfoRoots {
+			if _, err := tx.ExecContext(ctx,
+				`INSERT OR REPLACE INTO roots (idx, root) VALUES (?, ?)`,
+				toInt(batch.Events[k].Index), root.Bytes(),
+			); err != nil {
+				return err
+			}
+		}
+
+		if _, err := tx.ExecContext(ctx,
+			`UPDATE meta SET next_index = ?, checkpoint_number = ?, checkpoint_hash = ?`,
+			toInt(batch.NextIndex), toInt(batch.Checkpoint.Number), batch.Checkpoint.Hash.Bytes(),
+		); err != nil {
+			return err
+		}
+		if b := batch.Bound; b != nil {
+			if _, err := tx.ExecContext(ctx,
+				`UPDATE meta SET bound_tag = ?, bound_confirmations = ?, bound_number = ?`,
+				b.Tag, toInt(b.Confirmations), toInt(b.Number),
+			); err != nil {
+				return err
+			}
+		}
+
+		for _, ref := range batch.Blocks {
+			if _, err := tx.ExecContext(ctx,
+				`INSERT OR REPLACE INTO blocks (number, hash) VALUES (?, ?)`,
+				toInt(ref.Number), ref.Hash.Bytes(),
+			); err != nil {
+				return err
+			}
+		}
+		// keep only the newest Window entries
+		_, err = tx.ExecContext(ctx,
+			`DELETE FROM blocks WHERE number NOT IN (SELECT number FROM blocks ORDER BY number DESC LIMIT ?)`,
+			max(batch.Window, 0),
+		)
+		return err
+	})
+}
+
+// GetCheckpoint returns the last fully processed block, nil if none
+func (s *Store) GetCheckpoint(ctx context.Context) (*storage.BlockRef, error) {
+	return reader{s.db}.GetCheckpoint(ctx)
+}
+
+// GetSyncBound returns the bound recorded with the last batch, nil if none
+func (s *Store) GetSyncBound(ctx context.Context) (*storage.SyncBound, error) {
+	return reader{s.db}.GetSyncBound(ctx)
+}
+
+// RecentBlocks returns the stored block window, newest first
+func (s *Store) RecentBlocks(ctx context.Context) ([]storage.BlockRef, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT number, hash FROM blocks ORDER BY number DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var refs []storage.BlockRef
+	for rows.Next() {
+		var number int64
+		var hash []byte
+		if err := rows.Scan(&number, &hash); err != nil {
+			return nil, err
+		}
+		refs = append(refs, storage.BlockRef{Number: uint64(number), Hash: common.BytesToHash(hash)})
+	}
+	return refs, rows.Err()
+}
+
+// Rollback deletes all events, block hashes and L1 info tree entries above
+// the given block in one transaction, and moves the checkpoint back to it.
+// The lookups are plain indexes on events, so they follow automatically.
+func (s *Store) Rollback(ctx context.Context, block uint64) (uint64, error) {
+	var next uint64
+
+	err := s.update(ctx, func(tx *sql.Tx) error {
+		var err error
+		if next, err = getNextIndex(ctx, tx); err != nil {
+			return err
+		}
+
+		// events are appended in block order, and the events index finds
+		// the orphaned tail directly
+		var first sql.NullInt64
+		if err := tx.QueryRowContext(ctx,
+			`SELECT min(idx) FROM events WHERE block_number > ?`, toInt(block),
+		).Scan(&first); err != nil {
+			return err
+		}
+		if first.Valid {
+			next = uint64(first.Int64)
+		}
+
+		type stmt struct {
+			query string
+			args  []any
+		}
+		stmts := []stmt{
+			{`DELETE FROM events WHERE idx >= ?`, []any{toInt(next)}},
+			{`DELETE FROM roots WHERE idx >= ?`, []any{toInt(next)}},
+			{`DELETE FROM blocks WHERE number > ?`, []any{toInt(block)}},
+			{`UPDATE meta SET next_index = ?`, []any{toInt(next)}},
+		}
+		for level := uint8(0); level <= l1infotree.Height; level++ {
+			first := storage.FirstStaleNode(next, level)
+			stmts = append(stmts, stmt{`DELETE FROM tree WHERE level = ? AND pos >= ?`, []any{level, toInt(first)}})
+		}
+		for _, st := range stmts {
+			if _, err := tx.ExecContext(ctx, st.query, st.args...); err != nil {
+				return err
+			}
+		}
+
+		var hash []byte
+		err = tx.QueryRowContext(ctx, `SELECT hash FROM blocks WHERE number = ?`, toInt(block)).Scan(&hash)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		cp := storage.RollbackCheckpoint(block, common.BytesToHash(hash), err == nil)
+		if cp == nil {
+			_, err = tx.ExecContext(ctx, `UPDATE meta SET checkpoint_number = NULL, checkpoint_hash = NULL`)
+			return err
+		}
+		_, err = tx.ExecContext(ctx,
+			`UPDATE meta SET checkpoint_number = ?, checkpoint_hash = ?`,
+			toInt(cp.Number), cp.Hash.Bytes(),
+		)
+		return err
+	})
+
+	return next, err
+}
+
+// GetTreeNode returns a stored L1 info tree node
+func (s *Store) GetTreeNode(ctx context.Context, level uint8, pos uint64) (common.Hash, error) {
+	return reader{s.db}.GetTreeNode(ctx, level, pos)
+}
+
+// GetInfoRoot returns the L1 info root right after the event at index
+func (s *Store) GetInfoRoot(ctx context.Context, index uint64) (common.Hash, error) {
+	return reader{s.db}.GetInfoRoot(ctx, index)
+}
+
+// Close releases the database resources
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// update runs fn in a write transaction, committing if it returns nil
+func (s *Store) update(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+func getNextIndex(ctx context.Context, q querier) (uint64, error) {
+	var next int64
+	err := q.QueryRowContext(ctx, `SELECT next_index FROM meta`).Scan(&next)
+	return uint64(next), err
+}
+
+func insertEvent(ctx context.Context, q querier, e *model.IndexedEvent, replace bool) error {
+	verb := "INSERT"
+	if replace {
+		verb = "INSERT OR REPLACE"
+	}
+	_, err := q.ExecContext(ctx,
+		verb+` INTO events (`+eventColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		toInt(e.Index), toInt(e.BlockNumber), toInt(e.BlockTime),
+		e.ParentHash.Bytes(), e.TxHash.Bytes(), int64(e.LogIndex),
+		e.MainnetExitRoot.Bytes(), e.RollupExitRoot.Bytes(), e.GlobalExitRoot.Bytes(),
+	)
+	return err
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanEvent(row scanner) (*model.IndexedEvent, error) {
+	var (
+		idx, blockNumber, blockTime, logIndex int64
+
+		parent, tx, mainnet, rollup, ger []byte
+	)
+	err := row.Scan(&idx, &blockNumber, &blockTime, &parent, &tx, &logIndex, &mainnet, &rollup, &ger)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, storage.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.IndexedEvent{
+		Index:           uint64(idx),
+		BlockNumber:     uint64(blockNumber),
+		BlockTime:       uint64(blockTime),
+		ParentHash:      common.BytesToHash(parent),
+		TxHash:          common.BytesToHash(tx),
+		LogIndex:        uint(logIndex),
+		MainnetExitRoot: common.BytesToHash(mainnet),
+		RollupExitRoot:  common.BytesToHash(rollup),
+		GlobalExitRoot:  common.BytesToHash(ger),
+	}, nil
+}
+
+// toInt maps a uint64 onto SQLite's signed integers, saturating at
+// MaxInt64, which no index, block number or timestamp will reach.
+func toInt(v uint64) int64 {
+	if v > math.MaxInt64 {
+		return math.MaxInt64
+	}
+	return int64(v)
+}
@@ -0,0 +1,141 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/zacksfF/sepolia-sh/ch1/internal/model"
+	"github.com/zacksfF/sepolia-sh/ch1/internal/storage"
+)
+
+// View runs fn in one read transaction. In WAL mode it sees the database
+// as of its first read, whatever commits meanwhile.
+func (s *Store) View(ctx context.Context, fn func(storage.Reader) error) error {
+	tx, err := s.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	return fn(reader{tx})
+}
+
+// reader queries the database or a transaction
+type reader struct {
+	q querier
+}
+
+func (r reader) GetNextIndex(ctx context.Context) (uint64, error) {
+	return getNextIndex(ctx, r.q)
+}
+
+func (r reader) GetEvent(ctx context.Context, index uint64) (*model.IndexedEvent, error) {
+	row := r.q.QueryRowContext(ctx, `SELECT `+eventColumns+` FROM events WHERE idx = ?`, toInt(index))
+	return scanEvent(row)
+}
+
+func (r reader) LatestEvent(ctx context.Context) (*model.IndexedEvent, error) {
+	row := r.q.QueryRowContext(ctx, `SELECT `+eventColumns+` FROM events ORDER BY idx DESC LIMIT 1`)
+	return scanEvent(row)
+}
+
+func (r reader) GetCheckpoint(ctx context.Context) (*storage.BlockRef, error) {
+	var number sql.NullInt64
+	var hash []byte
+	err := r.q.QueryRowContext(ctx, `SELECT checkpoint_number, checkpoint_hash FROM meta`).Scan(&number, &hash)
+	if err != nil || !number.Valid {
+		return nil, err
+	}
+	return &storage.BlockRef{Number: uint64(number.Int64), Hash: common.BytesToHash(hash)}, nil
+}
+
+func (r reader) GetSyncBound(ctx context.Context) (*storage.SyncBound, error) {
+	var tag sql.NullString
+	var confirmations, number sql.NullInt64
+	err := r.q.QueryRowContext(ctx,
+		`SELECT bound_tag, bound_confirmations, bound_number FROM meta`,
+	).Scan(&tag, &confirmations, &number)
+	if err != nil || !tag.Valid {
+		return nil, err
+	}
+	return &storage.SyncBound{
+		Tag:           tag.String,
+		Confirmations: uint64(confirmations.Int64),
+		Number:        uint64(number.Int64),
+	}, nil
+}
+
+func (r reader) GetTreeNode(ctx context.Context, level uint8, pos uint64) (common.Hash, error) {
+	return r.getHash(ctx, `SELECT hash FROM tree WHERE level = ? AND pos = ?`, level, toInt(pos))
+}
+
+func (r reader) GetInfoRoot(ctx context.Context, index uint64) (common.Hash, error) {
+	return r.getHash(ctx, `SELECT root FROM roots WHERE idx = ?`, toInt(index))
+}
+
+func (r reader) getHash(ctx context.Context, query string, args ...any) (common.Hash, error) {
+	var b []byte
+	err := r.q.QueryRowContext(ctx, query, args...).Scan(&b)
+	if errors.Is(err, sql.ErrNoRows) {
+		return common.Hash{}, storage.ErrNotFound
+	}
+	return common.BytesToHash(b), err
+}
+
+func (r reader) EventRangeByBlock(ctx context.Context, number uint64) (uint64, uint64, error) {
+	var first, last sql.NullInt64
+	err := r.q.QueryRowContext(ctx,
+		`SELECT min(idx), max(idx) FROM events WHERE block_number = ?`, toInt(number),
+	).Scan(&first, &last)
+	if err != nil {
+		return 0, 0, err
+	}
+	if !first.Valid {
+		return 0, 0, storage.ErrNotFound
+	}
+	return uint64(first.Int64), uint64(last.Int64), nil
+}
+
+func (r reader) IndicesByTx(ctx context.Context, hash common.Hash) ([]uint64, error) {
+	rows, err := r.q.QueryContext(ctx, `SELECT idx FROM events WHERE tx_hash = ? ORDER BY idx`, hash.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var indices []uint64
+	for rows.Next() {
+		var idx int64
+		if err := rows.Scan(&idx); err != nil {
+			return nil, err
+		}
+		indices = append(indices, uint64(idx))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(indices) == 0 {
+		return nil, storage.ErrNotFound
+	}
+	return indices, nil
+}
+
+func (r reader) IndexByGlobalExitRoot(ctx context.Context, root common.Hash) (uint64, error) {
+	return r.lookupIndex(ctx, `SELECT min(idx) FROM events WHERE global_exit_root = ?`, root)
+}
+
+func (r reader) IndexByInfoRoot(ctx context.Context, root common.Hash) (uint64, error) {
+	return r.lookupIndex(ctx, `SELECT min(idx) FROM roots WHERE root = ?`, root)
+}
+
+func (r reader) lookupIndex(ctx context.Context, query string, key common.Hash) (uint64, error) {
+	var idx sql.NullInt64
+	if err := r.q.QueryRowContext(ctx, query, key.Bytes()).Scan(&idx); err != nil {
+		return 0, err
+	}
+	if !idx.Valid {
+		return 0, storage.ErrNotFound
+	}
+	return uint64(idx.Int64), nil
+}
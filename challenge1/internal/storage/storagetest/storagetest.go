@@ -0,0 +1,918 @@
+// Package storagetest is a conformance suite for storage.Store
+// implementations. Every backend runs it from its own tests, so they all
+// agree on ordering, atomicity and ErrNotFound semantics.
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/zacksfF/sepolia-sh/ch1/internal/l1infotree"
+	"github.com/zacksfF/sepolia-sh/ch1/internal/model"
+	"github.com/zacksfF/sepolia-sh/ch1/internal/storage"
+)
+
+// Opener opens the store kept in dir, creating it if needed. It is called
+// again with the same dir to check that state survives a reopen.
+type Opener func(t *testing.T, dir string) storage.Store
+
+// Run checks every storage.Store method against fresh, empty stores.
+func Run(t *testing.T, open Opener) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, s storage.Store)
+	}{
+		{"Empty", testEmpty},
+		{"SaveAndGetEvent", testSaveAndGetEvent},
+		{"SaveEventTree", testSaveEventTree},
+		{"NextIndex", testNextIndex},
+		{"WriteBatch", testWriteBatch},
+		{"WriteBatchRejectsGaps", testWriteBatchRejectsGaps},
+		{"BlockWindow", testBlockWindow},
+		{"IterateEvents", testIterateEvents},
+		{"Lookups", testLookups},
+		{"OverwriteLookups", testOverwriteLookups},
+		{"L1InfoTree", testL1InfoTree},
+		{"View", testView},
+		{"Rollback", testRollback},
+		{"RollbackPastWindow", testRollbackPastWindow},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := open(t, t.TempDir())
+			t.Cleanup(func() { s.Close() })
+			tt.fn(t, s)
+		})
+	}
+}
+
+// RunReopen checks that a committed batch survives closing and reopening
+// the store. Backends that don't persist skip it.
+func RunReopen(t *testing.T, open Opener) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	s := open(t, dir)
+	w := newWriter()
+	w.write(t, s, []uint64{10, 10, 11}, 5)
+	w.bound = storage.SyncBound{Tag: "safe", Number: 20}
+	if err := s.SetSyncBound(ctx, w.bound); err != nil {
+		t.Fatalf("Failed to set sync bound: %v", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Failed to close store: %v", err)
+	}
+
+	s = open(t, dir)
+	defer s.Close()
+
+	next, err := s.GetNextIndex(ctx)
+	if err != nil {
+		t.Fatalf("Failed to get next index: %v", err)
+	}
+	if next != 3 {
+		t.Errorf("next index mismatch: got %d, want 3", next)
+	}
+	checkEvents(t, s, w.events)
+	checkCheckpoint(t, s, w.refs[len(w.refs)-1])
+	checkRoots(t, s, w.roots)
+
+	bound, err := s.GetSyncBound(ctx)
+	if err != nil {
+		t.Fatalf("Failed to get sync bound: %v", err)
+	}
+	if bound == nil || *bound != w.bound {
+		t.Errorf("sync bound mismatch: got %+v, want %+v", bound, w.bound)
+	}
+	if first, last, err := s.EventRangeByBlock(ctx, 10); err != nil || first != 0 || last != 1 {
+		t.Errorf("block 10 range mismatch: got %d-%d (%v), want 0-1", first, last, err)
+	}
+}
+
+func testEmpty(t *testing.T, s storage.Store) {
+	ctx := context.Background()
+
+	next, err := s.GetNextIndex(ctx)
+	if err != nil || next != 0 {
+		t.Errorf("next index mismatch: got %d (%v), want 0", next, err)
+	}
+	if cp, err := s.GetCheckpoint(ctx); err != nil || cp != nil {
+		t.Errorf("checkpoint mismatch: got %+v (%v), want nil", cp, err)
+	}
+	if bound, err := s.GetSyncBound(ctx); err != nil || bound != nil {
+		t.Errorf("sync bound mismatch: got %+v (%v), want nil", bound, err)
+	}
+	if refs, err := s.RecentBlocks(ctx); err != nil || len(refs) != 0 {
+		t.Errorf("recent blocks mismatch: got %+v (%v), want none", refs, err)
+	}
+
+	if _, err := s.GetEvent(ctx, 0); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("GetEvent: got %v, want ErrNotFound", err)
+	}
+	if _, err := s.LatestEvent(ctx); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("LatestEvent: got %v, want ErrNotFound", err)
+	}
+	if _, _, err := s.EventRangeByBlock(ctx, 1); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("EventRangeByBlock: got %v, want ErrNotFound", err)
+	}
+	if _, err := s.IndicesByTx(ctx, common.Hash{1}); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("IndicesByTx: got %v, want ErrNotFound", err)
+	}
+	if _, err := s.IndexByGlobalExitRoot(ctx, common.Hash{1}); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("IndexByGlobalExitRoot: got %v, want ErrNotFound", err)
+	}
+	if _, err := s.IndexByInfoRoot(ctx, common.Hash{1}); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("IndexByInfoRoot: got %v, want ErrNotFound", err)
+	}
+	if _, err := s.GetTreeNode(ctx, 0, 0); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("GetTreeNode: got %v, want ErrNotFound", err)
+	}
+	if _, err := s.GetInfoRoot(ctx, 0); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("GetInfoRoot: got %v, want ErrNotFound", err)
+	}
+
+	err = s.IterateEvents(ctx, 0, ^uint64(0), func(e *model.IndexedEvent) error {
+		t.Errorf("unexpected event %d", e.Index)
+		return nil
+	})
+	if err != nil {
+		t.Errorf("IterateEvents: %v", err)
+	}
+
+	// rolling back an empty store is a no-op
+	if next, err := s.Rollback(ctx, 5); err != nil || next != 0 {
+		t.Errorf("Rollback: got %d (%v), want 0", next, err)
+	}
+}
+
+func testSaveAndGetEvent(t *testing.T, s storage.Store) {
+	ctx := context.Background()
+
+	want := newEvent(0, 12345)
+	if err := s.SaveEvent(ctx, want); err != nil {
+		t.Fatalf("Failed to save event: %v", err)
+	}
+
+	got, err := s.GetEvent(ctx, 0)
+	if err != nil {
+		t.Fatalf("Failed to get event: %v", err)
+	}
+	if *got != *want {
+		t.Errorf("event mismatch: got %+v, want %+v", got, want)
+	}
+
+	// SaveEvent feeds the lookups like WriteBatch does
+	if first, last, err := s.EventRangeByBlock(ctx, 12345); err != nil || first != 0 || last != 0 {
+		t.Errorf("block range mismatch: got %d-%d (%v), want 0-0", first, last, err)
+	}
+	if _, err := s.GetEvent(ctx, 1); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("GetEvent(1): got %v, want ErrNotFound", err)
+	}
+}
+
+func testSaveEventTree(t *testing.T, s storage.Store) {
+	ctx := context.Background()
+
+	// appends extend the L1 info tree like one-event batches
+	tree := l1infotree.New()
+	var roots []common.Hash
+	for i := uint64(0); i < 5; i++ {
+		e := newEvent(i, 100+i)
+		if err := s.SaveEvent(ctx, e); err != nil {
+			t.Fatalf("Failed to save event %d: %v", i, err)
+		}
+		_, root := tree.Append(l1infotree.LeafHash(e.GlobalExitRoot, e.ParentHash, e.BlockTime))
+		roots = append(roots, root)
+	}
+	checkRoots(t, s, roots)
+	for i, root := range roots {
+		if idx, err := s.IndexByInfoRoot(ctx, root); err != nil || idx != uint64(i) {
+			t.Errorf("info root %d index mismatch: got %d (%v)", i, idx, err)
+		}
+	}
+	loaded, err := l1infotree.Load(ctx, s, 5)
+	if err != nil {
+		t.Fatalf("Failed to load tree: %v", err)
+	}
+	if loaded.Root() != tree.Root() {
+		t.Errorf("loaded root mismatch: got %s, want %s", loaded.Root(), tree.Root())
+	}
+	if next, err := s.GetNextIndex(ctx); err != nil || next != 0 {
+		t.Errorf("next index mismatch: got %d (%v), want 0", next, err)
+	}
+
+	// an overwrite that keeps the leaf leaves the tree alone
+	e := newEvent(2, 102)
+	e.LogIndex = 6
+	if err := s.SaveEvent(ctx, e); err != nil {
+		t.Fatalf("Failed to overwrite event: %v", err)
+	}
+	checkEvents(t, s, []*model.IndexedEvent{e})
+	checkRoots(t, s, roots)
+
+	// changing the leaf or leaving a gap is rejected without a trace
+	changed := *e
+	changed.BlockTime++
+	if err := s.SaveEvent(ctx, &changed); err == nil {
+		t.Error("expected error overwriting an event with a different leaf")
+	}
+	checkEvents(t, s, []*model.IndexedEvent{e})
+
+	if err := s.SaveEvent(ctx, newEvent(6, 106)); err == nil {
+		t.Error("expected error saving an event past a gap")
+	}
+	if _, err := s.GetEvent(ctx, 6); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("GetEvent(6): got %v, want ErrNotFound", err)
+	}
+	if _, err := s.GetInfoRoot(ctx, 5); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("GetInfoRoot(5): got %v, want ErrNotFound", err)
+	}
+}
+
+func testNextIndex(t *testing.T, s storage.Store) {
+	ctx := context.Background()
+
+	if err := s.SetNextIndex(ctx, 42); err != nil {
+		t.Fatalf("Failed to set next index: %v", err)
+	}
+	next, err := s.GetNextIndex(ctx)
+	if err != nil {
+		t.Fatalf("Failed to get next index: %v", err)
+	}
+	if next != 42 {
+		t.Errorf("next index mismatch: got %d, want 42", next)
+	}
+}
+
+func testWriteBatch(t *testing.T, s storage.Store) {
+	ctx := context.Background()
+	w := newWriter()
+
+	w.write(t, s, []uint64{100, 100, 101}, 10)
+	checkEvents(t, s, w.events)
+	checkCheckpoint(t, s, w.refs[len(w.refs)-1])
+
+	next, err := s.GetNextIndex(ctx)
+	if err != nil || next != 3 {
+		t.Errorf("next index mismatch: got %d (%v), want 3", next, err)
+	}
+
+	bound, err := s.GetSyncBound(ctx)
+	if err != nil {
+		t.Fatalf("Failed to get sync bound: %v", err)
+	}
+	if bound == nil || *bound != w.bound {
+		t.Errorf("sync bound mismatch: got %+v, want %+v", bound, w.bound)
+	}
+
+	// a batch without a bound keeps the previous one, and an empty batch
+	// still moves the checkpoint
+	first := w.bound
+	w.bound = storage.SyncBound{}
+	w.write(t, s, nil, 10)
+	checkCheckpoint(t, s, w.refs[len(w.refs)-1])
+	if bound, err := s.GetSyncBound(ctx); err != nil || bound == nil || *bound != first {
+		t.Errorf("sync bound mismatch: got %+v (%v), want %+v", bound, err, first)
+	}
+
+	// SetSyncBound replaces the bound and nothing else
+	safe := storage.SyncBound{Tag: "safe", Number: 990}
+	if err := s.SetSyncBound(ctx, safe); err != nil {
+		t.Fatalf("Failed to set sync bound: %v", err)
+	}
+	if bound, err := s.GetSyncBound(ctx); err != nil || bound == nil || *bound != safe {
+		t.Errorf("sync bound mismatch: got %+v (%v), want %+v", bound, err, safe)
+	}
+	checkCheckpoint(t, s, w.refs[len(w.refs)-1])
+
+	latest, err := s.LatestEvent(ctx)
+	if err != nil {
+		t.Fatalf("Failed to get latest event: %v", err)
+	}
+	if *latest != *w.events[2] {
+		t.Errorf("latest event mismatch: got %+v, want %+v", latest, w.events[2])
+	}
+}
+
+func testWriteBatchRejectsGaps(t *testing.T, s storage.Store) {
+	ctx := context.Background()
+	w := newWriter()
+	w.write(t, s, []uint64{100}, 10)
+
+	cp := storage.BlockRef{Number: 200, Hash: common.HexToHash("0x200")}
+	bad := []*storage.Batch{
+		// index 2 skips 1
+		{Events: []*model.IndexedEvent{newEvent(2, 200)}, NextIndex: 3, Checkpoint: cp, Window: 10},
+		// index 0 is already stored
+		{Events: []*model.IndexedEvent{newEvent(0, 200)}, NextIndex: 1, Checkpoint: cp, Window: 10},
+		// next index disagrees with the events
+		{Events: []*model.IndexedEvent{newEvent(1, 200)}, NextIndex: 5, Checkpoint: cp, Window: 10},
+	}
+	for i, b := range bad {
+		if err := s.WriteBatch(ctx, b); err == nil {
+			t.Errorf("batch %d: expected error", i)
+		}
+	}
+
+	// rejected batches leave no trace
+	next, err := s.GetNextIndex(ctx)
+	if err != nil || next != 1 {
+		t.Errorf("next index mismatch: got %d (%v), want 1", next, err)
+	}
+	if _, err := s.GetEvent(ctx, 1); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("GetEvent(1): got %v, want ErrNotFound", err)
+	}
+	if _, _, err := s.EventRangeByBlock(ctx, 200); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("EventRangeByBlock(200): got %v, want ErrNotFound", err)
+	}
+	checkCheckpoint(t, s, w.refs[len(w.refs)-1])
+}
+
+func testBlockWindow(t *testing.T, s storage.Store) {
+	ctx := context.Background()
+	w := newWriter()
+
+	for bn := uint64(1); bn <= 6; bn++ {
+		w.write(t, s, []uint64{bn}, 3)
+	}
+
+	refs, err := s.RecentBlocks(ctx)
+	if err != nil {
+		t.Fatalf("Failed to get recent blocks: %v", err)
+	}
+	if len(refs) != 3 {
+		t.Fatalf("window size mismatch: got %d, want 3", len(refs))
+	}
+	for i, ref := range refs {
+		if want := blockRef(6 - uint64(i)); ref != want {
+			t.Errorf("window[%d] mismatch: got %+v, want %+v", i, ref, want)
+		}
+	}
+}
+
+func testIterateEvents(t *testing.T, s storage.Store) {
+	ctx := context.Background()
+	w := newWriter()
+	w.write(t, s, []uint64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}, 10)
+
+	collect := func(reverse bool, from, to uint64, stopAt int) []uint64 {
+		t.Helper()
+		var got []uint64
+		fn := func(e *model.IndexedEvent) error {
+			if *e != *w.events[e.Index] {
+				t.Errorf("event %d mismatch: got %+v", e.Index, e)
+			}
+			if len(got) == stopAt {
+				return storage.ErrStop
+			}
+			got = append(got, e.Index)
+			return nil
+		}
+		var err error
+		if reverse {
+			err = s.IterateEventsReverse(ctx, from, to, fn)
+		} else {
+			err = s.IterateEvents(ctx, from, to, fn)
+		}
+		if err != nil {
+			t.Fatalf("Failed to iterate: %v", err)
+		}
+		return got
+	}
+
+	tests := []struct {
+		name    string
+		reverse bool
+		from    uint64
+		to      uint64
+		stopAt  int
+		want    []uint64
+	}{
+		{"range", false, 2, 5, -1, []uint64{2, 3, 4, 5}},
+		{"reverse", true, 2, 5, -1, []uint64{5, 4, 3, 2}},
+		{"past end", false, 8, 100, -1, []uint64{8, 9}},
+		{"reverse past end", true, 8, ^uint64(0), -1, []uint64{9, 8}},
+		{"single", false, 0, 0, -1, []uint64{0}},
+		{"empty", false, 5, 4, -1, nil},
+		{"beyond", false, 20, 30, -1, nil},
+		{"stop", false, 0, 9, 3, []uint64{0, 1, 2}},
+		{"reverse stop", true, 0, 9, 2, []uint64{9, 8}},
+	}
+	for _, tt := range tests {
+		got := collect(tt.reverse, tt.from, tt.to, tt.stopAt)
+		if !equalIndices(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	// callback errors other than ErrStop are returned as is
+	boom := errors.New("boom")
+	err := s.IterateEvents(ctx, 0, 9, func(*model.IndexedEvent) error { return boom })
+	if !errors.Is(err, boom) {
+		t.Errorf("callback error: got %v, want %v", err, boom)
+	}
+
+	// a cancelled context ends the scan
+	cctx, cancel := context.WithCancel(ctx)
+	cancel()
+	err = s.IterateEvents(cctx, 0, 9, func(*model.IndexedEvent) error { return nil })
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("cancelled iteration: got %v, want context.Canceled", err)
+	}
+}
+
+func testLookups(t *testing.T, s storage.Store) {
+	ctx := context.Background()
+	w := newWriter()
+
+	// blocks 100, 100, 101, 103; events 0 and 1 share a tx, event 3
+	// repeats the global exit root of event 1
+	w.mutate = func(e *model.IndexedEvent) {
+		switch e.Index {
+		case 1:
+			e.TxHash = w.events[0].TxHash
+		case 3:
+			e.GlobalExitRoot = w.events[1].GlobalExitRoot
+		}
+	}
+	w.write(t, s, []uint64{100, 100, 101, 103}, 10)
+
+	if first, last, err := s.EventRangeByBlock(ctx, 100); err != nil || first != 0 || last != 1 {
+		t.Errorf("block 100 range mismatch: got %d-%d (%v), want 0-1", first, last, err)
+	}
+	if first, last, err := s.EventRangeByBlock(ctx, 103); err != nil || first != 3 || last != 3 {
+		t.Errorf("block 103 range mismatch: got %d-%d (%v), want 3-3", first, last, err)
+	}
+	if _, _, err := s.EventRangeByBlock(ctx, 102); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("block 102: got %v, want ErrNotFound", err)
+	}
+
+	indices, err := s.IndicesByTx(ctx, w.events[0].TxHash)
+	if err != nil || !equalIndices(indices, []uint64{0, 1}) {
+		t.Errorf("tx indices mismatch: got %v (%v), want [0 1]", indices, err)
+	}
+	indices, err = s.IndicesByTx(ctx, w.events[2].TxHash)
+	if err != nil || !equalIndices(indices, []uint64{2}) {
+		t.Errorf("tx indices mismatch: got %v (%v), want [2]", indices, err)
+	}
+
+	if idx, err := s.IndexByGlobalExitRoot(ctx, w.events[1].GlobalExitRoot); err != nil || idx != 1 {
+		t.Errorf("global exit root index mismatch: got %d (%v), want 1", idx, err)
+	}
+	if idx, err := s.IndexByGlobalExitRoot(ctx, w.events[2].GlobalExitRoot); err != nil || idx != 2 {
+		t.Errorf("global exit root index mismatch: got %d (%v), want 2", idx, err)
+	}
+
+	for i, root := range w.roots {
+		if idx, err := s.IndexByInfoRoot(ctx, root); err != nil || idx != uint64(i) {
+			t.Errorf("info root %d index mismatch: got %d (%v)", i, idx, err)
+		}
+	}
+}
+
+func testOverwriteLookups(t *testing.T, s storage.Store) {
+	ctx := context.Background()
+
+	// events 0 and 1 share block 5 and a tx, event 2 is in block 7
+	events := []*model.IndexedEvent{newEvent(0, 5), newEvent(1, 5), newEvent(2, 7)}
+	txA := events[0].TxHash
+	events[1].TxHash = txA
+	for _, e := range events {
+		if err := s.SaveEvent(ctx, e); err != nil {
+			t.Fatalf("Failed to save event %d: %v", e.Index, err)
+		}
+	}
+
+	// moving event 1 to block 6 and another tx keeps event 0's entries
+	moved := *events[1]
+	moved.BlockNumber = 6
+	moved.TxHash = common.Hash{0xb}
+	if err := s.SaveEvent(ctx, &moved); err != nil {
+		t.Fatalf("Failed to overwrite event 1: %v", err)
+	}
+	if first, last, err := s.EventRangeByBlock(ctx, 5); err != nil || first != 0 || last != 0 {
+		t.Errorf("block 5 range mismatch: got %d-%d (%v), want 0-0", first, last, err)
+	}
+	if first, last, err := s.EventRangeByBlock(ctx, 6); err != nil || first != 1 || last != 1 {
+		t.Errorf("block 6 range mismatch: got %d-%d (%v), want 1-1", first, last, err)
+	}
+	if indices, err := s.IndicesByTx(ctx, txA); err != nil || !equalIndices(indices, []uint64{0}) {
+		t.Errorf("old tx indices mismatch: got %v (%v), want [0]", indices, err)
+	}
+	if indices, err := s.IndicesByTx(ctx, moved.TxHash); err != nil || !equalIndices(indices, []uint64{1}) {
+		t.Errorf("new tx indices mismatch: got %v (%v), want [1]", indices, err)
+	}
+	if idx, err := s.IndexByGlobalExitRoot(ctx, moved.GlobalExitRoot); err != nil || idx != 1 {
+		t.Errorf("global exit root index mismatch: got %d (%v), want 1", idx, err)
+	}
+
+	// moving the last event out of block 5 removes its range and tx
+	moved = *events[0]
+	moved.BlockNumber = 6
+	moved.TxHash = common.Hash{0xa}
+	if err := s.SaveEvent(ctx, &moved); err != nil {
+		t.Fatalf("Failed to overwrite event 0: %v", err)
+	}
+	if _, _, err := s.EventRangeByBlock(ctx, 5); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("block 5: got %v, want ErrNotFound", err)
+	}
+	if first, last, err := s.EventRangeByBlock(ctx, 6); err != nil || first != 0 || last != 1 {
+		t.Errorf("block 6 range mismatch: got %d-%d (%v), want 0-1", first, last, err)
+	}
+	if _, err := s.IndicesByTx(ctx, txA); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("old tx: got %v, want ErrNotFound", err)
+	}
+	if first, last, err := s.EventRangeByBlock(ctx, 7); err != nil || first != 2 || last != 2 {
+		t.Errorf("block 7 range mismatch: got %d-%d (%v), want 2-2", first, last, err)
+	}
+}
+
+func testL1InfoTree(t *testing.T, s storage.Store) {
+	ctx := context.Background()
+	w := newWriter()
+
+	// several batches so upper nodes are rewritten across transactions
+	w.write(t, s, []uint64{1, 1, 2}, 10)
+	w.write(t, s, []uint64{3}, 10)
+	w.write(t, s, []uint64{4, 5, 5, 6}, 10)
+	checkRoots(t, s, w.roots)
+
+	count := uint64(len(w.events))
+	loaded, err := l1infotree.Load(ctx, s, count)
+	if err != nil {
+		t.Fatalf("Failed to load tree: %v", err)
+	}
+	if loaded.Root() != w.tree.Root() {
+		t.Errorf("loaded root mismatch: got %s, want %s", loaded.Root(), w.tree.Root())
+	}
+
+	// every leaf proves against the current root and a historical one
+	for i, e := range w.events {
+		leaf := l1infotree.LeafHash(e.GlobalExitRoot, e.ParentHash, e.BlockTime)
+		for _, c := range []uint64{uint64(i) + 1, count} {
+			proof, err := l1infotree.Proof(ctx, s, uint64(i), c)
+			if err != nil {
+				t.Fatalf("Failed to build proof %d/%d: %v", i, c, err)
+			}
+			if !l1infotree.VerifyProof(leaf, uint64(i), proof, w.roots[c-1]) {
+				t.Errorf("proof for leaf %d against root %d does not verify", i, c-1)
+			}
+		}
+	}
+
+	if _, err := s.GetTreeNode(ctx, 0, count); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("GetTreeNode past the tree: got %v, want ErrNotFound", err)
+	}
+}
+
+func testView(t *testing.T, s storage.Store) {
+	ctx := context.Background()
+	w := newWriter()
+	w.write(t, s, []uint64{1, 1, 2}, 10)
+	w.write(t, s, []uint64{3}, 10)
+
+	done := make(chan error, 1)
+	err := s.View(ctx, func(r storage.Reader) error {
+		next, err := r.GetNextIndex(ctx)
+		if err != nil || next != 4 {
+			t.Errorf("next index mismatch: got %d (%v), want 4", next, err)
+		}
+
+		// a write that commits meanwhile, or waits for the view to end,
+		// stays out of it
+		go func() { done <- s.SetNextIndex(ctx, 100) }()
+		select {
+		case err := <-done:
+			done <- err
+		case <-time.After(50 * time.Millisecond):
+		}
+		if next, err := r.GetNextIndex(ctx); err != nil || next != 4 {
+			t.Errorf("next index after a concurrent write: got %d (%v), want 4", next, err)
+		}
+
+		if e, err := r.GetEvent(ctx, 2); err != nil || *e != *w.events[2] {
+			t.Errorf("event mismatch: got %+v (%v), want %+v", e, err, w.events[2])
+		}
+		if _, err := r.GetEvent(ctx, 4); !errors.Is(err, storage.ErrNotFound) {
+			t.Errorf("GetEvent past the end: got %v, want ErrNotFound", err)
+		}
+		if root, err := r.GetInfoRoot(ctx, 3); err != nil || root != w.roots[3] {
+			t.Errorf("info root mismatch: got %s (%v), want %s", root, err, w.roots[3])
+		}
+		if cp, err := r.GetCheckpoint(ctx); err != nil || cp == nil || *cp != w.refs[1] {
+			t.Errorf("checkpoint mismatch: got %+v (%v), want %+v", cp, err, w.refs[1])
+		}
+		if bound, err := r.GetSyncBound(ctx); err != nil || bound == nil || *bound != w.bound {
+			t.Errorf("sync bound mismatch: got %+v (%v), want %+v", bound, err, w.bound)
+		}
+
+		// the lookups read the same snapshot
+		if e, err := r.LatestEvent(ctx); err != nil || *e != *w.events[3] {
+			t.Errorf("latest event mismatch: got %+v (%v), want %+v", e, err, w.events[3])
+		}
+		if first, last, err := r.EventRangeByBlock(ctx, 1); err != nil || first != 0 || last != 1 {
+			t.Errorf("block 1 range mismatch: got %d-%d (%v), want 0-1", first, last, err)
+		}
+		if indices, err := r.IndicesByTx(ctx, w.events[2].TxHash); err != nil || !equalIndices(indices, []uint64{2}) {
+			t.Errorf("tx indices mismatch: got %v (%v), want [2]", indices, err)
+		}
+		if idx, err := r.IndexByGlobalExitRoot(ctx, w.events[3].GlobalExitRoot); err != nil || idx != 3 {
+			t.Errorf("global exit root index mismatch: got %d (%v), want 3", idx, err)
+		}
+		if idx, err := r.IndexByInfoRoot(ctx, w.roots[2]); err != nil || idx != 2 {
+			t.Errorf("info root index mismatch: got %d (%v), want 2", idx, err)
+		}
+
+		e := w.events[1]
+		proof, err := l1infotree.Proof(ctx, r, 1, 4)
+		if err != nil {
+			return err
+		}
+		if !l1infotree.VerifyProof(l1infotree.LeafHash(e.GlobalExitRoot, e.ParentHash, e.BlockTime), 1, proof, w.roots[3]) {
+			t.Error("proof read through the view does not verify")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("View failed: %v", err)
+	}
+
+	if err := <-done; err != nil {
+		t.Fatalf("Failed to set next index: %v", err)
+	}
+	if next, err := s.GetNextIndex(ctx); err != nil || next != 100 {
+		t.Errorf("next index after the view: got %d (%v), want 100", next, err)
+	}
+
+	// an error from fn is returned as is
+	errTest := errors.New("test")
+	if err := s.View(ctx, func(storage.Reader) error { return errTest }); !errors.Is(err, errTest) {
+		t.Errorf("View error mismatch: got %v, want %v", err, errTest)
+	}
+}
+
+func testRollback(t *testing.T, s storage.Store) {
+	ctx := context.Background()
+	w := newWriter()
+
+	// event 4 repeats the global exit root first seen at event 2
+	w.mutate = func(e *model.IndexedEvent) {
+		if e.Index == 4 {
+			e.GlobalExitRoot = w.events[2].GlobalExitRoot
+		}
+	}
+	w.write(t, s, []uint64{100, 100, 101}, 10)
+	w.write(t, s, []uint64{102, 102, 103}, 10)
+
+	// 101 is the common ancestor; blocks 102 and 103 were orphaned
+	next, err := s.Rollback(ctx, 101)
+	if err != nil {
+		t.Fatalf("Failed to roll back: %v", err)
+	}
+	if next != 3 {
+		t.Fatalf("next index mismatch: got %d, want 3", next)
+	}
+	if stored, err := s.GetNextIndex(ctx); err != nil || stored != 3 {
+		t.Errorf("stored next index mismatch: got %d (%v), want 3", stored, err)
+	}
+
+	checkEvents(t, s, w.events[:3])
+	for i := uint64(3); i < 6; i++ {
+		if _, err := s.GetEvent(ctx, i); !errors.Is(err, storage.ErrNotFound) {
+			t.Errorf("event %d: got %v, want ErrNotFound", i, err)
+		}
+		if _, err := s.GetInfoRoot(ctx, i); !errors.Is(err, storage.ErrNotFound) {
+			t.Errorf("info root %d: got %v, want ErrNotFound", i, err)
+		}
+		if _, err := s.IndexByInfoRoot(ctx, w.roots[i]); !errors.Is(err, storage.ErrNotFound) {
+			t.Errorf("info root %d lookup: got %v, want ErrNotFound", i, err)
+		}
+	}
+	if latest, err := s.LatestEvent(ctx); err != nil || latest.Index != 2 {
+		t.Errorf("latest event mismatch: got %+v (%v), want index 2", latest, err)
+	}
+
+	// lookups drop the orphaned events but keep the earlier appearance
+	if _, _, err := s.EventRangeByBlock(ctx, 102); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("block 102: got %v, want ErrNotFound", err)
+	}
+	if _, err := s.IndicesByTx(ctx, w.events[5].TxHash); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("tx of event 5: got %v, want ErrNotFound", err)
+	}
+	if _, err := s.IndexByGlobalExitRoot(ctx, w.events[3].GlobalExitRoot); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("global exit root of event 3: got %v, want ErrNotFound", err)
+	}
+	if idx, err := s.IndexByGlobalExitRoot(ctx, w.events[2].GlobalExitRoot); err != nil || idx != 2 {
+		t.Errorf("global exit root of event 2: got %d (%v), want 2", idx, err)
+	}
+
+	checkCheckpoint(t, s, blockRef(101))
+	refs, err := s.RecentBlocks(ctx)
+	if err != nil {
+		t.Fatalf("Failed to get recent blocks: %v", err)
+	}
+	if len(refs) == 0 || refs[0] != blockRef(101) {
+		t.Errorf("window should end at block 101, got %+v", refs)
+	}
+
+	// the tree is back at three leaves and the fork can be indexed on top
+	loaded, err := l1infotree.Load(ctx, s, next)
+	if err != nil {
+		t.Fatalf("Failed to load tree: %v", err)
+	}
+	if loaded.Root() != w.roots[2] {
+		t.Errorf("restored root mismatch: got %s, want %s", loaded.Root(), w.roots[2])
+	}
+
+	w.tree, w.events, w.roots = loaded, w.events[:3], w.roots[:3]
+	w.salt, w.mutate = 1, nil
+	w.write(t, s, []uint64{102, 104}, 10)
+	checkEvents(t, s, w.events)
+	checkRoots(t, s, w.roots)
+	if idx, err := s.IndexByGlobalExitRoot(ctx, w.events[4].GlobalExitRoot); err != nil || idx != 4 {
+		t.Errorf("global exit root of fork event 4: got %d (%v), want 4", idx, err)
+	}
+}
+
+func testRollbackPastWindow(t *testing.T, s storage.Store) {
+	ctx := context.Background()
+	w := newWriter()
+	w.write(t, s, []uint64{100, 101}, 10)
+
+	// nothing at or below block 50 is known, so the checkpoint goes away
+	next, err := s.Rollback(ctx, 50)
+	if err != nil {
+		t.Fatalf("Failed to roll back: %v", err)
+	}
+	if next != 0 {
+		t.Errorf("next index mismatch: got %d, want 0", next)
+	}
+	if cp, err := s.GetCheckpoint(ctx); err != nil || cp != nil {
+		t.Errorf("checkpoint mismatch: got %+v (%v), want nil", cp, err)
+	}
+	if refs, err := s.RecentBlocks(ctx); err != nil || len(refs) != 0 {
+		t.Errorf("recent blocks mismatch: got %+v (%v), want none", refs, err)
+	}
+}
+
+// writer builds batches the way the indexer does: contiguous indices, one
+// window entry per event block plus the checkpoint, and the tree nodes and
+// roots for every appended leaf.
+type writer struct {
+	tree   *l1infotree.Tree
+	events []*model.IndexedEvent
+	roots  []common.Hash
+	refs   []storage.BlockRef
+	bound  storage.SyncBound
+	salt   uint64 // varies the hashes, to write a fork over the same blocks
+	mutate func(*model.IndexedEvent)
+}
+
+func newWriter() *writer {
+	return &writer{
+		tree:  l1infotree.New(),
+		bound: storage.SyncBound{Tag: "finalized", Confirmations: 2, Number: 1000},
+	}
+}
+
+// write commits one batch with an event per entry of blocks. The
+// checkpoint is the last block, or the block after the previous
+// checkpoint for an empty batch.
+func (w *writer) write(t *testing.T, s storage.Store, blocks []uint64, window int) {
+	t.Helper()
+
+	batch := &storage.Batch{Window: window}
+	nodes := make(map[[2]uint64]l1infotree.Node)
+	seen := make(map[uint64]bool)
+
+	for _, bn := range blocks {
+		e := newEvent(uint64(len(w.events)), bn)
+		e.GlobalExitRoot = hashOf(w.salt<<32 | 1<<20 | e.Index)
+		e.TxHash = hashOf(w.salt<<32 | 2<<20 | e.Index)
+		if w.mutate != nil {
+			w.mutate(e)
+		}
+		w.events = append(w.events, e)
+		batch.Events = append(batch.Events, e)
+
+		touched, root := w.tree.Append(l1infotree.LeafHash(e.GlobalExitRoot, e.ParentHash, e.BlockTime))
+		for _, n := range touched {
+			nodes[[2]uint64{uint64(n.Level), n.Pos}] = n
+		}
+		w.roots = append(w.roots, root)
+		batch.InfoRoots = append(batch.InfoRoots, root)
+
+		if !seen[bn] {
+			seen[bn] = true
+			batch.Blocks = append(batch.Blocks, w.ref(bn))
+		}
+	}
+	for _, n := range nodes {
+		batch.TreeNodes = append(batch.TreeNodes, n)
+	}
+
+	switch {
+	case len(blocks) > 0:
+		batch.Checkpoint = w.ref(blocks[len(blocks)-1])
+	case len(w.refs) > 0:
+		batch.Checkpoint = w.ref(w.refs[len(w.refs)-1].Number + 1)
+	default:
+		batch.Checkpoint = w.ref(1)
+	}
+	batch.Blocks = append(batch.Blocks, batch.Checkpoint)
+	w.refs = append(w.refs, batch.Checkpoint)
+
+	batch.NextIndex = uint64(len(w.events))
+	if w.bound != (storage.SyncBound{}) {
+		bound := w.bound
+		batch.Bound = &bound
+	}
+
+	if err := s.WriteBatch(context.Background(), batch); err != nil {
+		t.Fatalf("Failed to write batch: %v", err)
+	}
+}
+
+func (w *writer) ref(number uint64) storage.BlockRef {
+	if w.salt == 0 {
+		return blockRef(number)
+	}
+	return storage.BlockRef{Number: number, Hash: hashOf(w.salt<<32 | number)}
+}
+
+func blockRef(number uint64) storage.BlockRef {
+	return storage.BlockRef{Number: number, Hash: hashOf(number)}
+}
+
+func newEvent(index, block uint64) *model.IndexedEvent {
+	e := &model.IndexedEvent{
+		Index:           index,
+		BlockNumber:     block,
+		BlockTime:       1700000000 + block*12,
+		ParentHash:      hashOf(block - 1),
+		TxHash:          hashOf(3<<20 | index),
+		LogIndex:        uint(index % 7),
+		MainnetExitRoot: hashOf(4<<20 | index),
+		RollupExitRoot:  hashOf(5<<20 | index),
+	}
+	e.GlobalExitRoot = model.GlobalExitRoot(e.MainnetExitRoot, e.RollupExitRoot)
+	return e
+}
+
+func hashOf(v uint64) common.Hash {
+	return common.BigToHash(new(big.Int).SetUint64(v))
+}
+
+func checkEvents(t *testing.T, s storage.Store, want []*model.IndexedEvent) {
+	t.Helper()
+	for _, e := range want {
+		got, err := s.GetEvent(context.Background(), e.Index)
+		if err != nil {
+			t.Fatalf("Failed to get event %d: %v", e.Index, err)
+		}
+		if *got != *e {
+			t.Errorf("event %d mismatch: got %+v, want %+v", e.Index, got, e)
+		}
+	}
+}
+
+func checkCheckpoint(t *testing.T, s storage.Store, want storage.BlockRef) {
+	t.Helper()
+	cp, err := s.GetCheckpoint(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to get checkpoint: %v", err)
+	}
+	if cp == nil || *cp != want {
+		t.Errorf("checkpoint mismatch: got %+v, want %+v", cp, want)
+	}
+}
+
+func checkRoots(t *testing.T, s storage.Store, want []common.Hash) {
+	t.Helper()
+	for i, root := range want {
+		got, err := s.GetInfoRoot(context.Background(), uint64(i))
+		if err != nil {
+			t.Fatalf("Failed to get info root %d: %v", i, err)
+		}
+		if got != root {
+			t.Errorf("info root %d mismatch: got %s, want %s", i, got, root)
+		}
+	}
+}
+
+func equalIndices(a, b []uint64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -2,10 +2,75 @@ package storage
 
 import (
 	"context"
+	"errors"
 
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/zacksfF/sepolia-sh/ch1/internal/l1infotree"
 	"github.com/zacksfF/sepolia-sh/ch1/internal/model"
 )
 
+var (
+	// ErrNotFound is returned when a requested record doesn't exist.
+	ErrNotFound = errors.New("not found")
+
+	// ErrStop can be returned by an iteration callback to end the
+	// iteration early without error.
+	ErrStop = errors.New("stop iteration")
+
+	// ErrReindexRequired is returned when opening a database written in a
+	// format this version can't read or migrate. Delete it and index again.
+	ErrReindexRequired = errors.New("reindex required")
+)
+
+// EventFunc is called for every event visited by an iteration. The event
+// must not be retained past the call, and fn must not write to the store.
+type EventFunc func(event *model.IndexedEvent) error
+
+// BlockRef identifies a block the indexer has seen as canonical.
+type BlockRef struct {
+	Number uint64      `json:"number"`
+	Hash   common.Hash `json:"hash"`
+}
+
+// SyncBound records how far the indexer was allowed to index: the block
+// behind Tag (latest, safe or finalized) minus Confirmations.
+type SyncBound struct {
+	Tag           string `json:"tag"`
+	Confirmations uint64 `json:"confirmations"`
+	Number        uint64 `json:"number"`
+}
+
+// Batch is the output of one sync step. Stores must apply it atomically:
+// either every field lands or none does.
+type Batch struct {
+	Events     []*model.IndexedEvent // contiguous, starting at the current next index
+	NextIndex  uint64                // next index after the batch
+	Checkpoint BlockRef              // last fully processed block
+	Blocks     []BlockRef            // new reorg window entries
+	Window     int                   // window entries to retain
+	Bound      *SyncBound            // bound in effect for this batch, if any
+
+	TreeNodes []l1infotree.Node // L1 info tree nodes touched by Events
+	InfoRoots []common.Hash     // L1 info root after each of Events
+}
+
+// Reader is the read side of a Store that Store.View offers on a
+// consistent snapshot. Every Store is also a Reader.
+type Reader interface {
+	GetNextIndex(ctx context.Context) (uint64, error)
+	GetEvent(ctx context.Context, index uint64) (*model.IndexedEvent, error)
+	LatestEvent(ctx context.Context) (*model.IndexedEvent, error)
+	GetCheckpoint(ctx context.Context) (*BlockRef, error)
+	GetSyncBound(ctx context.Context) (*SyncBound, error)
+	GetTreeNode(ctx context.Context, level uint8, pos uint64) (common.Hash, error)
+	GetInfoRoot(ctx context.Context, index uint64) (common.Hash, error)
+
+	EventRangeByBlock(ctx context.Context, number uint64) (first, last uint64, err error)
+	IndicesByTx(ctx context.Context, hash common.Hash) ([]uint64, error)
+	IndexByGlobalExitRoot(ctx context.Context, root common.Hash) (uint64, error)
+	IndexByInfoRoot(ctx context.Context, root common.Hash) (uint64, error)
+}
+
 // Store defines the interface for persisting and retrieving indexed events.
 type Store interface {
 	// GetNextIndex returns the next available index for storing events.
@@ -14,12 +79,79 @@ type Store interface {
 	// SetNextIndex updates the next available index.
 	SetNextIndex(ctx context.Context, idx uint64) error
 
-	// SaveEvent persists an indexed event to storage.
+	// SaveEvent persists a single event outside a batch. Indices are dense:
+	// the event either follows the last stored one, extending the L1 info
+	// tree and recording its root like WriteBatch does, or overwrites a
+	// stored event with the same tree leaf. Anything else is an error. The
+	// next index, checkpoint and reorg window are left alone.
 	SaveEvent(ctx context.Context, event *model.IndexedEvent) error
 
 	// GetEvent retrieves an event by its index.
 	GetEvent(ctx context.Context, index uint64) (*model.IndexedEvent, error)
 
+	// IterateEvents calls fn for every event with from <= index <= to in
+	// ascending order, within a single read transaction.
+	IterateEvents(ctx context.Context, from, to uint64, fn EventFunc) error
+
+	// IterateEventsReverse is IterateEvents in descending index order.
+	IterateEventsReverse(ctx context.Context, from, to uint64, fn EventFunc) error
+
+	// LatestEvent returns the event with the highest index, or ErrNotFound
+	// if the store is empty.
+	LatestEvent(ctx context.Context) (*model.IndexedEvent, error)
+
+	// EventRangeByBlock returns the first and last index emitted in a block,
+	// or ErrNotFound if the block emitted none.
+	EventRangeByBlock(ctx context.Context, number uint64) (first, last uint64, err error)
+
+	// IndicesByTx returns the indices emitted by a transaction in ascending
+	// order, or ErrNotFound.
+	IndicesByTx(ctx context.Context, hash common.Hash) ([]uint64, error)
+
+	// IndexByGlobalExitRoot returns the index at which a global exit root
+	// first appeared, or ErrNotFound.
+	IndexByGlobalExitRoot(ctx context.Context, root common.Hash) (uint64, error)
+
+	// IndexByInfoRoot returns the index whose insertion produced the given
+	// L1 info root, or ErrNotFound.
+	IndexByInfoRoot(ctx context.Context, root common.Hash) (uint64, error)
+
+	// WriteBatch commits events, the next index, the checkpoint and the
+	// reorg window in a single transaction.
+	WriteBatch(ctx context.Context, batch *Batch) error
+
+	// GetCheckpoint returns the last fully processed block, or nil if the
+	// store has never completed a batch.
+	GetCheckpoint(ctx context.Context) (*BlockRef, error)
+
+	// GetSyncBound returns the bound recorded with the last batch or
+	// SetSyncBound, or nil.
+	GetSyncBound(ctx context.Context) (*SyncBound, error)
+
+	// SetSyncBound records the bound of a sync that had nothing to write,
+	// so the metadata follows a configuration change without a batch.
+	SetSyncBound(ctx context.Context, bound SyncBound) error
+
+	// RecentBlocks returns the reorg detection window, newest first.
+	RecentBlocks(ctx context.Context) ([]BlockRef, error)
+
+	// Rollback removes every event, block hash and L1 info tree entry above
+	// the given block number, rewinds the next index and moves the
+	// checkpoint back to the given block. It returns the new next index.
+	Rollback(ctx context.Context, block uint64) (uint64, error)
+
+	// GetTreeNode returns a persisted L1 info tree node.
+	GetTreeNode(ctx context.Context, level uint8, pos uint64) (common.Hash, error)
+
+	// View calls fn with a Reader on one consistent snapshot of the store,
+	// so several reads see the same state even while a batch or rollback
+	// commits. The Reader is only valid during fn, and fn must not write to
+	// the store.
+	View(ctx context.Context, fn func(Reader) error) error
+
+	// GetInfoRoot returns the L1 info root right after the event at index.
+	GetInfoRoot(ctx context.Context, index uint64) (common.Hash, error)
+
 	// Close releases storage resources.
 	Close() error
 }
@@ -9,7 +9,7 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/zacksfF/sepolia-sh/ch1/internal/eth"
 	"github.com/zacksfF/sepolia-sh/ch1/internal/indexer"
-	"github.com/zacksfF/sepolia-sh/ch1/internal/storage/bolt"
+	"github.com/zacksfF/sepolia-sh/ch1/internal/storage/memory"
 )
 
 func TestIndexer_Sepolia(t *testing.T) {
@@ -20,23 +20,19 @@ func TestIndexer_Sepolia(t *testing.T) {
 	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
 	defer cancel()
 
-	ethClient, err := eth.Dial(ctx, os.Getenv("RPC_URL"))
+	ethClient, err := eth.Dial(ctx, os.Getenv("RPC_URL"), eth.DefaultRetryPolicy)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	store, err := bolt.Open("test.db")
-	if err != nil {
-		t.Fatal(err)
-	}
-	defer os.Remove("test.db")
-	defer store.Close()
+	store := memory.New()
 
 	idx := indexer.New(
 		ethClient,
 		store,
 		common.HexToAddress(os.Getenv("CONTRACT_ADDRESS")),
 		common.HexToHash(os.Getenv("EVENT_TOPIC")),
+		indexer.Config{},
 	)
 
 	err = idx.Run(ctx, 0, nil, 1000) 
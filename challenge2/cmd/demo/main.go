@@ -25,6 +25,8 @@ func main() {
 	// RPC clients
 	log.Println("Connecting to RPC endpoints...")
 	var clients []*rpc.Client
+	var endpoints []scheduler.Endpoint
+	concurrency := make(map[string]int)
 	for _, ep := range cfg.Endpoints {
 		client, err := rpc.NewClient(ctx, ep.Name, ep.URL)
 		if err != nil {
@@ -32,6 +34,8 @@ func main() {
 			continue
 		}
 		clients = append(clients, client)
+		endpoints = append(endpoints, client)
+		concurrency[ep.Name] = ep.MaxConcurrency
 		log.Printf("Connected to %s", ep.Name)
 	}
 
@@ -57,19 +61,23 @@ func main() {
 	}
 	log.Printf("Demo: scanning blocks %d to %d", startBlock, latestBlock)
 
-	sched := scheduler.New(clients, scheduler.Config{
-		Contract:  cfg.Contract,
-		Topic:     cfg.Topic,
-		BatchSize: cfg.BatchSize,
+	sched := scheduler.New(endpoints, scheduler.Config{
+		Contract:    cfg.Contract,
+		Topic:       cfg.Topic,
+		BatchSize:   cfg.BatchSize,
+		Concurrency: concurrency,
 	})
 
 	start := time.Now()
-	totalLogs, err := sched.Run(ctx, startBlock, latestBlock)
+	totalLogs, dead, err := sched.Run(ctx, startBlock, latestBlock)
 	elapsed := time.Since(start)
 
 	if err != nil {
 		log.Printf("Scheduler stopped: %v", err)
 	}
+	for _, task := range dead {
+		log.Printf("Missing blocks %d-%d: failed on %v: %v", task.FromBlock, task.ToBlock, task.FailedOn, task.LastErr)
+	}
 
 	log.Printf("=== Summary ===")
 	log.Printf("Blocks scanned: %d", latestBlock-startBlock+1)
@@ -7,8 +7,9 @@ import (
 )
 
 type RPCEndpoint struct {
-	Name string
-	URL  string
+	Name           string
+	URL            string
+	MaxConcurrency int // most eth_getLogs calls in flight at once
 }
 
 type Config struct {
@@ -20,9 +21,9 @@ type Config struct {
 
 func DefaultEndpoints() []RPCEndpoint {
 	return []RPCEndpoint{
-		{Name: "publicnode", URL: "https://ethereum-sepolia-rpc.publicnode.com"},
-		{Name: "ankr", URL: "https://rpc.ankr.com/eth_sepolia"},
-		{Name: "drpc", URL: "https://sepolia.drpc.org"},
+		{Name: "publicnode", URL: "https://ethereum-sepolia-rpc.publicnode.com", MaxConcurrency: 4},
+		{Name: "ankr", URL: "https://rpc.ankr.com/eth_sepolia", MaxConcurrency: 2},
+		{Name: "drpc", URL: "https://sepolia.drpc.org", MaxConcurrency: 2},
 	}
 }
 
@@ -25,9 +25,35 @@ type Stats struct {
 	TotalRequests atomic.Int64
 	Failures      atomic.Int64
 	TotalLatency  atomic.Int64 // nanoseconds
+	Trips         atomic.Int64 // circuit breaker openings after failed requests
+	FailedProbes  atomic.Int64 // half-open probes that failed, reopening the breaker
+	Recoveries    atomic.Int64 // half-open probes that succeeded, closing the breaker
+	breakerState  atomic.Int32
 	mu            sync.RWMutex
 }
 
+// BreakerState is the state of an endpoint's circuit breaker.
+type BreakerState int
+
+const (
+	BreakerClosed   BreakerState = iota // requests flow
+	BreakerOpen                         // requests stopped
+	BreakerHalfOpen                     // one probe in flight
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
 // NewClient creates a new RPC client wrapper.
 func NewClient(ctx context.Context, name, url string) (*Client, error) {
 	client, err := ethclient.DialContext(ctx, url)
@@ -56,14 +82,7 @@ func (c *Client) Stats() *Stats {
 func (c *Client) FilterLogs(ctx context.Context, query ethereum.FilterQuery) ([]types.Log, error) {
 	start := time.Now()
 	logs, err := c.client.FilterLogs(ctx, query)
-	latency := time.Since(start)
-
-	c.stats.TotalRequests.Add(1)
-	c.stats.TotalLatency.Add(int64(latency))
-
-	if err != nil {
-		c.stats.Failures.Add(1)
-	}
+	c.stats.Record(time.Since(start), err)
 
 	return logs, err
 }
@@ -78,6 +97,34 @@ func (c *Client) Close() {
 	c.client.Close()
 }
 
+// Record counts a request that took latency and failed with err, if not nil.
+func (s *Stats) Record(latency time.Duration, err error) {
+	s.TotalRequests.Add(1)
+	s.TotalLatency.Add(int64(latency))
+
+	if err != nil {
+		s.Failures.Add(1)
+	}
+}
+
+// RecordTransition records a change of circuit breaker state.
+func (s *Stats) RecordTransition(from, to BreakerState) {
+	s.breakerState.Store(int32(to))
+	switch {
+	case from == BreakerClosed && to == BreakerOpen:
+		s.Trips.Add(1)
+	case from == BreakerHalfOpen && to == BreakerOpen:
+		s.FailedProbes.Add(1)
+	case from == BreakerHalfOpen && to == BreakerClosed:
+		s.Recoveries.Add(1)
+	}
+}
+
+// BreakerState returns the current circuit breaker state.
+func (s *Stats) BreakerState() BreakerState {
+	return BreakerState(s.breakerState.Load())
+}
+
 // GetStats returns a snapshot of the statistics.
 func (s *Stats) GetStats() (requests, failures int64, avgLatency time.Duration) {
 	requests = s.TotalRequests.Load()
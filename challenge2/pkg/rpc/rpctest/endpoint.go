@@ -0,0 +1,173 @@
+// Package rpctest provides a fake RPC endpoint with scripted latency and
+// errors for testing the scheduler without a network.
+package rpctest
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/zacksfF/sepolia-sh/ch2/pkg/rpc"
+)
+
+// Step scripts the outcome of one call.
+type Step struct {
+	Latency time.Duration
+	Err     error
+}
+
+// Endpoint is a fake RPC endpoint. Calls take the endpoint's latency and
+// succeed, unless a scripted step or a failure set with SetErr says
+// otherwise. Every block in a queried range has LogsPerBlock logs
+// matching the query.
+type Endpoint struct {
+	name  string
+	stats *rpc.Stats
+
+	mu           sync.Mutex
+	latency      time.Duration
+	logsPerBlock int
+	head         uint64
+	script       []Step
+	err          error
+	calls        map[string]int
+	inflight     int
+	peak         int
+}
+
+// NewEndpoint creates a fake endpoint whose calls take latency.
+func NewEndpoint(name string, latency time.Duration) *Endpoint {
+	return &Endpoint{
+		name:    name,
+		stats:   &rpc.Stats{Name: name},
+		latency: latency,
+		calls:   make(map[string]int),
+	}
+}
+
+// Name returns the endpoint's identifier.
+func (e *Endpoint) Name() string {
+	return e.name
+}
+
+// Stats returns the endpoint's statistics.
+func (e *Endpoint) Stats() *rpc.Stats {
+	return e.stats
+}
+
+// SetLogsPerBlock sets how many matching logs every block has.
+func (e *Endpoint) SetLogsPerBlock(n int) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.logsPerBlock = n
+}
+
+// SetHead sets the block number returned by BlockNumber.
+func (e *Endpoint) SetHead(n uint64) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.head = n
+}
+
+// Script queues steps for the next FilterLogs calls, one step per call.
+func (e *Endpoint) Script(steps ...Step) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.script = append(e.script, steps...)
+}
+
+// SetErr makes every call fail with err after the usual latency, until
+// it is called again with nil.
+func (e *Endpoint) SetErr(err error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.err = err
+}
+
+// Calls returns the number of calls of the JSON-RPC method so far.
+func (e *Endpoint) Calls(method string) int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.calls[method]
+}
+
+// Peak returns the most calls that were in flight at once.
+func (e *Endpoint) Peak() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.peak
+}
+
+// FilterLogs returns LogsPerBlock logs for every block in the query's
+// range, in block order.
+func (e *Endpoint) FilterLogs(ctx context.Context, query ethereum.FilterQuery) ([]types.Log, error) {
+	start := time.Now()
+	err := e.call(ctx, "eth_getLogs", true)
+	e.stats.Record(time.Since(start), err)
+	if err != nil {
+		return nil, err
+	}
+
+	e.mu.Lock()
+	perBlock := e.logsPerBlock
+	e.mu.Unlock()
+
+	var logs []types.Log
+	for b := query.FromBlock.Uint64(); b <= query.ToBlock.Uint64(); b++ {
+		for k := 0; k < perBlock; k++ {
+			lg := types.Log{BlockNumber: b, Index: uint(k)}
+			if len(query.Addresses) > 0 {
+				lg.Address = query.Addresses[0]
+			}
+			for _, topics := range query.Topics {
+				if len(topics) > 0 {
+					lg.Topics = append(lg.Topics, topics[0])
+				}
+			}
+			logs = append(logs, lg)
+		}
+	}
+	return logs, nil
+}
+
+// BlockNumber returns the head set with SetHead.
+func (e *Endpoint) BlockNumber(ctx context.Context) (uint64, error) {
+	if err := e.call(ctx, "eth_blockNumber", false); err != nil {
+		return 0, err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.head, nil
+}
+
+// call counts a call of method and waits out its latency. Scripted steps
+// only apply to calls that take them.
+func (e *Endpoint) call(ctx context.Context, method string, scripted bool) error {
+	e.mu.Lock()
+	e.calls[method]++
+	e.inflight++
+	e.peak = max(e.peak, e.inflight)
+	latency, err := e.latency, e.err
+	if scripted && err == nil && len(e.script) > 0 {
+		latency, err = e.script[0].Latency, e.script[0].Err
+		e.script = e.script[1:]
+	}
+	e.mu.Unlock()
+	defer func() {
+		e.mu.Lock()
+		e.inflight--
+		e.mu.Unlock()
+	}()
+
+	t := time.NewTimer(latency)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+	}
+	return err
+}
@@ -0,0 +1,202 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/zacksfF/sepolia-sh/ch2/pkg/rpc"
+)
+
+const (
+	// Breaker defaults: trip after 3 consecutive failures, stay open 5s,
+	// give up on the endpoint after 3 failed probes in a row.
+	defaultBreakerThreshold = 3
+	defaultBreakerCooldown  = 5 * time.Second
+	defaultBreakerProbes    = 3
+
+	// maxCooldown caps how long a breaker stays open after failed probes.
+	maxCooldown = time.Minute
+
+	// probeTimeout bounds the eth_blockNumber probe of a half-open breaker.
+	probeTimeout = 10 * time.Second
+)
+
+// breaker is the circuit breaker of one endpoint, shared by its workers.
+// It opens after threshold consecutive failures, and the workers stop
+// pulling tasks. Once the cooldown has passed it turns half-open and one
+// worker probes the endpoint: success closes it, failure opens it again
+// for twice as long, up to maxCooldown. After maxProbes failed probes in a
+// row the breaker gives up, and the endpoint sits out the rest of the run.
+type breaker struct {
+	name      string
+	stats     *rpc.Stats
+	threshold int
+	maxProbes int
+	base      time.Duration // cooldown after the breaker trips
+
+	mu       sync.Mutex
+	state    rpc.BreakerState
+	failures int           // consecutive failures while closed
+	probes   int           // consecutive failed probes
+	gaveUp   bool          // too many failed probes; reset by revive
+	probing  bool          // a half-open probe is in flight
+	cooldown time.Duration // of the current or next opening
+	until    time.Time     // end of the cooldown while open
+	changed  chan struct{} // closed and replaced on every transition
+}
+
+func newBreaker(name string, stats *rpc.Stats, threshold, maxProbes int, cooldown time.Duration) *breaker {
+	return &breaker{
+		name:      name,
+		stats:     stats,
+		threshold: threshold,
+		maxProbes: maxProbes,
+		base:      cooldown,
+		cooldown:  cooldown,
+		changed:   make(chan struct{}),
+	}
+}
+
+// revive gives an endpoint the breaker gave up on another chance, at the
+// start of a run. It stays open with the cooldown over, so its first caller
+// probes it.
+func (b *breaker) revive() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.gaveUp {
+		b.gaveUp = false
+		b.probes = 0
+		b.until = time.Now()
+	}
+}
+
+// allow waits while the breaker is open or half-open. When the cooldown
+// is over, the first caller probes the endpoint for the others. It returns
+// false if ctx is done or the breaker gave up on the endpoint.
+func (b *breaker) allow(ctx context.Context, probe func(context.Context) error) bool {
+	for {
+		b.mu.Lock()
+		if b.gaveUp {
+			b.mu.Unlock()
+			return false
+		}
+		if b.state == rpc.BreakerClosed {
+			b.mu.Unlock()
+			return true
+		}
+
+		wait := time.Until(b.until)
+		if b.state == rpc.BreakerOpen && wait <= 0 {
+			b.transition(rpc.BreakerHalfOpen, "cooldown over, probing")
+		}
+		if b.state == rpc.BreakerHalfOpen && !b.probing {
+			b.probing = true
+			b.mu.Unlock()
+			b.probe(ctx, probe)
+			if ctx.Err() != nil {
+				return false
+			}
+			continue
+		}
+
+		// Half-open waits for the probe; open for the cooldown too
+		changed := b.changed
+		open := b.state == rpc.BreakerOpen
+		b.mu.Unlock()
+		if !b.wait(ctx, changed, open, wait) {
+			return false
+		}
+	}
+}
+
+// wait blocks until changed is closed or, if open, the cooldown is over.
+func (b *breaker) wait(ctx context.Context, changed <-chan struct{}, open bool, cooldown time.Duration) bool {
+	var timeout <-chan time.Time
+	if open {
+		t := time.NewTimer(cooldown)
+		defer t.Stop()
+		timeout = t.C
+	}
+	select {
+	case <-ctx.Done():
+		return false
+	case <-changed:
+	case <-timeout:
+	}
+	return true
+}
+
+// probe runs the half-open probe and closes or reopens the breaker.
+func (b *breaker) probe(ctx context.Context, probe func(context.Context) error) {
+	probeCtx, cancel := context.WithTimeout(ctx, probeTimeout)
+	err := probe(probeCtx)
+	cancel()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+	switch {
+	case ctx.Err() != nil:
+		// not the endpoint's fault; stay half-open for the next caller
+		b.broadcast()
+	case err != nil:
+		b.probes++
+		b.cooldown = min(2*b.cooldown, maxCooldown)
+		b.open(fmt.Sprintf("probe %d failed: %v", b.probes, err))
+		if b.probes >= b.maxProbes {
+			log.Printf("[%s] circuit gave up after %d failed probes", b.name, b.probes)
+			b.gaveUp = true
+		}
+	default:
+		b.failures = 0
+		b.probes = 0
+		b.cooldown = b.base
+		b.transition(rpc.BreakerClosed, "probe succeeded")
+	}
+}
+
+// observe counts the outcome of a request. Outcomes of requests still in
+// flight when the breaker opened are ignored, as are cancelled requests.
+func (b *breaker) observe(err error) {
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state != rpc.BreakerClosed {
+		return
+	}
+	if err == nil {
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.failures >= b.threshold {
+		b.open(fmt.Sprintf("%d consecutive failures, last: %v", b.failures, err))
+	}
+}
+
+// open opens the breaker for the current cooldown. The caller holds b.mu.
+func (b *breaker) open(reason string) {
+	b.until = time.Now().Add(b.cooldown)
+	b.transition(rpc.BreakerOpen, fmt.Sprintf("%s; cooling down for %v", reason, b.cooldown))
+}
+
+// transition moves to state, logging and recording the change. The caller
+// holds b.mu.
+func (b *breaker) transition(state rpc.BreakerState, reason string) {
+	log.Printf("[%s] circuit %s -> %s: %s", b.name, b.state, state, reason)
+	b.stats.RecordTransition(b.state, state)
+	b.state = state
+	b.broadcast()
+}
+
+func (b *breaker) broadcast() {
+	close(b.changed)
+	b.changed = make(chan struct{})
+}
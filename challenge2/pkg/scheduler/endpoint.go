@@ -0,0 +1,20 @@
+package scheduler
+
+import (
+	"context"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/zacksfF/sepolia-sh/ch2/pkg/rpc"
+)
+
+// Endpoint is an RPC endpoint the scheduler distributes work to.
+// *rpc.Client implements it, and so does rpctest.Endpoint for tests.
+type Endpoint interface {
+	Name() string
+	FilterLogs(ctx context.Context, query ethereum.FilterQuery) ([]types.Log, error)
+	BlockNumber(ctx context.Context) (uint64, error)
+	Stats() *rpc.Stats
+}
+
+var _ Endpoint = (*rpc.Client)(nil)
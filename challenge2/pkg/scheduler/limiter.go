@@ -0,0 +1,121 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+// latencyFactor is how far above its baseline an endpoint's latency may
+// rise before the limiter takes it as a sign of overload.
+const latencyFactor = 2
+
+// limiter caps the tasks in flight on one endpoint. The limit adapts
+// between 1 and max: it halves on a failure, drops by one on a response
+// much slower than the baseline, and grows by one after a full limit's
+// worth of healthy responses.
+type limiter struct {
+	name string
+	max  int
+
+	mu        sync.Mutex
+	limit     int
+	inflight  int
+	successes int           // healthy responses since the limit last changed
+	baseline  time.Duration // typical healthy latency
+	changed   chan struct{} // closed and replaced when a slot may be free
+}
+
+func newLimiter(name string, maxLimit int) *limiter {
+	maxLimit = max(maxLimit, 1)
+	return &limiter{
+		name:    name,
+		max:     maxLimit,
+		limit:   maxLimit,
+		changed: make(chan struct{}),
+	}
+}
+
+// acquire waits for a free slot. It returns false if ctx is done.
+func (l *limiter) acquire(ctx context.Context) bool {
+	for {
+		l.mu.Lock()
+		if l.inflight < l.limit {
+			l.inflight++
+			l.mu.Unlock()
+			return true
+		}
+		changed := l.changed
+		l.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return false
+		case <-changed:
+		}
+	}
+}
+
+// release frees a slot taken by acquire.
+func (l *limiter) release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.inflight--
+	l.broadcast()
+}
+
+// observe adjusts the limit after a request that took latency and failed
+// with err, if not nil. Cancelled requests say nothing about the endpoint.
+func (l *limiter) observe(latency time.Duration, err error) {
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	switch {
+	case err != nil:
+		l.setLimit(l.limit/2, "failure")
+	case l.baseline > 0 && latency > latencyFactor*l.baseline:
+		l.setLimit(l.limit-1, "latency "+latency.Round(time.Millisecond).String())
+	default:
+		// the baseline follows the fastest responses down at once and
+		// slower ones up gradually
+		if l.baseline == 0 || latency < l.baseline {
+			l.baseline = latency
+		} else {
+			l.baseline += (latency - l.baseline) / 16
+		}
+		l.successes++
+		if l.successes >= l.limit {
+			l.setLimit(l.limit+1, "healthy")
+		}
+	}
+}
+
+// setLimit clamps and applies a new limit. The caller holds l.mu.
+func (l *limiter) setLimit(limit int, reason string) {
+	l.successes = 0
+	limit = min(max(limit, 1), l.max)
+	if limit == l.limit {
+		return
+	}
+	log.Printf("[%s] concurrency %d -> %d (%s)", l.name, l.limit, limit, reason)
+	l.limit = limit
+	l.broadcast()
+}
+
+func (l *limiter) broadcast() {
+	close(l.changed)
+	l.changed = make(chan struct{})
+}
+
+// current returns the limit in effect.
+func (l *limiter) current() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.limit
+}
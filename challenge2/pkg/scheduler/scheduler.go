@@ -2,90 +2,194 @@ package scheduler
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
+	"sort"
 	"sync"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common"
-	"github.com/zacksfF/sepolia-sh/ch2/pkg/rpc"
 )
 
 // Scheduler orchestrates work distribution across multiple RPC endpoints.
 // It uses a pull-based model where workers independently pull tasks from a shared queue.
 type Scheduler struct {
-	clients  []*rpc.Client
+	clients  []Endpoint
+	limiters []*limiter // by client
+	breakers []*breaker // by client
 	contract common.Address
 	topic    common.Hash
 
-	batchSize  uint64
-	bufferSize int
+	batchSize   uint64
+	bufferSize  int
+	maxAttempts int
+	streamBytes int64
 }
 
 // Config holds scheduler configuration.
 type Config struct {
-	Contract   common.Address
-	Topic      common.Hash
-	BatchSize  uint64 // blocks per task
-	BufferSize int    // task queue buffer size
+	Contract    common.Address
+	Topic       common.Hash
+	BatchSize   uint64 // blocks per task
+	BufferSize  int    // task queue buffer size
+	MaxAttempts int    // attempts per task before it is dead-lettered
+
+	// Concurrency is the most tasks in flight per endpoint, by name;
+	// default 1. Within it, the scheduler adapts to each endpoint's
+	// latency and error rate.
+	Concurrency map[string]int
+
+	// BreakerThreshold is how many consecutive failures open an
+	// endpoint's circuit breaker; default 3. BreakerCooldown is how long
+	// it stays open before a probe; default 5s, doubling per failed probe.
+	// After BreakerProbes failed probes in a row (default 3) the endpoint
+	// sits out the run; once every endpoint does, the remaining tasks are
+	// dead-lettered with ErrNoEndpoints.
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+	BreakerProbes    int
+
+	// StreamBufferBytes bounds the logs Stream holds back while waiting
+	// for an earlier task to finish
+	StreamBufferBytes int64
 }
 
-// New creates a new scheduler with the given RPC clients.
-func New(clients []*rpc.Client, cfg Config) *Scheduler {
+var (
+	// ErrIncomplete is returned by Run when some tasks failed every attempt.
+	ErrIncomplete = errors.New("scan incomplete")
+
+	// ErrNoEndpoints is the error of tasks dead-lettered because every
+	// endpoint's circuit breaker gave up.
+	ErrNoEndpoints = errors.New("no endpoint available")
+)
+
+// New creates a new scheduler with the given RPC endpoints.
+func New(clients []Endpoint, cfg Config) *Scheduler {
 	if cfg.BatchSize == 0 {
 		cfg.BatchSize = 1000
 	}
+	if cfg.BreakerThreshold == 0 {
+		cfg.BreakerThreshold = defaultBreakerThreshold
+	}
+	if cfg.BreakerCooldown == 0 {
+		cfg.BreakerCooldown = defaultBreakerCooldown
+	}
+	if cfg.BreakerProbes == 0 {
+		cfg.BreakerProbes = defaultBreakerProbes
+	}
+
+	limiters := make([]*limiter, len(clients))
+	breakers := make([]*breaker, len(clients))
+	workers := 0
+	for i, client := range clients {
+		limiters[i] = newLimiter(client.Name(), cfg.Concurrency[client.Name()])
+		breakers[i] = newBreaker(client.Name(), client.Stats(), cfg.BreakerThreshold, cfg.BreakerProbes, cfg.BreakerCooldown)
+		workers += limiters[i].max
+	}
+
 	if cfg.BufferSize == 0 {
-		cfg.BufferSize = len(clients) * 2
+		cfg.BufferSize = workers * 2
+	}
+	if cfg.MaxAttempts == 0 {
+		cfg.MaxAttempts = 3
+	}
+	if cfg.StreamBufferBytes == 0 {
+		cfg.StreamBufferBytes = 64 << 20
 	}
 
 	return &Scheduler{
-		clients:    clients,
-		contract:   cfg.Contract,
-		topic:      cfg.Topic,
-		batchSize:  cfg.BatchSize,
-		bufferSize: cfg.BufferSize,
+		clients:     clients,
+		limiters:    limiters,
+		breakers:    breakers,
+		contract:    cfg.Contract,
+		topic:       cfg.Topic,
+		batchSize:   cfg.BatchSize,
+		bufferSize:  cfg.BufferSize,
+		maxAttempts: cfg.MaxAttempts,
+		streamBytes: cfg.StreamBufferBytes,
 	}
 }
 
 // Run executes the scheduler from startBlock to endBlock.
-// Returns the total number of logs found and any error.
-func (s *Scheduler) Run(ctx context.Context, startBlock, endBlock uint64) (int, error) {
-	// Create channels
+// Failed tasks are retried, preferably on another endpoint. Returns the
+// total number of logs found and the dead-letter tasks that failed every
+// attempt, sorted by ID; if there are any, the error wraps ErrIncomplete.
+func (s *Scheduler) Run(ctx context.Context, startBlock, endBlock uint64) (int, []Task, error) {
+	totalTasks := s.countTasks(startBlock, endBlock)
+
+	// Create channels. A task is in the retry queue at most once, so it
+	// has room for all of them and sending to it never blocks.
 	tasks := make(chan Task, s.bufferSize)
+	retries := make(chan Task, totalTasks)
 	results := make(chan Result, s.bufferSize)
 
 	// Start workers
-	var wg sync.WaitGroup
-	for _, client := range s.clients {
-		worker := NewWorker(client, s.contract, s.topic, tasks, results)
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			worker.Run(ctx)
-		}()
-	}
+	workerCtx, stopWorkers := context.WithCancel(ctx)
+	defer stopWorkers()
+	wg := s.startWorkers(workerCtx, tasks, retries, results)
 
 	// Start task generator
-	go s.generateTasks(ctx, startBlock, endBlock, tasks)
+	go s.generateTasks(workerCtx, startBlock, endBlock, tasks, nil)
 
 	// Start result collector
-	totalTasks := s.countTasks(startBlock, endBlock)
-	totalLogs, err := s.collectResults(ctx, results, totalTasks)
+	totalLogs, dead, err := s.collectResults(ctx, results, retries, totalTasks)
 
-	// Wait for workers to finish
+	// Wait for workers to finish; stop those waiting on a breaker or backoff
+	stopWorkers()
 	wg.Wait()
 
 	// Print stats
 	s.printStats()
 
-	return totalLogs, err
+	if err == nil && len(dead) > 0 {
+		err = fmt.Errorf("%d of %d tasks failed: %w", len(dead), totalTasks, ErrIncomplete)
+	}
+	return totalLogs, dead, err
 }
 
-// generateTasks creates tasks and sends them to the task channel.
-func (s *Scheduler) generateTasks(ctx context.Context, start, end uint64, tasks chan<- Task) {
+// startWorkers starts as many workers per client as its concurrency
+// allows, sharing the client, its limiter and its breaker. If every worker
+// stops before the run is over, their breakers gave up and the tasks left
+// are failed with ErrNoEndpoints.
+func (s *Scheduler) startWorkers(ctx context.Context, tasks <-chan Task, retries chan Task, results chan<- Result) *sync.WaitGroup {
+	var workers sync.WaitGroup
+	for i, client := range s.clients {
+		s.breakers[i].revive()
+		for n := 0; n < s.limiters[i].max; n++ {
+			worker := NewWorker(client, s.contract, s.topic, tasks, retries, results)
+			worker.limiter = s.limiters[i]
+			worker.breaker = s.breakers[i]
+			workers.Add(1)
+			go func() {
+				defer workers.Done()
+				worker.Run(ctx)
+			}()
+		}
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		workers.Wait()
+		drain := &Worker{id: "scheduler", tasks: tasks, retries: retries, results: results}
+		drain.failAll(ctx, ErrNoEndpoints)
+	}()
+	return &wg
+}
+
+// generateTasks creates tasks and sends them to the task channel. With a
+// window, it waits for room in the window before each task.
+func (s *Scheduler) generateTasks(ctx context.Context, start, end uint64, tasks chan<- Task, win *window) {
 	defer close(tasks)
 
 	taskID := 0
 	for from := start; from <= end; from += s.batchSize {
+		if win != nil && !win.wait(ctx) {
+			return
+		}
+
 		to := from + s.batchSize - 1
 		if to > end {
 			to = end
@@ -114,35 +218,71 @@ func (s *Scheduler) countTasks(start, end uint64) int {
 	return int((end-start)/s.batchSize) + 1
 }
 
-// collectResults gathers results from workers.
-func (s *Scheduler) collectResults(ctx context.Context, results <-chan Result, totalTasks int) (int, error) {
+// collectResults gathers results from workers. A failed task goes back on
+// the retry queue until it has failed maxAttempts times, then into the
+// returned dead-letter list. Once every task has completed or given up, the
+// retry queue is closed, which stops the workers.
+func (s *Scheduler) collectResults(ctx context.Context, results <-chan Result, retries chan<- Task, totalTasks int) (int, []Task, error) {
 	totalLogs := 0
-	completed := 0
+	resolved := 0
+	var dead []Task
 
-	for completed < totalTasks {
+	for resolved < totalTasks {
 		select {
 		case <-ctx.Done():
-			return totalLogs, ctx.Err()
+			return totalLogs, dead, ctx.Err()
 		case result := <-results:
-			completed++
-			if result.Err != nil {
-				log.Printf("Task %d failed: %v", result.Task.ID, result.Err)
-				// In production, you might want to retry failed tasks
+			if result.Err == nil {
+				resolved++
+				totalLogs += result.LogCount
 				continue
 			}
-			totalLogs += result.LogCount
+
+			if task, ok := s.fail(result, retries); !ok {
+				dead = append(dead, task)
+				resolved++
+			}
 		}
 	}
+	close(retries)
+
+	sort.Slice(dead, func(i, j int) bool { return dead[i].ID < dead[j].ID })
+	return totalLogs, dead, nil
+}
 
-	return totalLogs, nil
+// fail records a failed attempt and requeues the task. It returns false,
+// leaving the task off the queue, once the task has no attempts left.
+func (s *Scheduler) fail(result Result, retries chan<- Task) (Task, bool) {
+	task := result.Task
+	if result.final {
+		task.LastErr = result.Err
+		log.Printf("Task %d (blocks %d-%d) dropped: %v", task.ID, task.FromBlock, task.ToBlock, result.Err)
+		return task, false
+	}
+
+	task.Attempts++
+	task.FailedOn = append(task.FailedOn[:len(task.FailedOn):len(task.FailedOn)], result.WorkerID)
+	task.LastErr = result.Err
+	task.handedBackAt = time.Time{}
+
+	if task.Attempts >= s.maxAttempts {
+		log.Printf("Task %d (blocks %d-%d) failed %d times, giving up: %v",
+			task.ID, task.FromBlock, task.ToBlock, task.Attempts, result.Err)
+		return task, false
+	}
+	log.Printf("Task %d failed on %s (attempt %d/%d), requeueing: %v",
+		task.ID, result.WorkerID, task.Attempts, s.maxAttempts, result.Err)
+	retries <- task
+	return task, true
 }
 
 // printStats logs the final statistics for each RPC.
 func (s *Scheduler) printStats() {
 	log.Println("=== RPC Statistics ===")
-	for _, client := range s.clients {
+	for i, client := range s.clients {
 		requests, failures, avgLatency := client.Stats().GetStats()
-		log.Printf("[%s] requests=%d failures=%d avg_latency=%v",
-			client.Name(), requests, failures, avgLatency)
+		log.Printf("[%s] requests=%d failures=%d avg_latency=%v concurrency=%d/%d circuit=%s trips=%d",
+			client.Name(), requests, failures, avgLatency, s.limiters[i].current(), s.limiters[i].max,
+			client.Stats().BreakerState(), client.Stats().Trips.Load())
 	}
 }
@@ -2,67 +2,47 @@ package scheduler
 
 import (
 	"context"
+	"errors"
 	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
-)
-
-// MockClient simulates an RPC client with configurable latency.
-type MockClient struct {
-	name       string
-	latency    time.Duration
-	taskCount  atomic.Int32
-	shouldFail bool
-}
 
-func (m *MockClient) Name() string { return m.name }
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/zacksfF/sepolia-sh/ch2/pkg/rpc"
+	"github.com/zacksfF/sepolia-sh/ch2/pkg/rpc/rpctest"
+)
 
-func (m *MockClient) Process(ctx context.Context) error {
-	select {
-	case <-ctx.Done():
-		return ctx.Err()
-	case <-time.After(m.latency):
+// newTestScheduler creates a scheduler over the endpoints with tasks of 10 blocks.
+func newTestScheduler(maxAttempts int, endpoints ...*rpctest.Endpoint) *Scheduler {
+	clients := make([]Endpoint, len(endpoints))
+	for i, ep := range endpoints {
+		ep.SetLogsPerBlock(1)
+		clients[i] = ep
 	}
-	m.taskCount.Add(1)
-	if m.shouldFail {
-		return context.DeadlineExceeded
-	}
-	return nil
+	return New(clients, Config{BatchSize: 10, MaxAttempts: maxAttempts})
 }
 
 func TestPullBasedDistribution(t *testing.T) {
-	// Create a shared task queue
-	tasks := make(chan int, 100)
-	var wg sync.WaitGroup
-
-	// Create workers with different "speeds"
-	fastWorker := &MockClient{name: "fast", latency: 10 * time.Millisecond}
-	slowWorker := &MockClient{name: "slow", latency: 100 * time.Millisecond}
+	// Create endpoints with different "speeds"
+	fast := rpctest.NewEndpoint("fast", 10*time.Millisecond)
+	slow := rpctest.NewEndpoint("slow", 100*time.Millisecond)
+	s := newTestScheduler(0, fast, slow)
 
-	// Worker function - pulls tasks from queue
-	worker := func(client *MockClient) {
-		defer wg.Done()
-		for range tasks {
-			client.Process(context.Background())
-		}
+	// 20 tasks
+	totalLogs, dead, err := s.Run(context.Background(), 0, 199)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
 	}
-
-	// Start workers
-	wg.Add(2)
-	go worker(fastWorker)
-	go worker(slowWorker)
-
-	// Send 20 tasks
-	for i := 0; i < 20; i++ {
-		tasks <- i
+	if len(dead) != 0 {
+		t.Errorf("Expected no dead tasks, got %d", len(dead))
+	}
+	if totalLogs != 200 {
+		t.Errorf("Expected 200 logs, got %d", totalLogs)
 	}
-	close(tasks)
-
-	wg.Wait()
 
-	fastCount := fastWorker.taskCount.Load()
-	slowCount := slowWorker.taskCount.Load()
+	fastCount := fast.Calls("eth_getLogs")
+	slowCount := slow.Calls("eth_getLogs")
 
 	t.Logf("Fast worker completed: %d tasks", fastCount)
 	t.Logf("Slow worker completed: %d tasks", slowCount)
@@ -79,6 +59,98 @@ func TestPullBasedDistribution(t *testing.T) {
 	}
 }
 
+func TestRunRetriesFailedTask(t *testing.T) {
+	flaky := rpctest.NewEndpoint("flaky", 10*time.Millisecond)
+	healthy := rpctest.NewEndpoint("healthy", 10*time.Millisecond)
+	flaky.Script(rpctest.Step{Err: errors.New("503 Service Unavailable")})
+	s := newTestScheduler(3, flaky, healthy)
+
+	totalLogs, dead, err := s.Run(context.Background(), 0, 39)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if len(dead) != 0 {
+		t.Errorf("Expected no dead tasks, got %d", len(dead))
+	}
+	if totalLogs != 40 {
+		t.Errorf("Expected 40 logs, got %d", totalLogs)
+	}
+	if _, failures, _ := flaky.Stats().GetStats(); failures != 1 {
+		t.Errorf("Expected 1 failure on flaky, got %d", failures)
+	}
+}
+
+func TestRunDeadLetter(t *testing.T) {
+	down := rpctest.NewEndpoint("down", time.Millisecond)
+	failure := errors.New("connection refused")
+	down.SetErr(failure)
+	s := newTestScheduler(2, down)
+
+	_, dead, err := s.Run(context.Background(), 0, 9)
+	if !errors.Is(err, ErrIncomplete) {
+		t.Fatalf("Expected ErrIncomplete, got %v", err)
+	}
+	if len(dead) != 1 {
+		t.Fatalf("Expected 1 dead task, got %d", len(dead))
+	}
+	if dead[0].Attempts != 2 || dead[0].LastErr != failure {
+		t.Errorf("dead[0] = %+v, want 2 attempts failing with %v", dead[0], failure)
+	}
+	if got := down.Calls("eth_getLogs"); got != 2 {
+		t.Errorf("Expected 2 calls, got %d", got)
+	}
+}
+
+func TestRunCancelled(t *testing.T) {
+	stuck := rpctest.NewEndpoint("stuck", time.Minute)
+	s := newTestScheduler(0, stuck)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, _, err := s.Run(ctx, 0, 99)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected deadline exceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Run took %v to stop after cancellation", elapsed)
+	}
+}
+
+func TestStream(t *testing.T) {
+	fast := rpctest.NewEndpoint("fast", time.Millisecond)
+	slow := rpctest.NewEndpoint("slow", 30*time.Millisecond)
+	s := newTestScheduler(0, fast, slow)
+
+	out := make(chan Result)
+	errc := make(chan error, 1)
+	go func() {
+		_, err := s.Stream(context.Background(), 0, 99, out)
+		errc <- err
+	}()
+
+	// results arrive in block order, whichever endpoint finished first
+	var block uint64
+	for result := range out {
+		if result.Err != nil {
+			t.Fatalf("task %d failed: %v", result.Task.ID, result.Err)
+		}
+		for _, lg := range result.Logs {
+			if lg.BlockNumber != block {
+				t.Fatalf("Expected a log of block %d, got block %d", block, lg.BlockNumber)
+			}
+			block++
+		}
+	}
+	if block != 100 {
+		t.Errorf("Expected logs up to block 99, got up to %d", block-1)
+	}
+	if err := <-errc; err != nil {
+		t.Errorf("Stream failed: %v", err)
+	}
+}
+
 func TestBackoffCalculation(t *testing.T) {
 	w := &Worker{}
 
@@ -124,3 +196,384 @@ func TestTaskGeneration(t *testing.T) {
 		}
 	}
 }
+
+func TestCollectResultsRequeue(t *testing.T) {
+	s := &Scheduler{maxAttempts: 3}
+	results := make(chan Result, 3)
+	retries := make(chan Task, 2)
+
+	failure := errors.New("request timed out")
+	results <- Result{Task: Task{ID: 0}, WorkerID: "fast", Err: failure}
+	results <- Result{Task: Task{ID: 1}, WorkerID: "slow", LogCount: 4}
+	results <- Result{Task: Task{ID: 0, Attempts: 1, FailedOn: []string{"fast"}}, WorkerID: "slow", LogCount: 2}
+
+	totalLogs, dead, err := s.collectResults(context.Background(), results, retries, 2)
+	if err != nil {
+		t.Fatalf("collectResults failed: %v", err)
+	}
+	if totalLogs != 6 {
+		t.Errorf("Expected 6 logs, got %d", totalLogs)
+	}
+	if len(dead) != 0 {
+		t.Errorf("Expected no dead tasks, got %d", len(dead))
+	}
+
+	// the failed task was requeued once, then the queue closed
+	retry, ok := <-retries
+	if !ok {
+		t.Fatal("Expected a requeued task")
+	}
+	if retry.ID != 0 || retry.Attempts != 1 || !retry.failedOn("fast") || retry.LastErr != failure {
+		t.Errorf("Requeued task = %+v, want task 0 after 1 attempt on fast", retry)
+	}
+	if _, ok := <-retries; ok {
+		t.Error("Expected the retry queue to be closed")
+	}
+}
+
+func TestCollectResultsDeadLetter(t *testing.T) {
+	s := &Scheduler{maxAttempts: 2}
+	results := make(chan Result, 4)
+	retries := make(chan Task, 2)
+
+	failure := errors.New("503 Service Unavailable")
+	results <- Result{Task: Task{ID: 1}, WorkerID: "a", Err: failure}
+	results <- Result{Task: Task{ID: 0}, WorkerID: "a", Err: failure}
+	results <- Result{Task: Task{ID: 1, Attempts: 1, FailedOn: []string{"a"}}, WorkerID: "b", Err: failure}
+	results <- Result{Task: Task{ID: 0, Attempts: 1, FailedOn: []string{"a"}}, WorkerID: "b", Err: failure}
+
+	_, dead, err := s.collectResults(context.Background(), results, retries, 2)
+	if err != nil {
+		t.Fatalf("collectResults failed: %v", err)
+	}
+	if len(dead) != 2 {
+		t.Fatalf("Expected 2 dead tasks, got %d", len(dead))
+	}
+	for i, task := range dead {
+		if task.ID != i || task.Attempts != 2 || len(task.FailedOn) != 2 || task.FailedOn[1] != "b" {
+			t.Errorf("dead[%d] = %+v, want task %d failed on a and b", i, task, i)
+		}
+	}
+}
+
+func TestReorderResults(t *testing.T) {
+	s := &Scheduler{maxAttempts: 1}
+	results := make(chan Result, 3)
+	retries := make(chan Task, 3)
+	out := make(chan Result, 3)
+	win := newWindow(1 << 20)
+
+	failure := errors.New("request timed out")
+	results <- Result{Task: Task{ID: 2}, WorkerID: "a", Logs: make([]types.Log, 2), LogCount: 2}
+	results <- Result{Task: Task{ID: 0}, WorkerID: "b", Err: failure}
+	results <- Result{Task: Task{ID: 1}, WorkerID: "a", Logs: make([]types.Log, 1), LogCount: 1}
+
+	dead, err := s.reorder(context.Background(), results, retries, out, win, 3)
+	if err != nil {
+		t.Fatalf("reorder failed: %v", err)
+	}
+	if len(dead) != 1 || dead[0].ID != 0 {
+		t.Errorf("Expected task 0 to be dead, got %+v", dead)
+	}
+
+	// the dead task is sent in its place, the rest in block order
+	for i := 0; i < 3; i++ {
+		result := <-out
+		if result.Task.ID != i {
+			t.Errorf("Result %d is task %d", i, result.Task.ID)
+		}
+		if (result.Err != nil) != (i == 0) {
+			t.Errorf("Result %d error = %v", i, result.Err)
+		}
+	}
+	if held := win.held.Load(); held != 0 {
+		t.Errorf("Expected an empty window, %d bytes held", held)
+	}
+}
+
+func TestWindowWait(t *testing.T) {
+	win := newWindow(100)
+	win.hold(150)
+
+	done := make(chan bool)
+	go func() { done <- win.wait(context.Background()) }()
+
+	select {
+	case <-done:
+		t.Fatal("Expected wait to block while the window is full")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	win.release(100)
+	if ok := <-done; !ok {
+		t.Error("Expected wait to return true once the window drained")
+	}
+
+	// a cancelled wait gives up
+	win.hold(100)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if win.wait(ctx) {
+		t.Error("Expected a cancelled wait to return false")
+	}
+}
+
+func TestRunConcurrency(t *testing.T) {
+	paid := rpctest.NewEndpoint("paid", 50*time.Millisecond)
+	paid.SetLogsPerBlock(1)
+	s := New([]Endpoint{paid}, Config{BatchSize: 10, Concurrency: map[string]int{"paid": 4}})
+
+	start := time.Now()
+	totalLogs, _, err := s.Run(context.Background(), 0, 79)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if totalLogs != 80 {
+		t.Errorf("Expected 80 logs, got %d", totalLogs)
+	}
+	if peak := paid.Peak(); peak != 4 {
+		t.Errorf("Expected 4 calls in flight, got %d", peak)
+	}
+	// 8 tasks, 4 at a time
+	if elapsed := time.Since(start); elapsed > 300*time.Millisecond {
+		t.Errorf("Run took %v, want about 100ms", elapsed)
+	}
+}
+
+func TestLimiterAdapts(t *testing.T) {
+	l := newLimiter("test", 4)
+	failure := errors.New("429 Too Many Requests")
+
+	// failures halve the limit, down to 1
+	for _, want := range []int{2, 1, 1} {
+		l.observe(10*time.Millisecond, failure)
+		if got := l.current(); got != want {
+			t.Errorf("After a failure, limit = %d, want %d", got, want)
+		}
+	}
+
+	// a full limit's worth of healthy responses adds one
+	for _, want := range []int{2, 2, 3} {
+		l.observe(10*time.Millisecond, nil)
+		if got := l.current(); got != want {
+			t.Errorf("After a success, limit = %d, want %d", got, want)
+		}
+	}
+
+	// a response well above the baseline takes one away
+	l.observe(50*time.Millisecond, nil)
+	if got := l.current(); got != 2 {
+		t.Errorf("After a slow response, limit = %d, want 2", got)
+	}
+
+	// cancelled requests are ignored
+	l.observe(time.Millisecond, context.Canceled)
+	if got := l.current(); got != 2 {
+		t.Errorf("After a cancelled request, limit = %d, want 2", got)
+	}
+}
+
+func TestLimiterAcquire(t *testing.T) {
+	l := newLimiter("test", 2)
+	ctx := context.Background()
+	if !l.acquire(ctx) || !l.acquire(ctx) {
+		t.Fatal("Expected 2 free slots")
+	}
+
+	acquired := make(chan bool)
+	go func() { acquired <- l.acquire(ctx) }()
+	select {
+	case <-acquired:
+		t.Fatal("Expected acquire to block at the limit")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	l.release()
+	if ok := <-acquired; !ok {
+		t.Error("Expected acquire to succeed after a release")
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if l.acquire(cancelled) {
+		t.Error("Expected a cancelled acquire to fail")
+	}
+}
+
+func TestBreakerTransitions(t *testing.T) {
+	stats := &rpc.Stats{Name: "test"}
+	b := newBreaker("test", stats, 2, 3, 10*time.Millisecond)
+	ctx := context.Background()
+	failure := errors.New("connection refused")
+
+	// consecutive failures trip it; a success in between resets the count
+	b.observe(failure)
+	b.observe(nil)
+	b.observe(failure)
+	if got := stats.BreakerState(); got != rpc.BreakerClosed {
+		t.Fatalf("Expected closed after non-consecutive failures, got %s", got)
+	}
+	b.observe(failure)
+	if got := stats.BreakerState(); got != rpc.BreakerOpen {
+		t.Fatalf("Expected open after 2 consecutive failures, got %s", got)
+	}
+
+	// a failed probe reopens it, a successful one closes it
+	probes := 0
+	probe := func(context.Context) error {
+		probes++
+		if probes == 1 {
+			return failure
+		}
+		return nil
+	}
+	if !b.allow(ctx, probe) {
+		t.Fatal("Expected allow to succeed")
+	}
+	if probes != 2 {
+		t.Errorf("Expected 2 probes, got %d", probes)
+	}
+
+	if got := stats.BreakerState(); got != rpc.BreakerClosed {
+		t.Errorf("Expected closed after a successful probe, got %s", got)
+	}
+	if trips, failed, recovered := stats.Trips.Load(), stats.FailedProbes.Load(), stats.Recoveries.Load(); trips != 1 || failed != 1 || recovered != 1 {
+		t.Errorf("Expected 1 trip, 1 failed probe, 1 recovery, got %d, %d, %d", trips, failed, recovered)
+	}
+}
+
+func TestBreakerGivesUp(t *testing.T) {
+	stats := &rpc.Stats{Name: "test"}
+	b := newBreaker("test", stats, 1, 2, time.Millisecond)
+	b.observe(errors.New("connection refused"))
+
+	probes := 0
+	probe := func(context.Context) error {
+		probes++
+		return errors.New("connection refused")
+	}
+	if b.allow(context.Background(), probe) {
+		t.Fatal("Expected allow to fail once the breaker gave up")
+	}
+	if probes != 2 {
+		t.Errorf("Expected 2 probes, got %d", probes)
+	}
+
+	// a new run probes again at once
+	b.revive()
+	if !b.allow(context.Background(), func(context.Context) error { return nil }) {
+		t.Error("Expected allow to succeed after revive and a good probe")
+	}
+}
+
+func TestBreakerCancelledProbe(t *testing.T) {
+	stats := &rpc.Stats{Name: "test"}
+	b := newBreaker("test", stats, 1, 3, time.Millisecond)
+	b.observe(errors.New("connection refused"))
+
+	// a probe cut short by cancellation says nothing about the endpoint
+	ctx, cancel := context.WithCancel(context.Background())
+	probe := func(ctx context.Context) error {
+		cancel()
+		return ctx.Err()
+	}
+	if b.allow(ctx, probe) {
+		t.Fatal("Expected a cancelled allow to fail")
+	}
+	if trips, failed := stats.Trips.Load(), stats.FailedProbes.Load(); trips != 1 || failed != 0 {
+		t.Errorf("Expected 1 trip and no failed probes, got %d and %d", trips, failed)
+	}
+
+	// the next caller probes instead
+	if !b.allow(context.Background(), func(context.Context) error { return nil }) {
+		t.Error("Expected allow to succeed after a good probe")
+	}
+}
+
+func TestBreakerSingleProbe(t *testing.T) {
+	stats := &rpc.Stats{Name: "test"}
+	b := newBreaker("test", stats, 1, 3, time.Millisecond)
+	b.observe(errors.New("connection refused"))
+
+	// every worker waits for one probe
+	var probes atomic.Int32
+	probe := func(context.Context) error {
+		probes.Add(1)
+		time.Sleep(20 * time.Millisecond)
+		return nil
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if !b.allow(context.Background(), probe) {
+				t.Error("Expected allow to succeed")
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := probes.Load(); got != 1 {
+		t.Errorf("Expected 1 probe, got %d", got)
+	}
+}
+
+func TestRunBreakerStopsPulling(t *testing.T) {
+	down := rpctest.NewEndpoint("down", time.Millisecond)
+	up := rpctest.NewEndpoint("up", 10*time.Millisecond)
+	down.SetErr(errors.New("connection refused"))
+	down.SetLogsPerBlock(1)
+	up.SetLogsPerBlock(1)
+	s := New([]Endpoint{down, up}, Config{BatchSize: 10, BreakerThreshold: 1, BreakerCooldown: time.Minute})
+
+	totalLogs, dead, err := s.Run(context.Background(), 0, 99)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if len(dead) != 0 || totalLogs != 100 {
+		t.Errorf("Expected 100 logs and no dead tasks, got %d logs, %d dead", totalLogs, len(dead))
+	}
+
+	// the open breaker kept the dead endpoint from taking more tasks
+	if got := down.Calls("eth_getLogs"); got != 1 {
+		t.Errorf("Expected 1 call to the dead endpoint, got %d", got)
+	}
+	if got := down.Stats().BreakerState(); got != rpc.BreakerOpen {
+		t.Errorf("Expected the dead endpoint's breaker open, got %s", got)
+	}
+}
+
+func TestRunAllEndpointsDown(t *testing.T) {
+	down := rpctest.NewEndpoint("down", time.Millisecond)
+	failure := errors.New("connection refused")
+	down.SetErr(failure)
+	s := New([]Endpoint{down}, Config{
+		BatchSize:        10,
+		MaxAttempts:      3,
+		BreakerThreshold: 1,
+		BreakerCooldown:  time.Millisecond,
+		BreakerProbes:    2,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// once the only breaker gives up, every task left is dead-lettered
+	// instead of waiting for ctx
+	_, dead, err := s.Run(ctx, 0, 49)
+	if !errors.Is(err, ErrIncomplete) {
+		t.Fatalf("Expected ErrIncomplete, got %v", err)
+	}
+	if len(dead) != 5 {
+		t.Fatalf("Expected 5 dead tasks, got %d", len(dead))
+	}
+	for i, task := range dead {
+		if task.ID != i || !errors.Is(task.LastErr, ErrNoEndpoints) {
+			t.Errorf("dead[%d] = task %d failing with %v, want task %d failing with ErrNoEndpoints", i, task.ID, task.LastErr, i)
+		}
+	}
+	if got := down.Calls("eth_getLogs"); got != 1 {
+		t.Errorf("Expected 1 call before the breaker opened, got %d", got)
+	}
+}
@@ -0,0 +1,143 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync/atomic"
+)
+
+const (
+	// resultOverhead and logOverhead approximate the memory of a Result
+	// and of a types.Log apart from its topics and data.
+	resultOverhead = 128
+	logOverhead    = 200
+)
+
+// Stream executes the scheduler from startBlock to endBlock like Run, but
+// sends every result on out in block order and closes out when done.
+//
+// Tasks finishing ahead of an earlier one wait in a reorder buffer. Once
+// the buffer holds StreamBufferBytes, no new tasks are started until the
+// earlier task completes and the buffer drains; the bound is soft, tasks
+// already in flight can overshoot it. A slow reader of out fills the
+// buffer too, so it holds back the workers the same way.
+//
+// A task that failed every attempt is sent in its place with Err set to
+// its last error. Stream returns the dead-letter tasks as Run does.
+func (s *Scheduler) Stream(ctx context.Context, startBlock, endBlock uint64, out chan<- Result) ([]Task, error) {
+	defer close(out)
+
+	totalTasks := s.countTasks(startBlock, endBlock)
+
+	// Create channels. As in Run, sending to the retry queue never blocks.
+	tasks := make(chan Task, s.bufferSize)
+	retries := make(chan Task, totalTasks)
+	results := make(chan Result, s.bufferSize)
+	win := newWindow(s.streamBytes)
+
+	workerCtx, stopWorkers := context.WithCancel(ctx)
+	defer stopWorkers()
+	wg := s.startWorkers(workerCtx, tasks, retries, results)
+	go s.generateTasks(workerCtx, startBlock, endBlock, tasks, win)
+
+	dead, err := s.reorder(ctx, results, retries, out, win, totalTasks)
+
+	stopWorkers()
+	wg.Wait()
+	s.printStats()
+
+	if err == nil && len(dead) > 0 {
+		err = fmt.Errorf("%d of %d tasks failed: %w", len(dead), totalTasks, ErrIncomplete)
+	}
+	return dead, err
+}
+
+// reorder gathers results like collectResults and sends them on out by
+// task ID. Results waiting for an earlier task are held in the window.
+func (s *Scheduler) reorder(ctx context.Context, results <-chan Result, retries chan<- Task, out chan<- Result, win *window, totalTasks int) ([]Task, error) {
+	pending := make(map[int]Result)
+	next := 0
+	var dead []Task
+
+	for next < totalTasks {
+		// Only offer the head once it is here; a nil channel never sends
+		head, ready := pending[next]
+		var send chan<- Result
+		if ready {
+			send = out
+		}
+
+		select {
+		case <-ctx.Done():
+			return dead, ctx.Err()
+		case send <- head:
+			delete(pending, next)
+			win.release(head.size())
+			next++
+		case result := <-results:
+			if result.Err != nil {
+				task, ok := s.fail(result, retries)
+				if ok {
+					continue
+				}
+				dead = append(dead, task)
+				result = Result{Task: task, WorkerID: result.WorkerID, Err: task.LastErr}
+			}
+			pending[result.Task.ID] = result
+			win.hold(result.size())
+		}
+	}
+	close(retries)
+
+	sort.Slice(dead, func(i, j int) bool { return dead[i].ID < dead[j].ID })
+	return dead, nil
+}
+
+// size estimates the memory held by the result.
+func (r Result) size() int64 {
+	n := int64(resultOverhead)
+	for _, lg := range r.Logs {
+		n += logOverhead + int64(len(lg.Data)) + int64(len(lg.Topics))*32
+	}
+	return n
+}
+
+// window tracks the bytes held in the reorder buffer and lets the task
+// generator wait for room.
+type window struct {
+	limit   int64
+	held    atomic.Int64
+	drained chan struct{} // signalled on every release
+}
+
+func newWindow(limit int64) *window {
+	return &window{limit: limit, drained: make(chan struct{}, 1)}
+}
+
+func (w *window) hold(n int64) {
+	w.held.Add(n)
+}
+
+func (w *window) release(n int64) {
+	w.held.Add(-n)
+	select {
+	case w.drained <- struct{}{}:
+	default:
+	}
+}
+
+// wait blocks while the window is full. It returns false if ctx is done.
+//
+// It cannot wait forever: everything held is behind the head task, which
+// was generated before them and so is in flight or already held.
+func (w *window) wait(ctx context.Context) bool {
+	for w.held.Load() >= w.limit {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-w.drained:
+		}
+	}
+	return true
+}
@@ -1,17 +1,38 @@
 package scheduler
 
+import (
+	"slices"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
 // Task represents a unit of work to be processed by a worker.
 // Each task is a block range to fetch logs from.
 type Task struct {
 	ID        int
 	FromBlock uint64
 	ToBlock   uint64
+
+	Attempts int      // failed attempts so far
+	FailedOn []string // workers that failed it, oldest first
+	LastErr  error    // error of the latest failed attempt
+
+	handedBackAt time.Time // when a worker first passed this attempt on to others
+}
+
+// failedOn reports whether the worker has failed this task before.
+func (t Task) failedOn(worker string) bool {
+	return slices.Contains(t.FailedOn, worker)
 }
 
 // Result contains the outcome of processing a task.
 type Result struct {
 	Task     Task
 	WorkerID string
+	Logs     []types.Log // in block order, as returned by eth_getLogs
 	LogCount int
 	Err      error
+
+	final bool // Err is not worth retrying; dead-letter the task at once
 }
@@ -14,25 +14,34 @@ const (
 	initialBackoff = 1 * time.Second
 	maxBackoff     = 30 * time.Second
 	backoffFactor  = 2.0
+
+	// handoffDelay is how long a worker waits after passing on a task it
+	// failed before, giving an idle worker on another endpoint the chance
+	// to take it.
+	handoffDelay = 100 * time.Millisecond
 )
 
 // Worker processes tasks from a shared queue using its RPC client.
 // It implements pull-based scheduling - taking tasks when ready.
 type Worker struct {
 	id       string
-	client   *rpc.Client
+	client   Endpoint
 	contract common.Address
 	topic    common.Hash
 	tasks    <-chan Task
+	retries  chan Task // failed tasks; shared with the scheduler
 	results  chan<- Result
+	limiter  *limiter // shared by the workers of an endpoint
+	breaker  *breaker // shared by the workers of an endpoint
 }
 
-// NewWorker creates a new worker with the given RPC client.
+// NewWorker creates a new worker with the given RPC endpoint.
 func NewWorker(
-	client *rpc.Client,
+	client Endpoint,
 	contract common.Address,
 	topic common.Hash,
 	tasks <-chan Task,
+	retries chan Task,
 	results chan<- Result,
 ) *Worker {
 	return &Worker{
@@ -41,54 +50,127 @@ func NewWorker(
 		contract: contract,
 		topic:    topic,
 		tasks:    tasks,
+		retries:  retries,
 		results:  results,
+		limiter:  newLimiter(client.Name(), 1),
+		breaker:  newBreaker(client.Name(), client.Stats(), defaultBreakerThreshold, defaultBreakerProbes, defaultBreakerCooldown),
 	}
 }
 
 // Run starts the worker loop. It pulls tasks from the queue and processes them.
-// The worker stops when the context is cancelled or the task channel is closed.
+// The worker stops when the context is cancelled or the scheduler closes the
+// retry queue, which it does once every task has completed or given up.
+// It only pulls while its endpoint's circuit is closed and its limiter has
+// a free slot.
 func (w *Worker) Run(ctx context.Context) {
 	consecutiveFailures := 0
 
 	for {
-		select {
-		case <-ctx.Done():
-			return
-		case task, ok := <-w.tasks:
-			if !ok {
-				return // channel closed, no more tasks
+		// Apply backoff if we've had recent failures, before taking a task
+		// so that no task waits on it
+		if consecutiveFailures > 0 {
+			backoff := w.calculateBackoff(consecutiveFailures)
+			log.Printf("[%s] backing off for %v after %d failures", w.id, backoff, consecutiveFailures)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff):
 			}
+		}
+
+		if !w.breaker.allow(ctx, w.probe) {
+			return
+		}
+		if !w.limiter.acquire(ctx) {
+			return
+		}
+		task, ok := w.next(ctx)
+		if !ok {
+			w.limiter.release()
+			return
+		}
 
-			// Apply backoff if we've had recent failures
-			if consecutiveFailures > 0 {
-				backoff := w.calculateBackoff(consecutiveFailures)
-				log.Printf("[%s] backing off for %v after %d failures", w.id, backoff, consecutiveFailures)
+		// Pass a task this endpoint failed before on to another one, once
+		// per attempt; if nobody takes it in time, we get it back
+		if task.failedOn(w.id) {
+			if task.handedBackAt.IsZero() {
+				task.handedBackAt = time.Now()
+			}
+			if wait := handoffDelay - time.Since(task.handedBackAt); wait > 0 {
+				w.retries <- task // never blocks, see Scheduler.Run
+				w.limiter.release()
 				select {
 				case <-ctx.Done():
 					return
-				case <-time.After(backoff):
+				case <-time.After(wait):
 				}
+				continue
 			}
+		}
 
-			// Process the task
-			result := w.processTask(ctx, task)
-
-			// Track consecutive failures for backoff
-			if result.Err != nil {
-				consecutiveFailures++
-				log.Printf("[%s] task %d failed: %v", w.id, task.ID, result.Err)
-			} else {
-				consecutiveFailures = 0
-				log.Printf("[%s] completed task %d (blocks %d-%d): %d logs",
-					w.id, task.ID, task.FromBlock, task.ToBlock, result.LogCount)
-			}
+		// Process the task
+		start := time.Now()
+		result := w.processTask(ctx, task)
+		w.limiter.observe(time.Since(start), result.Err)
+		w.limiter.release()
+		w.breaker.observe(result.Err)
 
-			// Send result
-			select {
-			case <-ctx.Done():
-				return
-			case w.results <- result:
+		// Track consecutive failures for backoff
+		if result.Err != nil {
+			consecutiveFailures++
+			log.Printf("[%s] task %d failed: %v", w.id, task.ID, result.Err)
+		} else {
+			consecutiveFailures = 0
+			log.Printf("[%s] completed task %d (blocks %d-%d): %d logs",
+				w.id, task.ID, task.FromBlock, task.ToBlock, result.LogCount)
+		}
+
+		// Send result
+		select {
+		case <-ctx.Done():
+			return
+		case w.results <- result:
+		}
+	}
+}
+
+// next pulls the next task. Retries come first, so a failed range is filled
+// in before the backfill moves further ahead.
+func (w *Worker) next(ctx context.Context) (Task, bool) {
+	select {
+	case task, ok := <-w.retries:
+		return task, ok
+	default:
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return Task{}, false
+		case task, ok := <-w.retries:
+			return task, ok
+		case task, ok := <-w.tasks:
+			if !ok {
+				w.tasks = nil // all generated; only retries are left
+				continue
 			}
+			return task, true
+		}
+	}
+}
+
+// failAll fails every task left in the queue with err, for good. It stands
+// in for the workers once all of them have stopped.
+func (w *Worker) failAll(ctx context.Context, err error) {
+	for {
+		task, ok := w.next(ctx)
+		if !ok {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case w.results <- Result{Task: task, WorkerID: w.id, Err: err, final: true}:
 		}
 	}
 }
@@ -102,11 +184,18 @@ func (w *Worker) processTask(ctx context.Context, task Task) Result {
 	return Result{
 		Task:     task,
 		WorkerID: w.id,
+		Logs:     logs,
 		LogCount: len(logs),
 		Err:      err,
 	}
 }
 
+// probe checks that the endpoint answers, for a half-open breaker.
+func (w *Worker) probe(ctx context.Context) error {
+	_, err := w.client.BlockNumber(ctx)
+	return err
+}
+
 // calculateBackoff returns the backoff duration based on failure count.
 // Uses exponential backoff with a maximum cap.
 func (w *Worker) calculateBackoff(failures int) time.Duration {